@@ -0,0 +1,127 @@
+// Command ouigen regenerates internal/oui/oui.txt from the IEEE registry
+// CSV exports (oui.csv for MA-L, mam.csv for MA-M, oui36.csv for MA-S):
+//
+//	go run ./cmd/ouigen -out internal/oui/oui.txt oui.csv mam.csv oui36.csv
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Corporate suffixes dropped to keep the embedded table small.
+var suffixes = []string{
+	"co., ltd.", "co.,ltd.", "co., ltd", "co.,ltd", "co ltd", "co. ltd.",
+	"corporation", "corp.", "corp", "incorporated", "inc.", "inc",
+	"limited", "ltd.", "ltd", "gmbh", "llc", "s.a.", "b.v.", "ag", "oy", "ab",
+}
+
+func main() {
+	out := flag.String("out", "internal/oui/oui.txt", "output file")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: ouigen [-out file] oui.csv [mam.csv oui36.csv]")
+	}
+
+	entries := make(map[string]string)
+	for _, path := range flag.Args() {
+		if err := readRegistry(path, entries); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	prefixes := make([]string, 0, len(entries))
+	for p := range entries {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	for _, p := range prefixes {
+		fmt.Fprintf(w, "%s\t%s\n", p, entries[p])
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d assignments to %s", len(prefixes), *out)
+}
+
+func readRegistry(path string, entries map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	assignCol, orgCol := -1, -1
+	for i, h := range header {
+		switch strings.TrimSpace(h) {
+		case "Assignment":
+			assignCol = i
+		case "Organization Name":
+			orgCol = i
+		}
+	}
+	if assignCol < 0 || orgCol < 0 {
+		return errors.New("missing Assignment or Organization Name column")
+	}
+
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) <= assignCol || len(rec) <= orgCol {
+			continue
+		}
+		prefix := strings.ToUpper(strings.TrimSpace(rec[assignCol]))
+		switch len(prefix) {
+		case 6, 7, 9:
+		default:
+			continue
+		}
+		if name := shorten(rec[orgCol]); name != "" {
+			entries[prefix] = name
+		}
+	}
+}
+
+func shorten(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	for changed := true; changed; {
+		changed = false
+		lower := strings.ToLower(name)
+		for _, s := range suffixes {
+			if strings.HasSuffix(lower, " "+s) || strings.HasSuffix(lower, ","+s) {
+				name = strings.TrimRight(name[:len(name)-len(s)], " ,")
+				changed = true
+				break
+			}
+		}
+	}
+	return name
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadRegistry(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"oui.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-L,00000C,\"Cisco Systems, Inc\",\"170 West Tasman Dr. San Jose CA US 95134\"\n" +
+			"MA-L,f01898,Apple Inc.,Cupertino\n" +
+			"MA-L,BADROW\n",
+		"mam.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-M,70B3D5F,  Example   Widgets GmbH ,Berlin\n",
+		"oui36.csv": "Registry,Assignment,Organization Name,Organization Address\n" +
+			"MA-S,70B3D5F2A,\"Tiny Sensors Co., Ltd.\",Shenzhen\n" +
+			"MA-S,70B3D5F2,Wrong Length,Nowhere\n",
+	}
+	entries := make(map[string]string)
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := readRegistry(path, entries); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	want := map[string]string{
+		"00000C":    "Cisco Systems",
+		"F01898":    "Apple",
+		"70B3D5F":   "Example Widgets",
+		"70B3D5F2A": "Tiny Sensors",
+	}
+	if len(entries) != len(want) {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+	for prefix, name := range want {
+		if entries[prefix] != name {
+			t.Errorf("entries[%s] = %q, want %q", prefix, entries[prefix], name)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	os.WriteFile(bad, []byte("Registry,Prefix,Name\n"), 0o644)
+	if err := readRegistry(bad, entries); err == nil {
+		t.Error("missing columns: want an error")
+	}
+}
+
+func TestShorten(t *testing.T) {
+	for in, want := range map[string]string{
+		"Cisco Systems, Inc":          "Cisco Systems",
+		"HUAWEI TECHNOLOGIES CO.,LTD": "HUAWEI TECHNOLOGIES",
+		"Foo Holdings Co., Ltd.":      "Foo Holdings",
+		"Acme Corporation Limited":    "Acme",
+		"Ag":                          "Ag",
+		"  Spaced \t Out  LLC ":       "Spaced Out",
+	} {
+		if got := shorten(in); got != want {
+			t.Errorf("shorten(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
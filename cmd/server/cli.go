@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"wifi-radar/internal/caps"
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/scanhelper"
+	"wifi-radar/pkg/client"
+)
+
+// Exit codes shared by all commands.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2 // bad flags or arguments; the flag package also uses 2
+	exitNotFound   = 3 // no networks, target not found, not connected
+	exitPermission = 4 // scanning needs CAP_NET_ADMIN or the scan helper
+	exitServer     = 5 // --server unreachable
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "collect samples and serve the web UI and API (default)", runServe},
+	{"scan", "scan once and print the networks as a table or --json", runScan},
+	{"watch", "print one line per sample until interrupted", runWatch},
+	{"tui", "full-screen terminal dashboard", runTUI},
+	{"best", "print the strongest network (or --server's best interface)", runBest},
+	{"export", "write the BSS inventory as WiGLE CSV, KML, GeoJSON or CSV", runExport},
+	{"record", "save samples and scans to a JSON lines file", runRecord},
+	{"replay", "play back a recording through the server or to stdout", runReplay},
+	{"doctor", "check that scanning works on this machine", runDoctor},
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	setupScanHelper()
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				log.Printf("%s: %v", name, err)
+				os.Exit(exitCode(err))
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	os.Exit(exitUsage)
+}
+
+func usage(w *os.File) {
+	fmt.Fprintln(w, "usage: wifi-radar [command] [flags]")
+	fmt.Fprintln(w)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run wifi-radar <command> -h for the command's flags.")
+}
+
+// setupScanHelper sends scans through the setcap'd helper when this
+// process cannot scan itself.
+func setupScanHelper() {
+	if os.Geteuid() == 0 || caps.Has(caps.NetAdmin) {
+		return
+	}
+	if path := scanhelper.Find(); path != "" {
+		collector.UseScanHelper(path)
+	}
+}
+
+// usageError marks errors in the command line itself.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+var errNoNetworks = errors.New("no networks found")
+
+func exitCode(err error) int {
+	var ue usageError
+	var ne net.Error
+	var oe *net.OpError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ue):
+		return exitUsage
+	case errors.Is(err, errNoNetworks), errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.As(err, &oe), errors.As(err, &ne):
+		return exitServer
+	}
+	switch collector.ErrorType(err) {
+	case "target_not_found", "not_connected":
+		return exitNotFound
+	case "permission":
+		return exitPermission
+	}
+	return exitError
+}
+
+// defaultInterface returns ifname, or the first wireless interface when
+// it is empty. Unlike serve, the scripting commands never prompt.
+func defaultInterface(ifname string) (string, error) {
+	if ifname != "" {
+		return ifname, nil
+	}
+	ifs, err := listInterfaces()
+	if err != nil {
+		return "", err
+	}
+	if len(ifs) == 0 {
+		return "", usageError{"no interfaces found; use --if <ifname>"}
+	}
+	return ifs[0], nil
+}
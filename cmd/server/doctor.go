@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"wifi-radar/internal/doctor"
+)
+
+var errChecksFailed = errors.New("some checks failed")
+
+// runDoctor implements "wifi-radar doctor": it checks iw, the
+// interfaces, rfkill, permissions, services holding the interface, the
+// regulatory domain and the web assets, and scans once per interface.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	var (
+		ifname string
+		asJSON bool
+		noScan bool
+	)
+	fs.StringVar(&ifname, "if", "", "interface to check (default: all)")
+	fs.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	fs.BoolVar(&noScan, "no-scan", false, "skip the test scan")
+	_ = fs.Parse(args)
+
+	opts := doctor.Options{Scan: !noScan}
+	if ifname != "" {
+		opts.IfNames = []string{ifname}
+	}
+	opts.StaticDir, opts.StaticErr = resolveStaticDir()
+	report := doctor.Run(opts)
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range report.Checks {
+			mark := map[doctor.Status]string{doctor.OK: "ok  ", doctor.Warn: "warn", doctor.Fail: "FAIL"}[c.Status]
+			fmt.Printf("[%s] %s: %s\n", mark, c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("       fix: %s\n", c.Fix)
+			}
+		}
+	}
+	if !report.OK {
+		return errChecksFailed
+	}
+	return nil
+}
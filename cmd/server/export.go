@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/export"
+	"wifi-radar/internal/gps"
+)
+
+// runExport implements "wifi-radar export": it either downloads the
+// inventory of a running server or scans locally and writes the result.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var (
+		ifname   string
+		format   string
+		out      string
+		scans    int
+		interval time.Duration
+		gpsdAddr string
+		server   string
+		token    string
+	)
+	fs.StringVar(&ifname, "if", "", "interface to scan with")
+	fs.StringVar(&format, "format", export.FormatWiGLE, "output format: "+strings.Join(export.Formats, ", "))
+	fs.StringVar(&out, "out", "", "output file (default stdout)")
+	fs.IntVar(&scans, "scans", 5, "number of scans to run")
+	fs.DurationVar(&interval, "interval", 2*time.Second, "delay between scans")
+	fs.StringVar(&gpsdAddr, "gpsd", "", "gpsd address to tag scans with location, e.g. "+gps.DefaultAddr)
+	fs.StringVar(&server, "server", "", "fetch the inventory from a running server instead, e.g. http://127.0.0.1:8888")
+	fs.StringVar(&token, "token", os.Getenv("WIFI_RADAR_READ_TOKEN"), "bearer token for --server")
+	_ = fs.Parse(args)
+
+	format = strings.ToLower(format)
+	if !export.ValidFormat(format) {
+		return usageError{fmt.Sprintf("invalid format %q (use %s)", format, strings.Join(export.Formats, ", "))}
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if server != "" {
+		return fetchExport(w, server, token, format)
+	}
+
+	ifname, err := defaultInterface(ifname)
+	if err != nil {
+		return err
+	}
+	if scans < 1 {
+		scans = 1
+	}
+
+	inventory := &export.Inventory{}
+	var gpsClient *gps.Client
+	if gpsdAddr != "" {
+		gpsClient = &gps.Client{Addr: gpsdAddr}
+		inventory.Locator = &gps.Estimator{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go gpsClient.Run(ctx)
+	}
+
+	for i := 0; i < scans; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		networks, err := collector.ScanNetworks(ifname, collector.ScanOptions{})
+		if err != nil {
+			log.Printf("scan %d/%d: %v", i+1, scans, err)
+			continue
+		}
+		if gpsClient != nil {
+			if loc := gpsClient.Location(); loc != nil {
+				for j := range networks {
+					networks[j].Location = loc
+				}
+			}
+			inventory.Locator.Observe(networks)
+		}
+		inventory.Observe(networks)
+		log.Printf("scan %d/%d: %d networks", i+1, scans, len(networks))
+	}
+
+	return export.Write(w, format, inventory.BSSes())
+}
+
+func fetchExport(w io.Writer, server, token, format string) error {
+	u := strings.TrimRight(server, "/") + "/api/export?format=" + url.QueryEscape(format)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchExport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("format=" + r.URL.Query().Get("format")))
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	if err := fetchExport(&out, srv.URL+"/", "secret", "kml"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "format=kml" {
+		t.Errorf("body = %q", out.String())
+	}
+
+	err := fetchExport(&out, srv.URL, "", "kml")
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized unauthorized") {
+		t.Errorf("without a token: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/score"
+	"wifi-radar/pkg/client"
+)
+
+// runScan implements "wifi-radar scan": one scan, printed as a table or
+// JSON array.
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	var (
+		ifname string
+		asJSON bool
+		sortBy string
+		ssid   string
+		scan   scanFlags
+	)
+	fs.StringVar(&ifname, "if", "", "interface to scan with (default: first found)")
+	fs.BoolVar(&asJSON, "json", false, "print a JSON array instead of a table")
+	fs.StringVar(&sortBy, "sort", "signal", "sort by signal, ssid or channel")
+	fs.StringVar(&ssid, "ssid", "", "only networks with this SSID")
+	scan.register(fs)
+	_ = fs.Parse(args)
+
+	var less func(a, b model.Sample) bool
+	switch sortBy {
+	case "signal":
+		less = func(a, b model.Sample) bool { return a.SignalDBM > b.SignalDBM }
+	case "ssid":
+		less = func(a, b model.Sample) bool { return strings.ToLower(a.SSID) < strings.ToLower(b.SSID) }
+	case "channel":
+		less = func(a, b model.Sample) bool { return a.FreqMHz < b.FreqMHz }
+	default:
+		return usageError{fmt.Sprintf("invalid --sort %q (use signal, ssid or channel)", sortBy)}
+	}
+
+	networks, err := scanOnce(ifname, ssid, scan)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(networks, func(i, j int) bool { return less(networks[i], networks[j]) })
+
+	if asJSON {
+		return writeJSONLine(os.Stdout, networks)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BSSID\tSSID\tCH\tFREQ\tSIGNAL\tSECURITY\tVENDOR")
+	for _, n := range networks {
+		vendor := n.Vendor
+		if n.Randomized {
+			vendor = "randomized"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\n",
+			n.BSSID, displaySSID(n.SSID), model.Channel(n.FreqMHz), n.FreqMHz, n.SignalDBM, n.Security, vendor)
+	}
+	return tw.Flush()
+}
+
+// runBest implements "wifi-radar best": the strongest network from a local
+// scan, or the best interface of a running server.
+func runBest(args []string) error {
+	fs := flag.NewFlagSet("best", flag.ExitOnError)
+	var (
+		ifname string
+		ssid   string
+		server string
+		token  string
+		asJSON bool
+		scan   scanFlags
+	)
+	fs.StringVar(&ifname, "if", "", "interface to scan with (default: first found)")
+	fs.StringVar(&ssid, "ssid", "", "only consider networks with this SSID")
+	fs.StringVar(&server, "server", "", "ask a running server instead, e.g. http://127.0.0.1:8888")
+	scan.register(fs)
+	fs.StringVar(&token, "token", os.Getenv("WIFI_RADAR_READ_TOKEN"), "bearer token for --server")
+	fs.BoolVar(&asJSON, "json", false, "print JSON")
+	_ = fs.Parse(args)
+
+	var best model.Best
+	if server != "" {
+		c := client.New(server)
+		c.Token = token
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var err error
+		if best, err = c.Best(ctx); err != nil {
+			return err
+		}
+	} else {
+		networks, err := scanOnce(ifname, ssid, scan)
+		if err != nil {
+			return err
+		}
+		best = model.Best{Sample: networks[0], Score: score.SampleScore(networks[0])}
+		for _, n := range networks[1:] {
+			if s := score.SampleScore(n); s > best.Score {
+				best = model.Best{Sample: n, Score: s}
+			}
+		}
+	}
+
+	if asJSON {
+		return writeJSONLine(os.Stdout, best)
+	}
+	fmt.Println(formatSample(best.Sample))
+	return nil
+}
+
+// scanOnce scans with ifname (or the first interface) and returns the
+// networks, optionally only those named ssid. No results is an error so
+// scripts can tell from the exit code.
+func scanOnce(ifname, ssid string, scan scanFlags) ([]model.Sample, error) {
+	ifname, err := defaultInterface(ifname)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := scan.options()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := collector.ScanNetworks(ifname, opts)
+	if err != nil {
+		return nil, err
+	}
+	if ssid != "" {
+		matching := networks[:0]
+		for _, n := range networks {
+			if n.SSID == ssid {
+				matching = append(matching, n)
+			}
+		}
+		networks = matching
+	}
+	if len(networks) == 0 {
+		return nil, errNoNetworks
+	}
+	return networks, nil
+}
+
+// formatSample renders a sample as one tab-separated line: time, interface,
+// BSSID, SSID, signal, frequency and "lost" for a missing target.
+func formatSample(s model.Sample) string {
+	ts := time.Now()
+	if s.TimestampUnixM != 0 {
+		ts = time.UnixMilli(s.TimestampUnixM)
+	}
+	line := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d",
+		ts.Format(time.RFC3339), s.IfName, s.BSSID, displaySSID(s.SSID), s.SignalDBM, s.FreqMHz)
+	if s.Lost {
+		line += "\tlost"
+	}
+	return line
+}
+
+func displaySSID(ssid string) string {
+	if ssid == "" {
+		return "<hidden>"
+	}
+	return ssid
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/collector"
+)
+
+// scanFlags tune the scans of scan mode.
+type scanFlags struct {
+	every         time.Duration
+	maxAge        time.Duration
+	targeted      bool
+	fullScanAfter int
+	passive       bool
+	freqs         string
+}
+
+// register adds --passive and --freq, which apply to any scan.
+func (f *scanFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.passive, "passive", false, "passive scans: listen for beacons instead of probing")
+	fs.StringVar(&f.freqs, "freq", "", "only scan these frequencies in MHz, e.g. 2412,2437")
+}
+
+// registerCollector adds the flags for cached and targeted scans, which
+// only the scan collector uses.
+func (f *scanFlags) registerCollector(fs *flag.FlagSet) {
+	fs.DurationVar(&f.every, "scan-every", 0, "trigger a scan this often and read cached results (iw scan dump) in between; 0 scans every interval")
+	fs.DurationVar(&f.maxAge, "max-age", 0, "with --scan-every, ignore cached results last seen longer ago (default: twice --scan-every)")
+	fs.BoolVar(&f.targeted, "targeted", false, "once the target is found, only scan its frequency and probe for its SSID")
+	fs.IntVar(&f.fullScanAfter, "full-scan-after", 3, "with --targeted, go back to full scans after this many misses in a row")
+}
+
+func (f *scanFlags) options() (collector.ScanOptions, error) {
+	opts := collector.ScanOptions{Passive: f.passive}
+	for _, field := range strings.FieldsFunc(f.freqs, func(r rune) bool { return r == ',' || r == ' ' }) {
+		freq, err := strconv.Atoi(field)
+		if err != nil || freq <= 0 {
+			return opts, usageError{fmt.Sprintf("invalid --freq %q", field)}
+		}
+		opts.Freqs = append(opts.Freqs, freq)
+	}
+	return opts, nil
+}
+
+// configure applies the flags to a scan collector.
+func (f *scanFlags) configure(c *collector.ScanCollector) error {
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	if f.every < 0 || f.maxAge < 0 {
+		return usageError{"--scan-every and --max-age must not be negative"}
+	}
+	if f.fullScanAfter < 1 {
+		return usageError{"--full-scan-after must be at least 1"}
+	}
+	c.Options = opts
+	c.TriggerEvery = f.every
+	c.MaxAge = f.maxAge
+	c.Targeted = f.targeted
+	c.FullScanAfter = f.fullScanAfter
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"wifi-radar/internal/api"
+	"wifi-radar/internal/store"
+	"wifi-radar/internal/tui"
+)
+
+// runTUI implements "wifi-radar tui": a terminal dashboard for machines
+// without a browser.
+func runTUI(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	var src sourceFlags
+	src.register(fs)
+	_ = fs.Parse(args)
+
+	st := store.New(8)
+	collectors, scanner, err := src.build(st)
+	if err != nil {
+		return err
+	}
+
+	// Collection errors arrive as collector events and are shown in the
+	// footer; log lines would tear the screen.
+	logOut := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOut)
+
+	rescan := make(chan struct{}, 1)
+	requestRescan := func() {
+		select {
+		case rescan <- struct{}{}:
+		default:
+		}
+	}
+	opts := tui.Options{Rescan: requestRescan}
+	if scanner != nil {
+		targets := api.API{Store: st, Scanner: scanner, Rescan: requestRescan}
+		opts.SetTarget = func(ssid, bssid string) { targets.SetTarget(ssid, bssid) }
+	}
+	go collectLoop(st, collectors, src.interval, rescan)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+	return tui.Run(ctx, st, opts)
+}
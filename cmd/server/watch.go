@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/record"
+	"wifi-radar/internal/store"
+)
+
+// sourceFlags are the collection flags shared by watch and record.
+type sourceFlags struct {
+	ifname   string
+	mode     string
+	ssid     string
+	bssid    string
+	interval time.Duration
+	count    int
+	duration time.Duration
+	scan     scanFlags
+}
+
+func (f *sourceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.ifname, "if", "", "interface to monitor (default: first found)")
+	fs.StringVar(&f.mode, "mode", "scan", "collection mode: scan or link")
+	fs.StringVar(&f.ssid, "ssid", "", "target SSID (scan mode; default: every network)")
+	fs.StringVar(&f.bssid, "bssid", "", "target BSSID (scan mode)")
+	fs.DurationVar(&f.interval, "interval", time.Second, "sampling interval")
+	f.scan.register(fs)
+	f.scan.registerCollector(fs)
+}
+
+func (f *sourceFlags) registerLimits(fs *flag.FlagSet) {
+	fs.IntVar(&f.count, "count", 0, "stop after this many samples (0: until interrupted)")
+	fs.DurationVar(&f.duration, "duration", 0, "stop after this long (0: until interrupted)")
+}
+
+// build returns the collector for the chosen interface and mode, feeding
+// scan results to st. The scan collector is nil in link mode.
+func (f *sourceFlags) build(st *store.Store) ([]namedSampler, *collector.ScanCollector, error) {
+	if f.mode != "scan" && f.mode != "link" {
+		return nil, nil, usageError{fmt.Sprintf("invalid mode: %s (use scan or link)", f.mode)}
+	}
+	ifname, err := defaultInterface(f.ifname)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.mode == "link" {
+		return []namedSampler{{name: ifname, sampler: collector.Collector{IfName: ifname}}}, nil, nil
+	}
+	scanner := &collector.ScanCollector{
+		IfName: ifname,
+		Target: collector.ScanTarget{SSID: f.ssid, BSSID: strings.ToLower(f.bssid)},
+		OnScan: func(networks []model.Sample) {
+			st.UpdateNetworks(ifname, networks)
+		},
+	}
+	if err := f.scan.configure(scanner); err != nil {
+		return nil, nil, err
+	}
+	return []namedSampler{{name: ifname, sampler: scanner}}, scanner, nil
+}
+
+// start builds collectors feeding st and returns a function running them
+// until the count, duration or an interrupt ends it.
+func (f *sourceFlags) start(st *store.Store) (func() error, error) {
+	collectors, _, err := f.build(st)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if f.duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, f.duration)
+			defer cancel()
+		}
+		return sampleLoop(ctx, st, collectors, f.interval, f.count)
+	}, nil
+}
+
+func (f *sourceFlags) hasTarget() bool {
+	return f.mode == "link" || f.ssid != "" || f.bssid != ""
+}
+
+// sampleLoop is collectLoop for the scripting commands: it stops after
+// count rounds or when ctx is done, and gives up on permission errors
+// rather than logging them forever.
+func sampleLoop(ctx context.Context, st *store.Store, collectors []namedSampler, interval time.Duration, count int) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for round := 1; ; round++ {
+		for _, c := range collectors {
+			sample, err := c.sampler.Collect()
+			switch {
+			case errors.Is(err, collector.ErrStale):
+			case err == nil, errors.Is(err, collector.ErrTargetNotFound):
+				st.Update(sample)
+			case errors.Is(err, collector.ErrPermission), errors.Is(err, collector.ErrNoDevice):
+				return err
+			default:
+				log.Printf("collect %s: %v", c.name, err)
+			}
+		}
+		if count > 0 && round >= count {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printSamples makes st print every sample, or with no target in scan
+// mode every network of every scan, as text lines or JSON lines.
+func printSamples(st *store.Store, w io.Writer, asJSON, perNetwork bool) {
+	emit := func(s model.Sample) {
+		if asJSON {
+			_ = writeJSONLine(w, s)
+			return
+		}
+		fmt.Fprintln(w, formatSample(s))
+	}
+	if perNetwork {
+		st.ObserveNetworks(func(networks []model.Sample) {
+			for _, n := range networks {
+				emit(n)
+			}
+		})
+		return
+	}
+	st.Observe(emit)
+}
+
+// runWatch implements "wifi-radar watch".
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var (
+		src    sourceFlags
+		asJSON bool
+	)
+	src.register(fs)
+	src.registerLimits(fs)
+	fs.BoolVar(&asJSON, "json", false, "print JSON lines")
+	_ = fs.Parse(args)
+
+	st := store.New(1)
+	run, err := src.start(st)
+	if err != nil {
+		return err
+	}
+	printSamples(st, os.Stdout, asJSON, !src.hasTarget())
+	return run()
+}
+
+// runRecord implements "wifi-radar record".
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	var (
+		src sourceFlags
+		out string
+	)
+	src.register(fs)
+	src.registerLimits(fs)
+	fs.StringVar(&out, "out", "", "recording file (default stdout)")
+	_ = fs.Parse(args)
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	st := store.New(1)
+	run, err := src.start(st)
+	if err != nil {
+		return err
+	}
+	rec := record.NewWriter(w)
+	st.Observe(rec.Sample)
+	st.ObserveNetworks(rec.Networks)
+	if err := run(); err != nil {
+		return err
+	}
+	log.Printf("recorded %d entries", rec.Count())
+	return rec.Err()
+}
+
+// runReplay implements "wifi-radar replay <file> [serve flags]". With
+// --print it writes the samples to stdout like watch instead of serving.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var (
+		speed    float64
+		toStdout bool
+		asJSON   bool
+	)
+	fs.Float64Var(&speed, "speed", 1, "playback speed; 0 plays as fast as possible")
+	fs.BoolVar(&toStdout, "print", false, "print samples instead of serving them")
+	fs.BoolVar(&asJSON, "json", false, "with --print, print JSON lines")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		return usageError{"usage: wifi-radar replay [--speed N] [--print] <file> [serve flags]"}
+	}
+	file := fs.Arg(0)
+
+	if !toStdout {
+		serveArgs := append([]string{"--replay", file, fmt.Sprintf("--replay-speed=%g", speed)}, fs.Args()[1:]...)
+		return runServe(serveArgs)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st := store.New(1)
+	printSamples(st, os.Stdout, asJSON, false)
+	printSamples(st, os.Stdout, asJSON, true)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := record.Play(ctx, record.NewReader(f), st, speed); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Command wifi-radar-scan is the privileged scan helper. Give it
+// CAP_NET_ADMIN and install it next to wifi-radar:
+//
+//	go build -o /usr/local/bin/wifi-radar-scan ./cmd/wifi-radar-scan
+//	sudo setcap cap_net_admin+ep /usr/local/bin/wifi-radar-scan
+//
+// wifi-radar starts it when it lacks CAP_NET_ADMIN itself and sends it
+// scan requests over stdin/stdout; see package scanhelper.
+package main
+
+import (
+	"log"
+	"os"
+
+	"wifi-radar/internal/scanhelper"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix(scanhelper.Name + ": ")
+	if len(os.Args) > 1 {
+		log.Fatal("takes no arguments; it is started by wifi-radar")
+	}
+	iw, err := scanhelper.TrustedIw()
+	if err != nil {
+		log.Fatal(err)
+	}
+	run := func(args []string) ([]byte, error) {
+		return scanhelper.Run(iw, args)
+	}
+	if err := scanhelper.Serve(os.Stdin, os.Stdout, run); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+type conditionKind int
+
+const (
+	condThreshold conditionKind = iota
+	condLost
+	condDrop
+	condNewBSS
+)
+
+// condition is a parsed rule expression. Supported forms:
+//
+//	signal_dbm < -75
+//	target lost                      (also "target not found")
+//	tx_mbps drop 50% vs 5m           (below the 5-minute mean by 50%)
+//	new bss [on channel 6]
+//
+// Any of them may end in "for 30s".
+type condition struct {
+	kind    conditionKind
+	field   string
+	op      string
+	value   float64
+	window  time.Duration
+	channel int
+	hold    time.Duration
+}
+
+var fields = map[string]func(model.Sample) float64{
+	"signal_dbm": func(s model.Sample) float64 { return float64(s.SignalDBM) },
+	"rx_mbps":    func(s model.Sample) float64 { return s.RxBitrateMbps },
+	"tx_mbps":    func(s model.Sample) float64 { return s.TxBitrateMbps },
+	"freq_mhz":   func(s model.Sample) float64 { return float64(s.FreqMHz) },
+}
+
+func parseCondition(expr string) (condition, error) {
+	tokens := strings.Fields(strings.ToLower(expr))
+	var c condition
+	if n := len(tokens); n >= 2 && tokens[n-2] == "for" {
+		d, err := time.ParseDuration(tokens[n-1])
+		if err != nil {
+			return c, fmt.Errorf("bad duration %q", tokens[n-1])
+		}
+		c.hold = d
+		tokens = tokens[:n-2]
+	}
+
+	switch {
+	case len(tokens) == 3 && isOp(tokens[1]):
+		if _, ok := fields[tokens[0]]; !ok {
+			return c, fmt.Errorf("unknown field %q", tokens[0])
+		}
+		v, err := strconv.ParseFloat(tokens[2], 64)
+		if err != nil {
+			return c, fmt.Errorf("bad number %q", tokens[2])
+		}
+		c.kind, c.field, c.op, c.value = condThreshold, tokens[0], tokens[1], v
+	case strings.Join(tokens, " ") == "target lost" || strings.Join(tokens, " ") == "target not found":
+		c.kind = condLost
+	case len(tokens) == 5 && (tokens[1] == "drop" || tokens[1] == "dropped") && tokens[3] == "vs":
+		if _, ok := fields[tokens[0]]; !ok {
+			return c, fmt.Errorf("unknown field %q", tokens[0])
+		}
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(tokens[2], "%"), 64)
+		if err != nil || pct <= 0 || pct >= 100 {
+			return c, fmt.Errorf("bad percentage %q", tokens[2])
+		}
+		window, err := time.ParseDuration(tokens[4])
+		if err != nil || window <= 0 {
+			return c, fmt.Errorf("bad baseline window %q", tokens[4])
+		}
+		c.kind, c.field, c.value, c.window = condDrop, tokens[0], pct, window
+	case len(tokens) >= 2 && tokens[0] == "new" && tokens[1] == "bss":
+		c.kind = condNewBSS
+		rest := tokens[2:]
+		if len(rest) == 0 {
+			break
+		}
+		if len(rest) != 3 || rest[0] != "on" || rest[1] != "channel" {
+			return c, fmt.Errorf("expected \"new bss on channel N\"")
+		}
+		ch, err := strconv.Atoi(rest[2])
+		if err != nil || ch <= 0 {
+			return c, fmt.Errorf("bad channel %q", rest[2])
+		}
+		c.channel = ch
+	default:
+		return c, fmt.Errorf("unrecognized condition %q", expr)
+	}
+	return c, nil
+}
+
+func isOp(s string) bool {
+	switch s {
+	case "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// compare reports whether v meets the threshold shifted by offset.
+func (c condition) compare(v, offset float64) bool {
+	switch c.op {
+	case "<":
+		return v < c.value+offset
+	case "<=":
+		return v <= c.value+offset
+	case ">":
+		return v > c.value-offset
+	default:
+		return v >= c.value-offset
+	}
+}
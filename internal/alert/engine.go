@@ -0,0 +1,414 @@
+// Package alert evaluates user-defined rules against every sample and scan
+// and reports when they start and stop firing.
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	StateOK       = "ok"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+
+	defaultSeverity = "warning"
+	// minBaseline is the number of samples a drop rule needs before it
+	// trusts its baseline.
+	minBaseline = 5
+)
+
+type Rule struct {
+	Name string `json:"name"`
+	When string `json:"when"`
+	// For is how long the condition has to hold before the rule fires.
+	// A trailing "for 30s" in When overrides it.
+	For Duration `json:"for,omitempty"`
+	// Hysteresis is how far back past the threshold a value has to go to
+	// resolve: dB or Mbps for thresholds, percentage points for drops.
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+	// Cooldown suppresses repeat notifications after a rule fired on the
+	// same interface.
+	Cooldown Duration `json:"cooldown,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+	// IfName limits the rule to one interface.
+	IfName string `json:"ifname,omitempty"`
+}
+
+// Duration is a time.Duration that reads from JSON strings like "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+type Engine struct {
+	// OnAlert is called when a rule fires or resolves, outside the
+	// engine's lock.
+	OnAlert func(model.Alert)
+
+	mu     sync.Mutex
+	rules  []*rule
+	known  map[string]bool
+	seeded bool
+}
+
+type rule struct {
+	Rule
+	cond  condition
+	hold  time.Duration
+	state map[string]*ruleState
+}
+
+type ruleState struct {
+	state      string
+	since      int64
+	value      float64
+	firedAt    int64
+	resolvedAt int64
+	fireCount  int
+	notified   bool
+	// lastNotified is when the rule last sent a firing alert for this
+	// interface.
+	lastNotified int64
+	history      []point
+	// frozen is the drop baseline while the rule is pending or firing, so
+	// the drop itself never becomes the baseline.
+	frozen float64
+}
+
+type point struct {
+	ts int64
+	v  float64
+}
+
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{known: make(map[string]bool)}
+	names := make(map[string]bool)
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", r.Name)
+		}
+		names[r.Name] = true
+		cond, err := parseCondition(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		if r.Severity == "" {
+			r.Severity = defaultSeverity
+		}
+		hold := time.Duration(r.For)
+		if cond.hold > 0 {
+			hold = cond.hold
+		}
+		e.rules = append(e.rules, &rule{Rule: r, cond: cond, hold: hold, state: make(map[string]*ruleState)})
+	}
+	return e, nil
+}
+
+// Observe evaluates the per-sample rules. It is meant to run on every
+// store update.
+func (e *Engine) Observe(sample model.Sample) {
+	now := sample.TimestampUnixM
+	if now == 0 {
+		now = model.NowUnixMS()
+	}
+
+	var alerts []model.Alert
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if r.cond.kind == condNewBSS || (r.IfName != "" && r.IfName != sample.IfName) {
+			continue
+		}
+		if a, ok := r.evaluate(sample, now); ok {
+			alerts = append(alerts, a)
+		}
+	}
+	e.mu.Unlock()
+	e.notify(alerts)
+}
+
+// ObserveNetworks evaluates "new bss" rules against a scan. The first scan
+// only records what is already there.
+func (e *Engine) ObserveNetworks(networks []model.Sample) {
+	now := model.NowUnixMS()
+
+	var alerts []model.Alert
+	e.mu.Lock()
+	seeding := !e.seeded
+	e.seeded = true
+	for _, n := range networks {
+		if n.Lost || n.BSSID == "" || e.known[n.BSSID] {
+			continue
+		}
+		e.known[n.BSSID] = true
+		if seeding {
+			continue
+		}
+		for _, r := range e.rules {
+			if r.cond.kind != condNewBSS || (r.IfName != "" && r.IfName != n.IfName) {
+				continue
+			}
+			if r.cond.channel != 0 && model.Channel(n.FreqMHz) != r.cond.channel {
+				continue
+			}
+			if a, ok := r.newBSS(n, now); ok {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+	e.mu.Unlock()
+	e.notify(alerts)
+}
+
+func (e *Engine) notify(alerts []model.Alert) {
+	if e.OnAlert == nil {
+		return
+	}
+	for _, a := range alerts {
+		e.OnAlert(a)
+	}
+}
+
+// States lists every rule with its state on each interface it has seen.
+func (e *Engine) States() []model.RuleState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var out []model.RuleState
+	for _, r := range e.rules {
+		base := model.RuleState{
+			Rule:       r.Name,
+			When:       r.When,
+			Severity:   r.Severity,
+			HoldMS:     r.hold.Milliseconds(),
+			CooldownMS: time.Duration(r.Cooldown).Milliseconds(),
+			State:      StateOK,
+		}
+		if len(r.state) == 0 {
+			out = append(out, base)
+			continue
+		}
+		keys := make([]string, 0, len(r.state))
+		for k := range r.state {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			st := r.state[k]
+			rs := base
+			rs.Key = k
+			rs.State = st.state
+			rs.SinceMS = st.since
+			rs.Value = st.value
+			rs.LastFiredMS = st.firedAt
+			rs.LastResolvedMS = st.resolvedAt
+			rs.FireCount = st.fireCount
+			out = append(out, rs)
+		}
+	}
+	return out
+}
+
+func (r *rule) evaluate(s model.Sample, now int64) (model.Alert, bool) {
+	st := r.state[s.IfName]
+	if st == nil {
+		st = &ruleState{state: StateOK, since: now}
+		r.state[s.IfName] = st
+	}
+
+	var active, holding bool
+	switch r.cond.kind {
+	case condLost:
+		active = s.Lost
+		holding = active
+	case condThreshold:
+		if s.Lost {
+			return model.Alert{}, false
+		}
+		st.value = fields[r.cond.field](s)
+		active = r.cond.compare(st.value, 0)
+		holding = r.cond.compare(st.value, r.Hysteresis)
+	case condDrop:
+		if s.Lost {
+			return model.Alert{}, false
+		}
+		v := fields[r.cond.field](s)
+		st.value = v
+		baseline, ok := st.frozen, true
+		if st.state == StateOK {
+			baseline, ok = st.baseline(now, r.cond.window)
+			st.frozen = baseline
+		}
+		if !ok || baseline <= 0 {
+			st.history = append(st.history, point{ts: now, v: v})
+			return model.Alert{}, false
+		}
+		active = v < baseline*(1-r.cond.value/100)
+		holding = v < baseline*(1-(r.cond.value-r.Hysteresis)/100)
+		// Only values that leave the rule OK count towards later
+		// baselines.
+		if !active && (st.state != StateFiring || !holding) {
+			st.history = append(st.history, point{ts: now, v: v})
+		}
+	}
+
+	switch st.state {
+	case StateOK:
+		if !active {
+			return model.Alert{}, false
+		}
+		st.state, st.since = StatePending, now
+		fallthrough
+	case StatePending:
+		if !active {
+			st.state, st.since = StateOK, now
+			return model.Alert{}, false
+		}
+		if now-st.since < r.hold.Milliseconds() {
+			return model.Alert{}, false
+		}
+		st.state, st.since = StateFiring, now
+		st.firedAt = now
+		st.fireCount++
+		st.notified = r.mayNotify(st, now)
+		if !st.notified {
+			return model.Alert{}, false
+		}
+		return r.alert(s.IfName, StateFiring, st, now), true
+	case StateFiring:
+		if holding {
+			return model.Alert{}, false
+		}
+		st.state, st.since = StateOK, now
+		st.resolvedAt = now
+		if !st.notified {
+			return model.Alert{}, false
+		}
+		st.notified = false
+		return r.alert(s.IfName, StateResolved, st, now), true
+	}
+	return model.Alert{}, false
+}
+
+func (r *rule) newBSS(n model.Sample, now int64) (model.Alert, bool) {
+	st := r.state[n.IfName]
+	if st == nil {
+		st = &ruleState{state: StateOK}
+		r.state[n.IfName] = st
+	}
+	st.since = now
+	st.firedAt = now
+	st.fireCount++
+	st.value = float64(n.SignalDBM)
+	if !r.mayNotify(st, now) {
+		return model.Alert{}, false
+	}
+	ssid := n.SSID
+	if ssid == "" {
+		ssid = "<hidden>"
+	}
+	return model.Alert{
+		ID:          r.Name + "/" + n.BSSID,
+		Kind:        "rule",
+		Rule:        r.Name,
+		State:       StateFiring,
+		Severity:    r.Severity,
+		SSID:        n.SSID,
+		BSSID:       n.BSSID,
+		Message:     fmt.Sprintf("%s: new BSS %s (%s) on channel %d at %d dBm", r.Name, n.BSSID, ssid, model.Channel(n.FreqMHz), n.SignalDBM),
+		SignalDBM:   n.SignalDBM,
+		FirstSeenMS: now,
+		LastSeenMS:  now,
+		Count:       1,
+	}, true
+}
+
+func (r *rule) mayNotify(st *ruleState, now int64) bool {
+	cooldown := time.Duration(r.Cooldown).Milliseconds()
+	if st.lastNotified != 0 && now-st.lastNotified < cooldown {
+		return false
+	}
+	st.lastNotified = now
+	return true
+}
+
+func (r *rule) alert(ifname, state string, st *ruleState, now int64) model.Alert {
+	verb := "firing"
+	if state == StateResolved {
+		verb = "resolved"
+	}
+	msg := fmt.Sprintf("%s %s on %s: %s", r.Name, verb, ifname, r.When)
+	if r.cond.kind != condLost {
+		msg += fmt.Sprintf(" (now %s)", strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.1f", st.value), "0"), "."))
+	}
+	return model.Alert{
+		ID:          r.Name + "/" + ifname,
+		Kind:        "rule",
+		Rule:        r.Name,
+		State:       state,
+		Severity:    r.Severity,
+		IfName:      ifname,
+		Message:     msg,
+		FirstSeenMS: st.firedAt,
+		LastSeenMS:  now,
+		Count:       st.fireCount,
+	}
+}
+
+// baseline returns the mean over window before now and drops older
+// points.
+func (st *ruleState) baseline(now int64, window time.Duration) (float64, bool) {
+	cutoff := now - window.Milliseconds()
+	i := 0
+	for i < len(st.history) && st.history[i].ts < cutoff {
+		i++
+	}
+	st.history = st.history[i:]
+	if len(st.history) < minBaseline {
+		return 0, false
+	}
+	var sum float64
+	for _, p := range st.history {
+		sum += p.v
+	}
+	return sum / float64(len(st.history)), true
+}
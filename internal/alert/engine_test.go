@@ -0,0 +1,368 @@
+package alert
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+func TestParseCondition(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		want condition
+	}{
+		{"signal_dbm < -75", condition{kind: condThreshold, field: "signal_dbm", op: "<", value: -75}},
+		{"RX_Mbps >= 100 for 30s", condition{kind: condThreshold, field: "rx_mbps", op: ">=", value: 100, hold: 30 * time.Second}},
+		{"target lost", condition{kind: condLost}},
+		{"target not found for 1m", condition{kind: condLost, hold: time.Minute}},
+		{"tx_mbps drop 50% vs 5m", condition{kind: condDrop, field: "tx_mbps", value: 50, window: 5 * time.Minute}},
+		{"signal_dbm dropped 20 vs 30s for 10s", condition{kind: condDrop, field: "signal_dbm", value: 20, window: 30 * time.Second, hold: 10 * time.Second}},
+		{"new bss", condition{kind: condNewBSS}},
+		{"new bss on channel 6", condition{kind: condNewBSS, channel: 6}},
+	} {
+		got, err := parseCondition(tt.expr)
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCondition(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"":                          "unrecognized",
+		"signal_dbm == -75":         "unrecognized",
+		"noise_dbm < -90":           "unknown field",
+		"signal_dbm < loud":         "bad number",
+		"signal_dbm < -75 for ever": "bad duration",
+		"bogus drop 50% vs 5m":      "unknown field",
+		"tx_mbps drop 150% vs 5m":   "bad percentage",
+		"tx_mbps drop 0% vs 5m":     "bad percentage",
+		"tx_mbps drop 50% vs -5m":   "bad baseline window",
+		"new bss on 6":              "new bss on channel N",
+		"new bss on channel six":    "bad channel",
+		"target gone":               "unrecognized",
+	} {
+		_, err := parseCondition(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseCondition(%q) error = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	below := condition{op: "<", value: -75}
+	above := condition{op: ">=", value: 100}
+	for _, tt := range []struct {
+		c      condition
+		v, off float64
+		want   bool
+	}{
+		{below, -80, 0, true},
+		{below, -75, 0, false},
+		{below, -74, 3, true}, // resolves only above -72
+		{below, -72, 3, false},
+		{above, 100, 0, true},
+		{above, 95, 10, true}, // resolves only below 90
+		{above, 89, 10, false},
+	} {
+		if got := tt.c.compare(tt.v, tt.off); got != tt.want {
+			t.Errorf("%s %v: compare(%v, %v) = %v", tt.c.op, tt.c.value, tt.v, tt.off, got)
+		}
+	}
+}
+
+// recorder collects the engine's notifications.
+type recorder struct{ alerts []model.Alert }
+
+func (r *recorder) take() []model.Alert {
+	out := r.alerts
+	r.alerts = nil
+	return out
+}
+
+func newTestEngine(t *testing.T, rules ...Rule) (*Engine, *recorder) {
+	t.Helper()
+	e, err := NewEngine(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{}
+	e.OnAlert = func(a model.Alert) { rec.alerts = append(rec.alerts, a) }
+	return e, rec
+}
+
+// t0 is the base for test timestamps; a zero timestamp means "now".
+const t0 = int64(1_700_000_000_000)
+
+func sample(ts int64, signal int) model.Sample {
+	return model.Sample{IfName: "wlan0", TimestampUnixM: t0 + ts, SignalDBM: signal}
+}
+
+// expectStates feeds signals at the given offsets from t0 and checks the
+// rule state and notification after each.
+func expectStates(t *testing.T, e *Engine, rec *recorder, steps []struct {
+	ts     int64
+	signal int
+	state  string
+	notify string
+}) {
+	t.Helper()
+	for _, s := range steps {
+		e.Observe(sample(s.ts, s.signal))
+		if got := e.States()[0].State; got != s.state {
+			t.Errorf("t=%d signal=%d: state %s, want %s", s.ts, s.signal, got, s.state)
+		}
+		var notified []string
+		for _, a := range rec.take() {
+			notified = append(notified, a.State)
+		}
+		if got := strings.Join(notified, ","); got != s.notify {
+			t.Errorf("t=%d signal=%d: notified %q, want %q", s.ts, s.signal, got, s.notify)
+		}
+	}
+}
+
+func TestHoldAndHysteresis(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "weak", When: "signal_dbm < -75 for 10s", Hysteresis: 3})
+	expectStates(t, e, rec, []struct {
+		ts     int64
+		signal int
+		state  string
+		notify string
+	}{
+		{0, -80, StatePending, ""},
+		{5000, -70, StateOK, ""}, // recovered before the hold ran out
+		{6000, -80, StatePending, ""},
+		{15000, -80, StatePending, ""},
+		{16000, -79, StateFiring, StateFiring},
+		{17000, -73, StateFiring, ""}, // above -75 but within the hysteresis
+		{18000, -71, StateOK, StateResolved},
+	})
+
+	st := e.States()[0]
+	if st.Key != "wlan0" || st.FireCount != 1 || st.LastFiredMS != t0+16000 || st.LastResolvedMS != t0+18000 || st.HoldMS != 10000 {
+		t.Errorf("state = %+v", st)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "weak", When: "signal_dbm < -75", Cooldown: Duration(time.Minute)})
+	expectStates(t, e, rec, []struct {
+		ts     int64
+		signal int
+		state  string
+		notify string
+	}{
+		{0, -80, StateFiring, StateFiring},
+		{1000, -60, StateOK, StateResolved},
+		// Fires again within the cooldown: tracked, but silent both ways.
+		{2000, -80, StateFiring, ""},
+		{3000, -60, StateOK, ""},
+		{61000, -80, StateFiring, StateFiring},
+	})
+	if n := e.States()[0].FireCount; n != 3 {
+		t.Errorf("fire count = %d, want 3", n)
+	}
+}
+
+func TestAlertContents(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{When: "signal_dbm < -75", Severity: "critical", IfName: "wlan0"})
+	e.Observe(model.Sample{IfName: "wlan1", TimestampUnixM: t0, SignalDBM: -90})
+	if len(rec.alerts) != 0 {
+		t.Fatalf("rule for wlan0 fired on wlan1")
+	}
+	e.Observe(sample(1000, -82))
+	alerts := rec.take()
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	a := alerts[0]
+	want := model.Alert{
+		ID: "rule-1/wlan0", Kind: "rule", Rule: "rule-1", State: StateFiring, Severity: "critical",
+		IfName: "wlan0", Message: "rule-1 firing on wlan0: signal_dbm < -75 (now -82)",
+		FirstSeenMS: t0 + 1000, LastSeenMS: t0 + 1000, Count: 1,
+	}
+	if a != want {
+		t.Errorf("alert = %+v\nwant    %+v", a, want)
+	}
+}
+
+func TestLostRule(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "gone", When: "target lost"})
+	e.Observe(sample(0, -50))
+	e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0 + 1000, Lost: true})
+	e.Observe(sample(2000, -50))
+	alerts := rec.take()
+	if len(alerts) != 2 || alerts[0].State != StateFiring || alerts[1].State != StateResolved {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	if strings.Contains(alerts[0].Message, "now") {
+		t.Errorf("lost alert has a value: %q", alerts[0].Message)
+	}
+}
+
+func TestDropRule(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "slow", When: "tx_mbps drop 50% vs 1m", Hysteresis: 10})
+	tx := func(ts int64, v float64) {
+		e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0 + ts, TxBitrateMbps: v})
+	}
+	// Until minBaseline samples are in, nothing can drop.
+	tx(0, 100)
+	tx(1000, 10)
+	if len(rec.alerts) != 0 {
+		t.Fatalf("fired without a baseline: %+v", rec.alerts)
+	}
+	for ts := int64(2000); ts < 6000; ts += 1000 {
+		tx(ts, 100)
+	}
+	// The baseline is now (100+10+100*4)/6 = 85.
+	tx(6000, 40)
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].State != StateFiring {
+		t.Fatalf("alerts = %+v, want firing", alerts)
+	}
+	// Resolving needs the value back above 60% of the baseline.
+	tx(7000, 45)
+	if len(rec.alerts) != 0 {
+		t.Errorf("resolved within the hysteresis: %+v", rec.alerts)
+	}
+	tx(8000, 90)
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].State != StateResolved {
+		t.Errorf("alerts = %+v, want resolved", alerts)
+	}
+
+	// Points older than the window leave the baseline.
+	st := e.rules[0].state["wlan0"]
+	if _, ok := st.baseline(t0+120000, time.Minute); ok || len(st.history) != 0 {
+		t.Errorf("stale history kept: %+v", st.history)
+	}
+}
+
+// A drop that lasts longer than the window must not become its own
+// baseline and resolve itself.
+func TestDropRuleSustained(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "slow", When: "tx_mbps drop 50% vs 30s"})
+	tx := func(ts int64, v float64) {
+		e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0 + ts, TxBitrateMbps: v})
+	}
+	for ts := int64(0); ts < 10000; ts += 1000 {
+		tx(ts, 100)
+	}
+	for ts := int64(10000); ts < 130000; ts += 1000 {
+		tx(ts, 30)
+		if st := e.States()[0].State; st != StateFiring {
+			t.Fatalf("t=%d: %s during a sustained drop", ts, st)
+		}
+	}
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].State != StateFiring {
+		t.Fatalf("alerts = %+v, want one firing", alerts)
+	}
+	tx(130000, 95)
+	if alerts := rec.take(); len(alerts) != 1 || alerts[0].State != StateResolved {
+		t.Errorf("alerts = %+v, want resolved", alerts)
+	}
+
+	// A drop that stays pending does not pull the baseline down either.
+	e, _ = newTestEngine(t, Rule{Name: "slow", When: "tx_mbps drop 50% vs 30s for 1m"})
+	for ts := int64(0); ts < 5000; ts += 1000 {
+		tx(ts, 100)
+	}
+	for ts := int64(5000); ts < 20000; ts += 1000 {
+		tx(ts, 40)
+	}
+	st := e.rules[0].state["wlan0"]
+	if b, ok := st.baseline(t0+20000, 30*time.Second); !ok || b != 100 || st.frozen != 100 {
+		t.Errorf("baseline = %v %v, frozen %v; want 100", b, ok, st.frozen)
+	}
+}
+
+func TestCooldownPerInterface(t *testing.T) {
+	e, rec := newTestEngine(t, Rule{Name: "weak", When: "signal_dbm < -75", Cooldown: Duration(time.Minute)})
+	e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0, SignalDBM: -80})
+	e.Observe(model.Sample{IfName: "wlan1", TimestampUnixM: t0 + 1000, SignalDBM: -80})
+	e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0 + 2000, SignalDBM: -60})
+	e.Observe(model.Sample{IfName: "wlan0", TimestampUnixM: t0 + 3000, SignalDBM: -80})
+	var got []string
+	for _, a := range rec.take() {
+		got = append(got, a.ID+" "+a.State)
+	}
+	// wlan1 has its own cooldown; wlan0's second firing is within its own.
+	want := "weak/wlan0 firing, weak/wlan1 firing, weak/wlan0 resolved"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("alerts = %v, want %s", got, want)
+	}
+}
+
+func TestNewBSSRule(t *testing.T) {
+	e, rec := newTestEngine(t,
+		Rule{Name: "any", When: "new bss"},
+		Rule{Name: "ch6", When: "new bss on channel 6", Cooldown: Duration(time.Hour)},
+	)
+	bss := func(bssid string, freq int) model.Sample {
+		return model.Sample{IfName: "wlan0", BSSID: bssid, FreqMHz: freq, SignalDBM: -60}
+	}
+	e.ObserveNetworks([]model.Sample{bss("aa", 2412)})
+	if len(rec.alerts) != 0 {
+		t.Fatalf("the first scan only seeds: %+v", rec.alerts)
+	}
+	e.ObserveNetworks([]model.Sample{bss("aa", 2412), bss("bb", 2437), {BSSID: "cc", Lost: true}})
+	e.ObserveNetworks([]model.Sample{bss("dd", 2437), bss("bb", 2437)})
+
+	var got []string
+	for _, a := range rec.take() {
+		got = append(got, a.ID)
+	}
+	// ch6 is in its cooldown for dd.
+	if want := "any/bb ch6/bb any/dd"; strings.Join(got, " ") != want {
+		t.Errorf("alerts = %v, want %s", got, want)
+	}
+	// Per-sample observations never evaluate new-bss rules.
+	e.Observe(bss("ee", 2437))
+	if len(rec.alerts) != 0 {
+		t.Errorf("Observe fired a new bss rule: %+v", rec.alerts)
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	if _, err := NewEngine([]Rule{{Name: "a", When: "target lost"}, {Name: "a", When: "new bss"}}); err == nil {
+		t.Error("duplicate names: want an error")
+	}
+	if _, err := NewEngine([]Rule{{Name: "a", When: "signal is bad"}}); err == nil || !strings.Contains(err.Error(), `rule "a"`) {
+		t.Errorf("bad expression: err = %v", err)
+	}
+
+	e, err := NewEngine([]Rule{{When: "target lost", For: Duration(5 * time.Second)}, {When: "target lost for 1s", For: Duration(time.Hour)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := e.States()
+	if states[0].Rule != "rule-1" || states[0].Severity != defaultSeverity || states[0].State != StateOK || states[0].HoldMS != 5000 {
+		t.Errorf("defaults: %+v", states[0])
+	}
+	if states[1].HoldMS != 1000 {
+		t.Errorf("a trailing \"for\" should override For: hold %d ms", states[1].HoldMS)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var r Rule
+	if err := json.Unmarshal([]byte(`{"when":"target lost","for":"1m30s","cooldown":"10m"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(r.For) != 90*time.Second || time.Duration(r.Cooldown) != 10*time.Minute {
+		t.Errorf("rule = %+v", r)
+	}
+	if err := json.Unmarshal([]byte(`{"for":30}`), &r); err == nil {
+		t.Error("numeric duration: want an error")
+	}
+	data, _ := json.Marshal(Duration(90 * time.Second))
+	if string(data) != `"1m30s"` {
+		t.Errorf("marshal = %s", data)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+// streamState is what Stream and WebSocket keep per client: its filter,
+// the networks it was last sent (for ?delta=1) and its rate limit
+// (?max_hz=).
+type streamState struct {
+	filter   streamFilter
+	delta    *deltaState
+	interval time.Duration
+	next     time.Time
+	pending  *model.Event
+	timer    *time.Timer
+}
+
+func newStreamState(r *http.Request) (*streamState, error) {
+	f, err := parseStreamFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	st := &streamState{filter: f}
+
+	q := r.URL.Query()
+	if v := q.Get("max_hz"); v != "" {
+		hz, err := strconv.ParseFloat(v, 64)
+		if err != nil || hz <= 0 {
+			return nil, fmt.Errorf("invalid max_hz %q", v)
+		}
+		st.interval = time.Duration(float64(time.Second) / hz)
+	}
+	if v := q.Get("delta"); v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delta %q", v)
+		}
+		if on {
+			st.delta = &deltaState{}
+		}
+	}
+	return st, nil
+}
+
+// throttle reports whether ev may be sent now. A status arriving sooner
+// than the rate limit allows is held back, replacing any status already
+// held, until due fires.
+func (st *streamState) throttle(ev model.Event, now time.Time) bool {
+	if st.interval == 0 || ev.Type != model.EventStatus {
+		return true
+	}
+	if now.Before(st.next) {
+		st.pending = &ev
+		if st.timer == nil {
+			st.timer = time.NewTimer(st.next.Sub(now))
+		}
+		return false
+	}
+	st.next = now.Add(st.interval)
+	st.pending = nil
+	st.stop()
+	return true
+}
+
+// due fires when a held status may be sent; take it with release.
+func (st *streamState) due() <-chan time.Time {
+	if st.pending == nil || st.timer == nil {
+		return nil
+	}
+	return st.timer.C
+}
+
+func (st *streamState) release(now time.Time) model.Event {
+	ev := *st.pending
+	st.pending = nil
+	st.timer = nil
+	st.next = now.Add(st.interval)
+	return ev
+}
+
+func (st *streamState) stop() {
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+}
+
+// reset makes the next status a full one, e.g. after missed events.
+func (st *streamState) reset() {
+	if st.delta != nil {
+		st.delta.sent = nil
+	}
+}
+
+// encode returns the message type and payload to send for ev, or false
+// if the client filtered it out. Unfiltered events reuse the payload the
+// store encoded once; only filtered statuses and deltas are encoded per
+// client.
+func (st *streamState) encode(ev model.Event) (string, []byte, bool) {
+	if !st.filter.wants(ev.Type) {
+		return "", nil, false
+	}
+	status, ok := ev.Data.(model.Status)
+	if !ok || (st.delta == nil && st.filter.all()) {
+		if ev.Payload == nil {
+			payload, err := json.Marshal(ev.Data)
+			return ev.Type, payload, err == nil
+		}
+		return ev.Type, ev.Payload, true
+	}
+
+	status = st.filter.apply(status)
+	if st.delta != nil {
+		if delta, ok := st.delta.diff(status); ok {
+			payload, err := json.Marshal(delta)
+			return "delta", payload, err == nil
+		}
+	}
+	payload, err := json.Marshal(status)
+	return ev.Type, payload, err == nil
+}
+
+// netSig holds the fields of a network that count as a change; the
+// timestamp alone does not.
+type netSig struct {
+	ssid     string
+	security string
+	signal   int
+	freq     int
+	lost     bool
+	lat, lon float64
+}
+
+func signature(n model.Sample) netSig {
+	sig := netSig{ssid: n.SSID, security: n.Security, signal: n.SignalDBM, freq: n.FreqMHz, lost: n.Lost}
+	if n.Location != nil {
+		sig.lat, sig.lon = n.Location.Lat, n.Location.Lon
+	}
+	return sig
+}
+
+type deltaState struct {
+	sent map[string]netSig
+}
+
+// diff records status as sent and returns what changed since the last
+// call. It returns false on the first call, when the client needs the
+// full status.
+func (d *deltaState) diff(status model.Status) (model.StatusDelta, bool) {
+	first := d.sent == nil
+	seen := make(map[string]netSig, len(status.Networks))
+	delta := model.StatusDelta{Interfaces: status.Interfaces, DF: status.DF}
+	for _, n := range status.Networks {
+		key := n.IfName + "/" + n.BSSID
+		sig := signature(n)
+		seen[key] = sig
+		if old, ok := d.sent[key]; !ok || old != sig {
+			delta.Networks = append(delta.Networks, n)
+		}
+	}
+	for key := range d.sent {
+		if _, ok := seen[key]; !ok {
+			ifname, bssid, _ := strings.Cut(key, "/")
+			delta.Removed = append(delta.Removed, model.NetworkRef{IfName: ifname, BSSID: bssid})
+		}
+	}
+	d.sent = seen
+	return delta, !first
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+func network(bssid string, signal int) model.Sample {
+	return model.Sample{IfName: "wlan0", BSSID: bssid, SSID: "net-" + bssid, SignalDBM: signal, FreqMHz: 2412}
+}
+
+func bssids(networks []model.Sample) string {
+	var out []string
+	for _, n := range networks {
+		out = append(out, n.BSSID)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestDeltaDiff(t *testing.T) {
+	var d deltaState
+	status := model.Status{
+		Interfaces: []model.Sample{{IfName: "wlan0", SignalDBM: -50}},
+		Networks:   []model.Sample{network("a", -50), network("b", -60), network("c", -70)},
+	}
+	if _, ok := d.diff(status); ok {
+		t.Fatal("first diff: want a full status")
+	}
+
+	// Only the timestamp changed for a; b moved; c is gone; d is new.
+	a := network("a", -50)
+	a.TimestampUnixM = 12345
+	b := network("b", -61)
+	status = model.Status{
+		Interfaces: []model.Sample{{IfName: "wlan0", SignalDBM: -51}},
+		Networks:   []model.Sample{a, b, network("d", -80)},
+	}
+	delta, ok := d.diff(status)
+	if !ok {
+		t.Fatal("second diff: want a delta")
+	}
+	if got := bssids(delta.Networks); got != "b,d" {
+		t.Errorf("changed = %s, want b,d", got)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0] != (model.NetworkRef{IfName: "wlan0", BSSID: "c"}) {
+		t.Errorf("removed = %+v, want wlan0/c", delta.Removed)
+	}
+	if len(delta.Interfaces) != 1 || delta.Interfaces[0].SignalDBM != -51 {
+		t.Errorf("interfaces = %+v, want them complete", delta.Interfaces)
+	}
+
+	// Each of these fields counts as a change on its own.
+	for name, change := range map[string]func(*model.Sample){
+		"ssid":     func(n *model.Sample) { n.SSID = "renamed" },
+		"security": func(n *model.Sample) { n.Security = "OPEN" },
+		"freq":     func(n *model.Sample) { n.FreqMHz = 5180 },
+		"lost":     func(n *model.Sample) { n.Lost = true },
+		"location": func(n *model.Sample) { n.Location = &model.Location{Lat: 1, Lon: 2} },
+	} {
+		var d deltaState
+		n := network("a", -50)
+		d.diff(model.Status{Networks: []model.Sample{n}})
+		change(&n)
+		if delta, _ := d.diff(model.Status{Networks: []model.Sample{n}}); len(delta.Networks) != 1 {
+			t.Errorf("%s change not in the delta", name)
+		}
+	}
+
+	// Nothing changed: an empty delta, still with the interfaces.
+	delta, _ = d.diff(status)
+	if len(delta.Networks) != 0 || len(delta.Removed) != 0 || len(delta.Interfaces) != 1 {
+		t.Errorf("unchanged status gave %+v", delta)
+	}
+
+	// The same BSS seen on two interfaces is tracked per interface.
+	other := network("b", -61)
+	other.IfName = "wlan1"
+	status.Networks = append(status.Networks, other)
+	if delta, _ := d.diff(status); len(delta.Networks) != 1 || delta.Networks[0].IfName != "wlan1" {
+		t.Errorf("second interface: %+v", delta.Networks)
+	}
+}
+
+func TestStreamStateEncode(t *testing.T) {
+	st, err := newStreamState(httptest.NewRequest("GET", "/api/stream?delta=1&bssid=AA:BB,cc:dd", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := model.Status{Networks: []model.Sample{network("aa:bb", -50), network("cc:dd", -60), network("ee:ff", -70)}}
+	ev := model.Event{ID: 1, Type: model.EventStatus, Data: status, Payload: []byte(`{"stale":true}`)}
+
+	typ, payload, ok := st.encode(ev)
+	var full model.Status
+	if !ok || typ != model.EventStatus || json.Unmarshal(payload, &full) != nil || bssids(full.Networks) != "aa:bb,cc:dd" {
+		t.Fatalf("first = %s %s, want the filtered full status", typ, payload)
+	}
+
+	status.Networks[0].SignalDBM = -40
+	status.Networks[2].SignalDBM = -40 // filtered out, so no change
+	typ, payload, _ = st.encode(model.Event{ID: 2, Type: model.EventStatus, Data: status})
+	var delta model.StatusDelta
+	if typ != "delta" || json.Unmarshal(payload, &delta) != nil || bssids(delta.Networks) != "aa:bb" {
+		t.Errorf("second = %s %s, want a delta with aa:bb", typ, payload)
+	}
+
+	// After missed events the client needs a full status again.
+	st.reset()
+	if typ, _, _ := st.encode(model.Event{ID: 3, Type: model.EventStatus, Data: status}); typ != model.EventStatus {
+		t.Errorf("after reset = %s, want status", typ)
+	}
+
+	// Without filters or delta, the store's payload is sent as is.
+	plain, _ := newStreamState(httptest.NewRequest("GET", "/api/stream", nil))
+	if _, payload, _ := plain.encode(ev); string(payload) != `{"stale":true}` {
+		t.Errorf("unfiltered payload = %s, want the shared one", payload)
+	}
+	alerts, _ := newStreamState(httptest.NewRequest("GET", "/api/stream?types=alert", nil))
+	if _, _, ok := alerts.encode(ev); ok {
+		t.Error("types=alert let a status through")
+	}
+}
+
+func TestStreamStateParams(t *testing.T) {
+	for query, want := range map[string]time.Duration{
+		"":             0,
+		"max_hz=2":     500 * time.Millisecond,
+		"max_hz=0.5":   2 * time.Second,
+		"max_hz=10":    100 * time.Millisecond,
+		"delta=false":  0,
+		"delta=1":      0,
+		"delta=true&x": 0,
+	} {
+		st, err := newStreamState(httptest.NewRequest("GET", "/api/stream?"+query, nil))
+		if err != nil {
+			t.Errorf("%q: %v", query, err)
+			continue
+		}
+		if st.interval != want {
+			t.Errorf("%q: interval %v, want %v", query, st.interval, want)
+		}
+		if on := strings.Contains(query, "delta=1") || strings.Contains(query, "delta=true"); (st.delta != nil) != on {
+			t.Errorf("%q: delta %v", query, st.delta != nil)
+		}
+	}
+	for _, query := range []string{"max_hz=0", "max_hz=-1", "max_hz=fast", "delta=maybe"} {
+		if _, err := newStreamState(httptest.NewRequest("GET", "/api/stream?"+query, nil)); err == nil {
+			t.Errorf("%q: want an error", query)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	st, _ := newStreamState(httptest.NewRequest("GET", "/api/stream?max_hz=2", nil))
+	defer st.stop()
+	status := func(id uint64) model.Event { return model.Event{ID: id, Type: model.EventStatus} }
+	t0 := time.Unix(1700000000, 0)
+
+	if !st.throttle(status(1), t0) {
+		t.Fatal("first status held back")
+	}
+	if st.due() != nil {
+		t.Error("due armed with nothing held")
+	}
+	// Within 500 ms: held, and a newer one replaces it.
+	if st.throttle(status(2), t0.Add(100*time.Millisecond)) || st.throttle(status(3), t0.Add(200*time.Millisecond)) {
+		t.Fatal("status within the interval was sent")
+	}
+	// Other events are never throttled.
+	if !st.throttle(model.Event{ID: 4, Type: "alert"}, t0.Add(300*time.Millisecond)) {
+		t.Error("alert was throttled")
+	}
+	select {
+	case <-st.due():
+	case <-time.After(2 * time.Second):
+		t.Fatal("due never fired")
+	}
+	if ev := st.release(t0.Add(500 * time.Millisecond)); ev.ID != 3 {
+		t.Errorf("released %d, want the newest held status 3", ev.ID)
+	}
+	if st.due() != nil {
+		t.Error("due still armed after release")
+	}
+
+	// The interval restarts at the release.
+	if st.throttle(status(5), t0.Add(900*time.Millisecond)) {
+		t.Error("status 400 ms after a release was sent")
+	}
+	if !st.throttle(status(6), t0.Add(1000*time.Millisecond)) {
+		t.Error("status a full interval after the release was held")
+	}
+	if st.due() != nil || st.pending != nil {
+		t.Error("sending a status should drop the held one")
+	}
+
+	// Without max_hz everything goes through.
+	free, _ := newStreamState(httptest.NewRequest("GET", "/api/stream", nil))
+	for i := uint64(1); i <= 3; i++ {
+		if !free.throttle(status(i), t0) {
+			t.Errorf("status %d held without max_hz", i)
+		}
+	}
+}
+
+// TestStreamMaxHz checks the rate limit end to end: a burst of updates
+// yields one status at once and the newest one after the interval.
+func TestStreamMaxHz(t *testing.T) {
+	st := store.New(10)
+	srv := httptest.NewServer(http.HandlerFunc(API{Store: st}.Stream))
+	defer srv.Close()
+
+	start := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		start <- time.Now()
+		for i := 1; i <= 10; i++ {
+			st.Update(model.Sample{IfName: "wlan0", SignalDBM: -70 + i})
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	msgs := readSSE(t, srv.URL+"?max_hz=4", nil, 2)
+	elapsed := time.Since(<-start)
+
+	if msgs[1].id != "10" {
+		t.Errorf("ids = %s, %s; want the newest status (10) second", msgs[0].id, msgs[1].id)
+	}
+	if elapsed < 200*time.Millisecond {
+		t.Errorf("two statuses within %v at max_hz=4", elapsed)
+	}
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"wifi-radar/internal/doctor"
+)
+
+// Diagnostics runs the doctor checks, without the scan check since the
+// collector is already scanning. ?ifname= picks the interfaces; by
+// default they are the ones being sampled.
+func (a API) Diagnostics(w http.ResponseWriter, r *http.Request) {
+	opts := doctor.Options{StaticDir: a.StaticDir}
+	if v := r.URL.Query().Get("ifname"); v != "" {
+		opts.IfNames = strings.Split(v, ",")
+	} else {
+		for _, s := range a.Store.LatestStatus().Interfaces {
+			opts.IfNames = append(opts.IfNames, s.IfName)
+		}
+	}
+	writeJSON(w, doctor.Run(opts))
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"wifi-radar/internal/model"
+)
+
+var streamTypes = []string{
+	model.EventStatus, "hunt", model.EventAlert, model.EventTarget, model.EventScan, model.EventCollector,
+}
+
+type streamFilter struct {
+	types   map[string]bool
+	ifnames map[string]bool
+	bssids  map[string]bool
+}
+
+func parseStreamFilter(r *http.Request) (streamFilter, error) {
+	q := r.URL.Query()
+	f := streamFilter{
+		types:   splitSet(q.Get("types"), false),
+		ifnames: splitSet(q.Get("ifname"), false),
+		bssids:  splitSet(q.Get("bssid"), true),
+	}
+	for t := range f.types {
+		known := false
+		for _, st := range streamTypes {
+			known = known || st == t
+		}
+		if !known {
+			return f, fmt.Errorf("unknown event type %q (use %s)", t, strings.Join(streamTypes, ", "))
+		}
+	}
+	return f, nil
+}
+
+func (f streamFilter) wants(typ string) bool {
+	return f.types == nil || f.types[typ]
+}
+
+// all reports whether status messages pass unfiltered.
+func (f streamFilter) all() bool {
+	return f.ifnames == nil && f.bssids == nil
+}
+
+// apply limits a status to the requested interfaces and BSSIDs. The
+// status may be shared, so filtered slices are copies.
+func (f streamFilter) apply(status model.Status) model.Status {
+	if f.ifnames != nil {
+		interfaces := make([]model.Sample, 0, len(status.Interfaces))
+		for _, s := range status.Interfaces {
+			if f.ifnames[s.IfName] {
+				interfaces = append(interfaces, s)
+			}
+		}
+		status.Interfaces = interfaces
+	}
+	if f.bssids != nil || f.ifnames != nil {
+		networks := make([]model.Sample, 0, len(status.Networks))
+		for _, n := range status.Networks {
+			if (f.bssids == nil || f.bssids[strings.ToLower(n.BSSID)]) &&
+				(f.ifnames == nil || f.ifnames[n.IfName]) {
+				networks = append(networks, n)
+			}
+		}
+		status.Networks = networks
+	}
+	return status
+}
+
+func splitSet(value string, lower bool) map[string]bool {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if lower {
+			part = strings.ToLower(part)
+		}
+		if part != "" {
+			set[part] = true
+		}
+	}
+	return set
+}
+
+// lastEventID reads the resume cursor from the Last-Event-ID header, or
+// the last_event_id query parameter for clients that cannot set headers.
+func lastEventID(r *http.Request) (uint64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
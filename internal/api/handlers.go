@@ -2,41 +2,356 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"wifi-radar/internal/alert"
+	"wifi-radar/internal/audio"
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/df"
+	"wifi-radar/internal/distance"
+	"wifi-radar/internal/export"
+	"wifi-radar/internal/gps"
+	"wifi-radar/internal/hunt"
 	"wifi-radar/internal/model"
+	"wifi-radar/internal/rogue"
 	"wifi-radar/internal/score"
 	"wifi-radar/internal/store"
+	"wifi-radar/internal/survey"
 )
 
 type API struct {
-	Store *store.Store
+	Store     *store.Store
+	Distance  *distance.Estimator
+	DF        *df.Finder
+	Hunter    *hunt.Tracker
+	Sound     audio.Mapping
+	Surveys   *survey.Manager
+	NoiseDBM  float64
+	GPS       *gps.Client
+	Locator   *gps.Estimator
+	Inventory *export.Inventory
+	Rogue     *rogue.Detector
+	Rules     *alert.Engine
+	// Scanner is the scan-mode collector, nil in link mode.
+	Scanner *collector.ScanCollector
+	// Rescan asks the collect loop to sample right away.
+	Rescan func()
+	// StaticDir is where the web UI is served from, for diagnostics.
+	StaticDir string
 }
 
 func (a API) Status(w http.ResponseWriter, r *http.Request) {
 	status := a.Store.LatestStatus()
-	writeJSON(w, status)
+	writeJSON(w, a.Decorate(status))
 }
 
 func (a API) Best(w http.ResponseWriter, r *http.Request) {
-	samples := a.Store.SmoothedSamples()
-	if len(samples) == 0 {
+	best, ok := bestSample(a.Store.SmoothedSamples())
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
+	writeJSON(w, best)
+}
 
-	best := model.Best{Sample: samples[0], Score: score.SampleScore(samples[0])}
-	for _, s := range samples[1:] {
-		scoreVal := score.SampleScore(s)
-		if scoreVal > best.Score {
-			best = model.Best{Sample: s, Score: scoreVal}
+// History returns recent raw samples. ?ifname= limits it to one
+// interface, ?since_ms= to samples newer than a Unix millisecond
+// timestamp and ?limit= to the newest N.
+func (a API) History(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var (
+		since int64
+		limit int
+		err   error
+	)
+	if v := q.Get("since_ms"); v != "" {
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "invalid since_ms", http.StatusBadRequest)
+			return
 		}
 	}
-	writeJSON(w, best)
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, a.Store.History(q.Get("ifname"), since, limit))
+}
+
+func (a API) Networks(w http.ResponseWriter, r *http.Request) {
+	networks := a.Store.Networks()
+	if networks == nil {
+		networks = []model.Sample{}
+	}
+	writeJSON(w, networks)
+}
+
+func (a API) Position(w http.ResponseWriter, r *http.Request) {
+	if a.GPS == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	loc := a.GPS.Location()
+	if loc == nil {
+		http.Error(w, "no GPS fix", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, loc)
+}
+
+func (a API) Locations(w http.ResponseWriter, r *http.Request) {
+	if a.Locator == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if bssid := r.URL.Query().Get("bssid"); bssid != "" {
+		estimate, ok := a.Locator.Estimate(strings.ToLower(bssid))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, estimate)
+		return
+	}
+	writeJSON(w, a.Locator.Estimates())
 }
 
+func (a API) Export(w http.ResponseWriter, r *http.Request) {
+	if a.Inventory == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = export.FormatWiGLE
+	}
+	if !export.ValidFormat(format) {
+		http.Error(w, fmt.Sprintf("invalid format %q (use %s)", format, strings.Join(export.Formats, ", ")), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName(format)))
+	_ = export.Write(w, format, a.Inventory.BSSes())
+}
+
+func (a API) Alerts(w http.ResponseWriter, r *http.Request) {
+	if a.Rules == nil {
+		writeJSON(w, []model.RuleState{})
+		return
+	}
+	writeJSON(w, a.Rules.States())
+}
+
+func (a API) RogueAlerts(w http.ResponseWriter, r *http.Request) {
+	if a.Rogue == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, a.Rogue.Alerts())
+	case http.MethodDelete:
+		a.Rogue.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a API) CalibrateDistance(w http.ResponseWriter, r *http.Request) {
+	if a.Distance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		a.Distance.Reset()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		IfName string  `json:"ifname"`
+		Meters float64 `json:"meters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		sample model.Sample
+		ok     bool
+	)
+	if req.IfName != "" {
+		sample, ok = a.Store.SmoothedSample(req.IfName)
+	} else {
+		var best model.Best
+		best, ok = bestSample(a.Store.SmoothedSamples())
+		sample = best.Sample
+	}
+	if !ok {
+		http.Error(w, "no samples yet", http.StatusConflict)
+		return
+	}
+
+	d, err := a.Distance.Calibrate(sample, req.Meters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, d)
+}
+
+func (a API) Heading(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.DF == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		HeadingDeg *float64 `json:"heading_deg"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.HeadingDeg == nil || math.IsNaN(*req.HeadingDeg) || math.IsInf(*req.HeadingDeg, 0) {
+		http.Error(w, "heading_deg is required", http.StatusBadRequest)
+		return
+	}
+	a.DF.SetHeading(*req.HeadingDeg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a API) DirectionFinding(w http.ResponseWriter, r *http.Request) {
+	if a.DF == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshot, ok := a.DF.Snapshot()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, snapshot)
+	case http.MethodDelete:
+		a.DF.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a API) Hunt(w http.ResponseWriter, r *http.Request) {
+	if a.Hunter == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshot, ok := a.Hunter.Snapshot()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, snapshot)
+	case http.MethodDelete:
+		a.Hunter.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a API) Audio(w http.ResponseWriter, r *http.Request) {
+	mapping, err := a.Sound.WithQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/wav")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	var flush func()
+	if flusher, ok := w.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+	params := func() model.AudioParams {
+		return mapping.Params(a.trackedSample())
+	}
+	_ = audio.StreamWAV(r.Context(), w, params, flush)
+}
+
+func (a API) AudioParams(w http.ResponseWriter, r *http.Request) {
+	mapping, err := a.Sound.WithQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ctx := r.Context()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	var last model.AudioParams
+	for first := true; ; first = false {
+		params := mapping.Params(a.trackedSample())
+		if first || params != last {
+			payload, _ := json.Marshal(params)
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+			last = params
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stream serves status and events as SSE. Each journal event carries its
+// id and type (status, alert, target, scan, collector); a hunt message
+// follows each status under the same id, since it is derived from it. A
+// reconnecting client resumes after Last-Event-ID (or ?last_event_id=)
+// from the replay buffer, or gets a "reset" message without an id when it
+// was away for longer. ?types= limits the event types, ?ifname= and
+// ?bssid= (comma separated) filter status messages, ?delta=1 sends only
+// changed networks after the first status and ?max_hz= limits the status
+// rate, always sending the newest.
 func (a API) Stream(w http.ResponseWriter, r *http.Request) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -44,24 +359,62 @@ func (a API) Stream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	st, err := newStreamState(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer st.stop()
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, "retry: 2000\n\n")
+
+	cursor, resume := lastEventID(r)
+	if resume && cursor > a.Store.LastEventID() {
+		// IDs restart with the server; an ID from the future is stale.
+		resume = false
+	}
+	if !resume {
+		// Fresh clients start with the current status, then follow.
+		cursor = a.Store.LastEventID()
+		if ev, ok := a.Store.LatestEvent(model.EventStatus); ok && st.throttle(ev, time.Now()) {
+			a.writeEvent(w, st, ev)
+		}
+	}
+	flusher.Flush()
 
-	ch := a.Store.Subscribe()
-	defer a.Store.Unsubscribe(ch)
+	reader := a.Store.Follow(cursor)
+	defer reader.Close()
 
 	ctx := r.Context()
 	ping := time.NewTicker(10 * time.Second)
 	defer ping.Stop()
 
 	for {
+		events, missed, wait := reader.Next()
+		if missed {
+			// The client was away longer than the replay buffer covers.
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+			st.reset()
+		}
+		now := time.Now()
+		for _, ev := range events {
+			if st.throttle(ev, now) {
+				a.writeEvent(w, st, ev)
+			}
+		}
+		if len(events) > 0 || missed {
+			flusher.Flush()
+		}
+
 		select {
 		case <-ctx.Done():
 			return
-		case status := <-ch:
-			payload, _ := json.Marshal(status)
-			fmt.Fprintf(w, "data: %s\n\n", payload)
+		case <-wait:
+		case <-st.due():
+			a.writeEvent(w, st, st.release(time.Now()))
 			flusher.Flush()
 		case <-ping.C:
 			fmt.Fprint(w, ": ping\n\n")
@@ -70,6 +423,69 @@ func (a API) Stream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a API) writeEvent(w io.Writer, st *streamState, ev model.Event) {
+	typ, payload, ok := st.encode(ev)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, typ, payload)
+
+	if ev.Type == model.EventStatus && a.Hunter != nil && st.filter.wants("hunt") {
+		if snapshot, ok := a.Hunter.Snapshot(); ok {
+			payload, _ := json.Marshal(snapshot)
+			fmt.Fprintf(w, "id: %d\nevent: hunt\ndata: %s\n\n", ev.ID, payload)
+		}
+	}
+}
+
+// Decorate adds derived per-interface data to a status. The status may be
+// shared with other readers, so the interface slice is copied first. The
+// store runs it on every update; see store.SetDecorator.
+func (a API) Decorate(status model.Status) model.Status {
+	if a.DF != nil {
+		if snapshot, ok := a.DF.Snapshot(); ok {
+			status.DF = &snapshot
+		}
+	}
+	if a.Distance == nil || len(status.Interfaces) == 0 {
+		return status
+	}
+
+	interfaces := make([]model.Sample, len(status.Interfaces))
+	copy(interfaces, status.Interfaces)
+	for i, s := range interfaces {
+		smoothed, ok := a.Store.SmoothedSample(s.IfName)
+		if !ok {
+			continue
+		}
+		if d, ok := a.Distance.Estimate(smoothed); ok {
+			interfaces[i].Distance = &d
+		}
+	}
+	status.Interfaces = interfaces
+	return status
+}
+
+func (a API) trackedSample() (model.Sample, bool) {
+	best, ok := bestSample(a.Store.SmoothedSamples())
+	return best.Sample, ok
+}
+
+func bestSample(samples []model.Sample) (model.Best, bool) {
+	if len(samples) == 0 {
+		return model.Best{}, false
+	}
+
+	best := model.Best{Sample: samples[0], Score: score.SampleScore(samples[0])}
+	for _, s := range samples[1:] {
+		scoreVal := score.SampleScore(s)
+		if scoreVal > best.Score {
+			best = model.Best{Sample: s, Score: scoreVal}
+		}
+	}
+	return best, true
+}
+
 func writeJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
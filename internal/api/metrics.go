@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/metrics"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/score"
+)
+
+// Metrics serves the Prometheus text exposition format.
+func (a API) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := metrics.NewWriter(w)
+
+	status := a.Store.LatestStatus()
+	latest := status.Interfaces
+	sort.Slice(latest, func(i, j int) bool { return latest[i].IfName < latest[j].IfName })
+	smoothed := a.Store.SmoothedSamples()
+	sort.Slice(smoothed, func(i, j int) bool { return smoothed[i].IfName < smoothed[j].IfName })
+
+	ifaceGauge := func(name, help string, samples []model.Sample, value func(model.Sample) float64) {
+		mw.Family(name, help, "gauge")
+		for _, s := range samples {
+			mw.Sample(name, value(s), "ifname", s.IfName, "ssid", s.SSID, "bssid", s.BSSID)
+		}
+	}
+	ifaceGauge("wifi_radar_signal_dbm", "Latest signal of the tracked network.", latest,
+		func(s model.Sample) float64 { return float64(s.SignalDBM) })
+	ifaceGauge("wifi_radar_signal_smoothed_dbm", "Signal averaged over the store's sample window.", smoothed,
+		func(s model.Sample) float64 { return float64(s.SignalDBM) })
+	ifaceGauge("wifi_radar_rx_bitrate_mbps", "Latest receive bitrate.", latest,
+		func(s model.Sample) float64 { return s.RxBitrateMbps })
+	ifaceGauge("wifi_radar_tx_bitrate_mbps", "Latest transmit bitrate.", latest,
+		func(s model.Sample) float64 { return s.TxBitrateMbps })
+	ifaceGauge("wifi_radar_freq_mhz", "Frequency of the tracked network.", latest,
+		func(s model.Sample) float64 { return float64(s.FreqMHz) })
+	ifaceGauge("wifi_radar_score", "Link score of the smoothed sample.", smoothed,
+		func(s model.Sample) float64 { return float64(score.SampleScore(s)) })
+	ifaceGauge("wifi_radar_target_lost", "1 if the target was missing from the last scan.", latest,
+		func(s model.Sample) float64 { return boolValue(s.Lost) })
+
+	mw.Family("wifi_radar_bss_signal_dbm", "Signal of each BSS in the last scan.", "gauge")
+	for _, n := range status.Networks {
+		mw.Sample("wifi_radar_bss_signal_dbm", float64(n.SignalDBM), bssLabels(n)...)
+	}
+	mw.Family("wifi_radar_bss_freq_mhz", "Frequency of each BSS in the last scan.", "gauge")
+	for _, n := range status.Networks {
+		mw.Sample("wifi_radar_bss_freq_mhz", float64(n.FreqMHz), bssLabels(n)...)
+	}
+
+	mw.Histogram("wifi_radar_scan_duration_seconds", "Time taken by iw scan.", collector.ScanDuration)
+	mw.CounterVec("wifi_radar_collect_errors_total", "Collection errors by type.", "type", &collector.Errors)
+	mw.Counter("wifi_radar_helper_scans_total", "Scans run through the privileged scan helper.", &collector.HelperScans)
+
+	stats := a.Store.Stats()
+	mw.Gauge("wifi_radar_stream_subscribers", "Connected SSE and WebSocket clients.", float64(stats.Readers))
+	mw.Family("wifi_radar_broadcasts_total", "Status updates published to stream clients.", "counter")
+	mw.Sample("wifi_radar_broadcasts_total", float64(stats.Broadcasts))
+	mw.Family("wifi_radar_stream_coalesced_total", "Status updates skipped for slow clients because a newer one was waiting.", "counter")
+	mw.Sample("wifi_radar_stream_coalesced_total", float64(stats.Coalesced))
+	mw.Family("wifi_radar_stream_missed_total", "Client reads that found events already gone from the replay buffer.", "counter")
+	mw.Sample("wifi_radar_stream_missed_total", float64(stats.Missed))
+}
+
+func bssLabels(n model.Sample) []string {
+	return []string{
+		"ifname", n.IfName,
+		"bssid", n.BSSID,
+		"ssid", n.SSID,
+		"channel", strconv.Itoa(model.Channel(n.FreqMHz)),
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
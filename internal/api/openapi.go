@@ -0,0 +1,55 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+func (a API) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+// CheckOpenAPI reports routes missing from openapi.json and documented
+// operations with no route.
+func CheckOpenAPI(routes []Route) error {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		return fmt.Errorf("openapi.json: %w", err)
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method := range item {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	var problems []string
+	for _, rt := range routes {
+		if !documented[rt.Pattern()] {
+			problems = append(problems, "undocumented route "+rt.Pattern())
+		}
+		delete(documented, rt.Pattern())
+	}
+	for op := range documented {
+		problems = append(problems, "documented operation without a route: "+op)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi.json out of date: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	routes := API{}.Routes()
+	if err := CheckOpenAPI(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	// The check fails in both directions.
+	extra := append(routes[:len(routes):len(routes)], Route{http.MethodPut, "/api/status", nil})
+	if err := CheckOpenAPI(extra); err == nil || !strings.Contains(err.Error(), "undocumented route PUT /api/status") {
+		t.Errorf("undocumented route: %v", err)
+	}
+	var missing []Route
+	for _, rt := range routes {
+		if rt.Pattern() != "GET /api/best" {
+			missing = append(missing, rt)
+		}
+	}
+	if err := CheckOpenAPI(missing); err == nil || !strings.Contains(err.Error(), "without a route: GET /api/best") {
+		t.Errorf("documented operation without a route: %v", err)
+	}
+}
+
+func TestRoutesUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, rt := range (API{}).Routes() {
+		if seen[rt.Pattern()] {
+			t.Errorf("duplicate route %s", rt.Pattern())
+		}
+		seen[rt.Pattern()] = true
+		if rt.Handler == nil {
+			t.Errorf("%s has no handler", rt.Pattern())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import "net/http"
+
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.HandlerFunc
+}
+
+// Pattern is the ServeMux pattern for the route.
+func (rt Route) Pattern() string {
+	return rt.Method + " " + rt.Path
+}
+
+// Routes lists every API endpoint. openapi.json documents the same set;
+// CheckOpenAPI compares the two.
+func (a API) Routes() []Route {
+	return []Route{
+		{http.MethodGet, "/api/openapi.json", a.OpenAPI},
+		{http.MethodGet, "/api/status", a.Status},
+		{http.MethodGet, "/api/best", a.Best},
+		{http.MethodGet, "/api/history", a.History},
+		{http.MethodGet, "/api/diagnostics", a.Diagnostics},
+		{http.MethodGet, "/api/stream", a.Stream},
+		{http.MethodGet, "/api/ws", a.WebSocket},
+		{http.MethodPost, "/api/distance/calibrate", a.CalibrateDistance},
+		{http.MethodDelete, "/api/distance/calibrate", a.CalibrateDistance},
+		{http.MethodPost, "/api/heading", a.Heading},
+		{http.MethodGet, "/api/df", a.DirectionFinding},
+		{http.MethodDelete, "/api/df", a.DirectionFinding},
+		{http.MethodGet, "/api/hunt", a.Hunt},
+		{http.MethodDelete, "/api/hunt", a.Hunt},
+		{http.MethodGet, "/api/audio", a.Audio},
+		{http.MethodGet, "/api/audio/params", a.AudioParams},
+		{http.MethodGet, "/api/networks", a.Networks},
+		{http.MethodGet, "/api/gps", a.Position},
+		{http.MethodGet, "/api/locations", a.Locations},
+		{http.MethodGet, "/api/export", a.Export},
+		{http.MethodGet, "/api/alerts", a.Alerts},
+		{http.MethodGet, "/api/alerts/rogue", a.RogueAlerts},
+		{http.MethodDelete, "/api/alerts/rogue", a.RogueAlerts},
+		{http.MethodGet, "/metrics", a.Metrics},
+		{http.MethodGet, "/api/survey", a.ListSurveys},
+		{http.MethodPost, "/api/survey", a.CreateSurvey},
+		{http.MethodGet, "/api/survey/{id}", a.GetSurvey},
+		{http.MethodDelete, "/api/survey/{id}", a.DeleteSurvey},
+		{http.MethodGet, "/api/survey/{id}/floorplan", a.SurveyFloorplan},
+		{http.MethodGet, "/api/survey/{id}/bss", a.SurveyBSSes},
+		{http.MethodPost, "/api/survey/{id}/points", a.AddSurveyPoint},
+		{http.MethodDelete, "/api/survey/{id}/points/{point}", a.DeleteSurveyPoint},
+		{http.MethodGet, "/api/survey/{id}/heatmap", a.SurveyHeatmap},
+	}
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/alert"
+	"wifi-radar/internal/df"
+	"wifi-radar/internal/distance"
+	"wifi-radar/internal/export"
+	"wifi-radar/internal/gps"
+	"wifi-radar/internal/hunt"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/rogue"
+	"wifi-radar/internal/store"
+	"wifi-radar/internal/survey"
+)
+
+// schema is the subset of an OpenAPI schema object the spec uses.
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Properties map[string]*schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *schema            `json:"items"`
+	Enum       []any              `json:"enum"`
+	OneOf      []*schema          `json:"oneOf"`
+}
+
+type spec struct {
+	Paths map[string]struct {
+		Get *struct {
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema *schema `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"get"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var s spec
+	if err := json.Unmarshal(openAPISpec, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// validate checks v, decoded from JSON, against sc and returns one
+// message per mismatch: missing required properties, wrong types, values
+// outside an enum and properties the schema does not document.
+func (s spec) validate(sc *schema, v any, path string) []string {
+	if sc.Ref != "" {
+		name := strings.TrimPrefix(sc.Ref, "#/components/schemas/")
+		ref, ok := s.Components.Schemas[name]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown $ref %s", path, sc.Ref)}
+		}
+		return s.validate(ref, v, path)
+	}
+	if len(sc.OneOf) > 0 {
+		matches := 0
+		for _, alt := range sc.OneOf {
+			if len(s.validate(alt, v, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: matches %d oneOf alternatives, want 1", path, matches)}
+		}
+		return nil
+	}
+
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	switch sc.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("got %s, want object", jsonType(v))
+			return errs
+		}
+		for _, name := range sc.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		if sc.Properties == nil {
+			return errs
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := sc.Properties[name]
+			if !ok {
+				fail("undocumented property %q", name)
+				continue
+			}
+			errs = append(errs, s.validate(prop, obj[name], path+"."+name)...)
+		}
+	case "array":
+		list, ok := v.([]any)
+		if !ok {
+			fail("got %s, want array", jsonType(v))
+			return errs
+		}
+		for i, item := range list {
+			if sc.Items != nil {
+				errs = append(errs, s.validate(sc.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok {
+			fail("got %s, want integer", jsonType(v))
+		} else if n != math.Trunc(n) {
+			fail("got %v, want integer", n)
+		}
+	case "number", "string", "boolean":
+		if jsonType(v) != sc.Type {
+			fail("got %s, want %s", jsonType(v), sc.Type)
+		}
+	case "":
+	default:
+		fail("unsupported schema type %q", sc.Type)
+	}
+	if len(sc.Enum) > 0 {
+		found := false
+		for _, e := range sc.Enum {
+			found = found || e == v
+		}
+		if !found {
+			fail("%v is not one of %v", v, sc.Enum)
+		}
+	}
+	return errs
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func TestValidate(t *testing.T) {
+	s := loadSpec(t)
+	best := &schema{Ref: "#/components/schemas/Best"}
+	sample := `"ifname":"wlan0","ssid":"home","bssid":"aa","freq_mhz":2412,"signal_dbm":-50,"rx_mbps":0,"tx_mbps":0,"ts_unix_ms":1`
+	for _, tt := range []struct {
+		body string
+		want string
+	}{
+		{`{"sample":{` + sample + `},"score":80}`, ""},
+		{`{"sample":{` + sample + `}}`, `$: missing required property "score"`},
+		{`{"sample":{` + sample + `},"score":"80"}`, "$.score: got string, want integer"},
+		{`{"sample":{` + sample + `},"score":80.5}`, "$.score: got 80.5, want integer"},
+		{`{"sample":{` + sample + `,"extra":1},"score":80}`, `$.sample: undocumented property "extra"`},
+		{`{"sample":{"ifname":"wlan0"},"score":80}`, `$.sample: missing required property "ssid"`},
+		{`{"sample":null,"score":80}`, "$.sample: got null, want object"},
+		{`[]`, "$: got array, want object"},
+	} {
+		var v any
+		json.Unmarshal([]byte(tt.body), &v)
+		errs := strings.Join(s.validate(best, v, "$"), "; ")
+		if tt.want == "" && errs != "" || !strings.Contains(errs, tt.want) {
+			t.Errorf("%s: %q, want %q", tt.body, errs, tt.want)
+		}
+	}
+}
+
+// fakeGPSD serves one 3D fix to every client.
+func fakeGPSD(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// The client closes the connection when the test ends.
+			fmt.Fprintf(conn, `{"class":"TPV","mode":3,"lat":52.52,"lon":13.405,"altHAE":34.5,"eph":4.2,"speed":1.5}`+"\n")
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// seededAPI returns an API with every optional component set and fed
+// enough data for each GET route to answer 200.
+func seededAPI(t *testing.T) API {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gpsClient := &gps.Client{Addr: fakeGPSD(t)}
+	go gpsClient.Run(ctx)
+
+	surveys, err := survey.Open(t.TempDir(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { surveys.Close() })
+	rules, err := alert.NewEngine([]alert.Rule{
+		{Name: "weak", When: "signal_dbm < -40"},
+		{Name: "new", When: "new bss"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := API{
+		Store:     store.New(5),
+		Distance:  &distance.Estimator{},
+		DF:        &df.Finder{Smoothing: 1},
+		Hunter:    &hunt.Tracker{},
+		Surveys:   surveys,
+		GPS:       gpsClient,
+		Locator:   &gps.Estimator{},
+		Inventory: &export.Inventory{},
+		Rogue: &rogue.Detector{Allowlist: rogue.Allowlist{Networks: []rogue.Network{
+			{SSID: "home", BSSIDs: []string{"aa:bb:cc:dd:ee:01"}},
+		}}},
+		Rules: rules,
+	}
+	a.Store.SetDecorator(a.Decorate)
+	a.Store.SetLocator(gpsClient.Location)
+	a.Store.Observe(a.DF.Observe)
+	a.Store.Observe(a.Hunter.Observe)
+	a.Store.Observe(rules.Observe)
+	a.Store.ObserveNetworks(surveys.ObserveNetworks)
+	a.Store.ObserveNetworks(a.Locator.Observe)
+	a.Store.ObserveNetworks(a.Inventory.Observe)
+	a.Store.ObserveNetworks(a.Rogue.Observe)
+	a.Store.ObserveNetworks(rules.ObserveNetworks)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for gpsClient.Location() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("no fix from the fake gpsd")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.Black)
+	png.Encode(&buf, img)
+	project, err := surveys.Create("office", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Turn a full circle past the AP, one scan per step, clicking survey
+	// points along the way.
+	for deg := 0.0; deg < 360; deg += 10 {
+		a.DF.SetHeading(deg)
+		dbm := int(math.Round(-60 + 10*math.Cos((deg-90)*math.Pi/180)))
+		now := model.NowUnixMS()
+		networks := []model.Sample{
+			{IfName: "wlan0", BSSID: "aa:bb:cc:dd:ee:01", SSID: "home", Security: "WPA2-PSK", FreqMHz: 2437, SignalDBM: dbm, TimestampUnixM: now},
+			// Another BSS with the protected SSID raises a rogue alert.
+			{IfName: "wlan0", BSSID: "aa:bb:cc:dd:ee:99", SSID: "home", Security: "OPEN", FreqMHz: 2412, SignalDBM: -70, TimestampUnixM: now},
+		}
+		a.Store.UpdateNetworks("wlan0", networks)
+		a.Store.Update(model.Sample{IfName: "wlan0", BSSID: "aa:bb:cc:dd:ee:01", SSID: "home", FreqMHz: 2437,
+			SignalDBM: dbm, TimestampUnixM: now})
+		if int(deg)%90 == 0 {
+			if _, err := surveys.AddPoint(project.ID, deg/10, deg/20); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return a
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	s := loadSpec(t)
+	a := seededAPI(t)
+	mux := http.NewServeMux()
+	for _, rt := range a.Routes() {
+		mux.HandleFunc(rt.Pattern(), rt.Handler)
+	}
+	surveys := a.Surveys.List()
+	if len(surveys) != 1 {
+		t.Fatalf("surveys = %+v", surveys)
+	}
+	id := surveys[0].ID
+	// Concrete URLs for routes with path parameters or required queries.
+	urls := map[string]string{
+		"/api/survey/{id}":         "/api/survey/" + id,
+		"/api/survey/{id}/bss":     "/api/survey/" + id + "/bss",
+		"/api/survey/{id}/heatmap": "/api/survey/" + id + "/heatmap?bssid=aa:bb:cc:dd:ee:01&format=json",
+		"/api/diagnostics":         "/api/diagnostics?ifname=wlan0",
+	}
+
+	checked := 0
+	for _, rt := range a.Routes() {
+		if rt.Method != http.MethodGet {
+			continue
+		}
+		op := s.Paths[rt.Path].Get
+		if op == nil {
+			t.Errorf("GET %s is not documented", rt.Path)
+			continue
+		}
+		media, ok := op.Responses["200"].Content["application/json"]
+		if !ok || media.Schema == nil {
+			continue
+		}
+		url := rt.Path
+		if u, ok := urls[rt.Path]; ok {
+			url = u
+		}
+		t.Run(strings.TrimPrefix(rt.Path, "/"), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s: %d %s", url, w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var v any
+			if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+				t.Fatal(err)
+			}
+			if list, ok := v.([]any); ok && len(list) == 0 {
+				t.Errorf("GET %s returned an empty list; seed it so its items are checked", url)
+			}
+			for _, e := range s.validate(media.Schema, v, "$") {
+				t.Error(e)
+			}
+		})
+		checked++
+	}
+	if checked < 15 {
+		t.Errorf("checked %d JSON routes", checked)
+	}
+}
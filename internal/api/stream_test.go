@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/hunt"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+// sseMessage is one message of an event stream; fields missing from it
+// are empty.
+type sseMessage struct {
+	id, event, data string
+}
+
+// readSSE returns the next n messages of the stream at url.
+func readSSE(t *testing.T, url string, header http.Header, n int) []sseMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+
+	var (
+		out []sseMessage
+		msg sseMessage
+	)
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 1<<20)
+	for len(out) < n && sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if msg != (sseMessage{}) {
+				out = append(out, msg)
+			}
+			msg = sseMessage{}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			msg.id = value
+		case "event":
+			msg.event = value
+		case "data":
+			msg.data = value
+		}
+	}
+	if len(out) < n {
+		t.Fatalf("stream ended after %d messages (%v), want %d", len(out), sc.Err(), n)
+	}
+	return out
+}
+
+func TestStreamHuntID(t *testing.T) {
+	st := store.New(10)
+	a := API{Store: st, Hunter: &hunt.Tracker{}}
+	st.Observe(a.Hunter.Observe)
+	srv := httptest.NewServer(http.HandlerFunc(a.Stream))
+	defer srv.Close()
+
+	now := model.NowUnixMS()
+	for i := 0; i < 3; i++ {
+		st.Update(model.Sample{IfName: "wlan0", BSSID: "aa:bb", SignalDBM: -60 + i, TimestampUnixM: now + int64(i)*100})
+	}
+
+	// A fresh client gets the latest status followed by its hunt.
+	msgs := readSSE(t, srv.URL, nil, 2)
+	if msgs[0].event != "status" || msgs[1].event != "hunt" {
+		t.Fatalf("events = %q, %q; want status, hunt", msgs[0].event, msgs[1].event)
+	}
+	if msgs[0].id == "" || msgs[1].id != msgs[0].id {
+		t.Errorf("hunt id = %q, want the status id %q", msgs[1].id, msgs[0].id)
+	}
+	if !strings.Contains(msgs[1].data, `"signal_dbm":-58`) {
+		t.Errorf("hunt data = %s", msgs[1].data)
+	}
+
+	// A resuming client gets the newest status since its id, with the
+	// hunt under the same id, and then live updates.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		st.Update(model.Sample{IfName: "wlan0", BSSID: "aa:bb", SignalDBM: -57, TimestampUnixM: now + 300})
+	}()
+	msgs = readSSE(t, srv.URL, http.Header{"Last-Event-Id": {"1"}}, 4)
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.id+" "+m.event)
+	}
+	if want := "3 status,3 hunt,4 status,4 hunt"; strings.Join(got, ",") != want {
+		t.Errorf("messages = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	// ?types= can leave the hunt out.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		st.Update(model.Sample{IfName: "wlan0", BSSID: "aa:bb", SignalDBM: -56, TimestampUnixM: now + 400})
+	}()
+	msgs = readSSE(t, srv.URL+"?types=status", nil, 2)
+	if msgs[0].event != "status" || msgs[1].event != "status" || msgs[1].id != "5" {
+		t.Errorf("messages with types=status = %+v", msgs)
+	}
+}
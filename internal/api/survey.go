@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"strconv"
+
+	"wifi-radar/internal/survey"
+)
+
+const maxUpload = 40 << 20
+
+func (a API) ListSurveys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.Surveys.List())
+}
+
+func (a API) CreateSurvey(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	if err := r.ParseMultipartForm(maxUpload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("floorplan")
+	if err != nil {
+		http.Error(w, "floorplan file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	project, err := a.Surveys.Create(r.FormValue("name"), file)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, survey.ErrBadFloorplan) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, project)
+}
+
+func (a API) GetSurvey(w http.ResponseWriter, r *http.Request) {
+	project, err := a.Surveys.Get(r.PathValue("id"))
+	if err != nil {
+		surveyError(w, err)
+		return
+	}
+	writeJSON(w, project)
+}
+
+func (a API) DeleteSurvey(w http.ResponseWriter, r *http.Request) {
+	if err := a.Surveys.Delete(r.PathValue("id")); err != nil {
+		surveyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a API) SurveyFloorplan(w http.ResponseWriter, r *http.Request) {
+	path, err := a.Surveys.FloorplanPath(r.PathValue("id"))
+	if err != nil {
+		surveyError(w, err)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+func (a API) SurveyBSSes(w http.ResponseWriter, r *http.Request) {
+	bsses, err := a.Surveys.BSSes(r.PathValue("id"))
+	if err != nil {
+		surveyError(w, err)
+		return
+	}
+	writeJSON(w, bsses)
+}
+
+func (a API) AddSurveyPoint(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		X *float64 `json:"x"`
+		Y *float64 `json:"y"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.X == nil || req.Y == nil {
+		http.Error(w, "x and y are required", http.StatusBadRequest)
+		return
+	}
+
+	point, err := a.Surveys.AddPoint(r.PathValue("id"), *req.X, *req.Y)
+	if err != nil {
+		surveyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, point)
+}
+
+func (a API) DeleteSurveyPoint(w http.ResponseWriter, r *http.Request) {
+	pointID, err := strconv.Atoi(r.PathValue("point"))
+	if err != nil {
+		http.Error(w, "invalid point id", http.StatusBadRequest)
+		return
+	}
+	if err := a.Surveys.DeletePoint(r.PathValue("id"), pointID); err != nil {
+		surveyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a API) SurveyHeatmap(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	project, err := a.Surveys.Get(id)
+	if err != nil {
+		surveyError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	opts := survey.HeatmapOptions{
+		BSSID:    q.Get("bssid"),
+		Method:   q.Get("method"),
+		Metric:   q.Get("metric"),
+		NoiseDBM: a.NoiseDBM,
+	}
+	if opts.BSSID == "" {
+		http.Error(w, "bssid is required", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("cell"); v != "" {
+		if opts.Cell, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid cell", http.StatusBadRequest)
+			return
+		}
+	}
+
+	grid, err := survey.Heatmap(project, opts)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, survey.ErrNoSamples) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if q.Get("format") == "json" {
+		writeJSON(w, grid)
+		return
+	}
+
+	var floorplan image.Image
+	if q.Get("overlay") == "1" {
+		path, err := a.Surveys.FloorplanPath(id)
+		if err == nil {
+			floorplan, err = decodeImage(path)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("load floor plan: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := survey.RenderPNG(&buf, grid, floorplan); err != nil {
+		http.Error(w, fmt.Sprintf("render heatmap: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(buf.Bytes())
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+func surveyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, survey.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
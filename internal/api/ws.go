@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/auth"
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/ws"
+)
+
+type wsCommand struct {
+	ID      string   `json:"id,omitempty"`
+	Type    string   `json:"type"`
+	BSSIDs  []string `json:"bssids,omitempty"`
+	IfName  string   `json:"ifname,omitempty"`
+	SSID    string   `json:"ssid,omitempty"`
+	BSSID   string   `json:"bssid,omitempty"`
+	Samples int      `json:"samples,omitempty"`
+
+	parseErr error
+}
+
+type wsReply struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Command string `json:"command"`
+	Error   string `json:"error,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// WebSocket streams the same status and events as Stream, with the same
+// query parameters, and accepts commands: subscribe, set_target, rescan
+// and set_smoothing.
+func (a API) WebSocket(w http.ResponseWriter, r *http.Request) {
+	st, err := newStreamState(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer st.stop()
+
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	commands := make(chan wsCommand)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			var cmd wsCommand
+			if err := json.Unmarshal(msg, &cmd); err != nil {
+				cmd = wsCommand{parseErr: err}
+			}
+			select {
+			case commands <- cmd:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	cursor := a.Store.LastEventID()
+	if ev, ok := a.Store.LatestEvent(model.EventStatus); ok && st.throttle(ev, time.Now()) {
+		if err := a.sendEvent(conn, st, ev); err != nil {
+			return
+		}
+	}
+	reader := a.Store.Follow(cursor)
+	defer reader.Close()
+
+	ping := time.NewTicker(20 * time.Second)
+	defer ping.Stop()
+
+	for {
+		events, missed, wait := reader.Next()
+		if missed {
+			st.reset()
+			if err := conn.WriteText([]byte(`{"type":"reset"}`)); err != nil {
+				return
+			}
+		}
+		now := time.Now()
+		for _, ev := range events {
+			if !st.throttle(ev, now) {
+				continue
+			}
+			if err := a.sendEvent(conn, st, ev); err != nil {
+				return
+			}
+		}
+
+		var err error
+		select {
+		case <-r.Context().Done():
+			_ = conn.WriteClose(ws.CloseGoingAway, "")
+			return
+		case err = <-readErr:
+			if !errors.Is(err, ws.ErrClosed) {
+				log.Printf("ws: %v", err)
+			}
+			return
+		case <-wait:
+		case <-st.due():
+			err = a.sendEvent(conn, st, st.release(time.Now()))
+		case cmd := <-commands:
+			var payload []byte
+			payload, err = json.Marshal(a.handleCommand(cmd, &st.filter, auth.FromContext(r.Context())))
+			if err == nil {
+				err = conn.WriteText(payload)
+			}
+		case <-ping.C:
+			err = conn.Ping()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// sendEvent writes ev as {"type": ..., "data": ...}, reusing the payload
+// the store encoded when possible.
+func (a API) sendEvent(conn *ws.Conn, st *streamState, ev model.Event) error {
+	typ, payload, ok := st.encode(ev)
+	if !ok {
+		return nil
+	}
+	if err := conn.WriteText(wsFrame(typ, payload)); err != nil {
+		return err
+	}
+	if ev.Type == model.EventStatus && a.Hunter != nil && st.filter.wants("hunt") {
+		if snapshot, ok := a.Hunter.Snapshot(); ok {
+			payload, err := json.Marshal(snapshot)
+			if err != nil {
+				return err
+			}
+			return conn.WriteText(wsFrame("hunt", payload))
+		}
+	}
+	return nil
+}
+
+func wsFrame(typ string, payload []byte) []byte {
+	frame := make([]byte, 0, len(payload)+len(typ)+20)
+	frame = append(frame, `{"type":"`...)
+	frame = append(frame, typ...)
+	frame = append(frame, `","data":`...)
+	frame = append(frame, payload...)
+	return append(frame, '}')
+}
+
+func (a API) handleCommand(cmd wsCommand, filter *streamFilter, role auth.Role) wsReply {
+	reply := wsReply{Type: "ack", ID: cmd.ID, Command: cmd.Type}
+	fail := func(format string, args ...any) wsReply {
+		reply.Type = "error"
+		reply.Error = fmt.Sprintf(format, args...)
+		return reply
+	}
+
+	if cmd.parseErr != nil {
+		return fail("invalid command: %v", cmd.parseErr)
+	}
+	if cmd.Type != "subscribe" && role < auth.Admin {
+		return fail("%s needs the admin role", cmd.Type)
+	}
+	switch cmd.Type {
+	case "subscribe":
+		filter.bssids = splitSet(strings.Join(cmd.BSSIDs, ","), true)
+		reply.Data = cmd.BSSIDs
+	case "set_target":
+		if a.Scanner == nil {
+			return fail("changing the target needs scan mode")
+		}
+		if cmd.SSID == "" && cmd.BSSID == "" {
+			return fail("set_target needs ssid or bssid")
+		}
+		reply.Data = a.SetTarget(cmd.SSID, cmd.BSSID)
+	case "rescan":
+		if a.Rescan == nil {
+			return fail("rescan is not available")
+		}
+		a.Rescan()
+	case "set_smoothing":
+		if cmd.Samples < 1 || cmd.Samples > 1000 {
+			return fail("samples must be between 1 and 1000")
+		}
+		a.Store.SetSmoothing(cmd.Samples)
+		reply.Data = cmd.Samples
+	default:
+		return fail("unknown command %q", cmd.Type)
+	}
+	return reply
+}
+
+// SetTarget switches the scanner to another network, publishes the
+// change as a target event and asks for a scan right away. Scanner must
+// be set.
+func (a API) SetTarget(ssid, bssid string) model.Target {
+	target := collector.ScanTarget{SSID: ssid, BSSID: strings.ToLower(bssid)}
+	a.Scanner.SetTarget(target)
+	changed := model.Target{IfName: a.Scanner.IfName, SSID: target.SSID, BSSID: target.BSSID}
+	a.Store.Publish(model.Event{Type: model.EventTarget, Data: changed})
+	if a.Rescan != nil {
+		a.Rescan()
+	}
+	return changed
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"testing"
+
+	"wifi-radar/internal/auth"
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+func TestSetTargetCommand(t *testing.T) {
+	rescans := 0
+	scanner := &collector.ScanCollector{IfName: "wlan0", Target: collector.ScanTarget{SSID: "home"}}
+	a := API{Store: store.New(1), Scanner: scanner, Rescan: func() { rescans++ }}
+
+	cmd := wsCommand{ID: "1", Type: "set_target", SSID: "cafe", BSSID: "AA:BB:CC:DD:EE:01"}
+	reply := a.handleCommand(cmd, &streamFilter{}, auth.Read)
+	if reply.Type != "error" || scanner.CurrentTarget().SSID != "home" {
+		t.Errorf("read role: reply %+v, target %+v", reply, scanner.CurrentTarget())
+	}
+
+	reply = a.handleCommand(cmd, &streamFilter{}, auth.Admin)
+	want := model.Target{IfName: "wlan0", SSID: "cafe", BSSID: "aa:bb:cc:dd:ee:01"}
+	if reply.Type != "ack" || reply.Data != want {
+		t.Errorf("reply %+v, want data %+v", reply, want)
+	}
+	if got := scanner.CurrentTarget(); got != (collector.ScanTarget{SSID: "cafe", BSSID: "aa:bb:cc:dd:ee:01"}) {
+		t.Errorf("scanner target %+v", got)
+	}
+	if ev, ok := a.Store.LatestEvent(model.EventTarget); !ok || ev.Data != want {
+		t.Errorf("target event %+v, %v", ev, ok)
+	}
+	if rescans != 1 {
+		t.Errorf("%d rescans, want 1", rescans)
+	}
+
+	if reply := a.handleCommand(wsCommand{Type: "set_target"}, &streamFilter{}, auth.Admin); reply.Error != "set_target needs ssid or bssid" {
+		t.Errorf("empty target: %+v", reply)
+	}
+	a.Scanner = nil
+	if reply := a.handleCommand(cmd, &streamFilter{}, auth.Admin); reply.Error != "changing the target needs scan mode" {
+		t.Errorf("link mode: %+v", reply)
+	}
+}
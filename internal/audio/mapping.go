@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	ModeTone  = "tone"
+	ModeClick = "click"
+
+	CurveLinear = "linear"
+	CurveExp    = "exp"
+	CurveLog    = "log"
+
+	// Steepness of the exp/log curves.
+	curveK = 3.0
+)
+
+type Mapping struct {
+	Mode      string
+	Curve     string
+	MinDBM    float64
+	MaxDBM    float64
+	MinHz     float64
+	MaxHz     float64
+	MinRate   float64
+	MaxRate   float64
+	Gain      float64
+	LostAfter time.Duration
+}
+
+func DefaultMapping() Mapping {
+	return Mapping{
+		Mode:      ModeTone,
+		Curve:     CurveLinear,
+		MinDBM:    -90,
+		MaxDBM:    -30,
+		MinHz:     220,
+		MaxHz:     1760,
+		MinRate:   1,
+		MaxRate:   30,
+		Gain:      0.5,
+		LostAfter: 3 * time.Second,
+	}
+}
+
+func (m Mapping) Validate() error {
+	switch m.Mode {
+	case ModeTone, ModeClick:
+	default:
+		return fmt.Errorf("invalid audio mode %q (use tone or click)", m.Mode)
+	}
+	switch m.Curve {
+	case CurveLinear, CurveExp, CurveLog:
+	default:
+		return fmt.Errorf("invalid audio curve %q (use linear, exp or log)", m.Curve)
+	}
+	if m.MaxDBM <= m.MinDBM {
+		return fmt.Errorf("audio max dBm (%.0f) must be above min dBm (%.0f)", m.MaxDBM, m.MinDBM)
+	}
+	if m.MinHz <= 0 || m.MaxHz <= m.MinHz {
+		return fmt.Errorf("invalid audio tone range %.0f-%.0f Hz", m.MinHz, m.MaxHz)
+	}
+	if m.MinRate <= 0 || m.MaxRate <= m.MinRate {
+		return fmt.Errorf("invalid audio click range %.1f-%.1f/s", m.MinRate, m.MaxRate)
+	}
+	return nil
+}
+
+// WithQuery returns a copy of m with overrides from URL query parameters
+// (mode, curve, min_dbm, max_dbm, gain).
+func (m Mapping) WithQuery(q url.Values) (Mapping, error) {
+	if v := q.Get("mode"); v != "" {
+		m.Mode = v
+	}
+	if v := q.Get("curve"); v != "" {
+		m.Curve = v
+	}
+	for key, dst := range map[string]*float64{
+		"min_dbm": &m.MinDBM,
+		"max_dbm": &m.MaxDBM,
+		"gain":    &m.Gain,
+	} {
+		v := q.Get(key)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return m, fmt.Errorf("invalid %s: %q", key, v)
+		}
+		*dst = f
+	}
+	return m, m.Validate()
+}
+
+// Params maps a smoothed sample to sound parameters. The output is muted
+// when there is no sample, the target is lost or the sample is stale.
+func (m Mapping) Params(sample model.Sample, ok bool) model.AudioParams {
+	out := model.AudioParams{
+		Mode:  m.Mode,
+		Curve: m.Curve,
+		Muted: true,
+	}
+	if !ok || sample.Lost || sample.SignalDBM == 0 {
+		return out
+	}
+	if m.LostAfter > 0 && model.NowUnixMS()-sample.TimestampUnixM > m.LostAfter.Milliseconds() {
+		return out
+	}
+
+	level := m.level(float64(sample.SignalDBM))
+	out.Muted = false
+	out.SignalDBM = sample.SignalDBM
+	out.Level = math.Round(level*1000) / 1000
+	// Pitch is spaced exponentially so equal signal steps sound like equal
+	// musical intervals.
+	out.ToneHz = math.Round(m.MinHz * math.Pow(m.MaxHz/m.MinHz, level))
+	out.ClickRate = math.Round((m.MinRate+(m.MaxRate-m.MinRate)*level)*10) / 10
+	out.Gain = math.Max(0, math.Min(1, m.Gain))
+	return out
+}
+
+func (m Mapping) level(dbm float64) float64 {
+	x := (dbm - m.MinDBM) / (m.MaxDBM - m.MinDBM)
+	x = math.Max(0, math.Min(1, x))
+	switch m.Curve {
+	case CurveExp:
+		// More resolution near the strong end, for the last few metres.
+		return (math.Exp(curveK*x) - 1) / (math.Exp(curveK) - 1)
+	case CurveLog:
+		// More resolution near the weak end, for picking up a faint AP.
+		return math.Log1p(curveK*x) / math.Log1p(curveK)
+	default:
+		return x
+	}
+}
@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	SampleRate = 16000
+
+	chunk       = 50 * time.Millisecond
+	clickLength = SampleRate / 500
+	// Short fades keep parameter changes and mutes from popping.
+	rampSamples = SampleRate / 200
+)
+
+// StreamWAV writes an endless 16-bit mono WAV stream to w in real time,
+// asking params for the current sound parameters before every chunk. It
+// returns when ctx is done or a write fails.
+func StreamWAV(ctx context.Context, w io.Writer, params func() model.AudioParams, flush func()) error {
+	if err := writeHeader(w); err != nil {
+		return err
+	}
+
+	synth := &synth{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	perChunk := int(SampleRate * chunk / time.Second)
+	buf := make([]byte, perChunk*2)
+
+	ticker := time.NewTicker(chunk)
+	defer ticker.Stop()
+
+	for {
+		synth.render(buf, params())
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		if flush != nil {
+			flush()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeHeader writes a RIFF header with maximal sizes, which players treat
+// as a stream of unknown length.
+func writeHeader(w io.Writer) error {
+	const unknown = 0xFFFFFFFF
+	header := make([]byte, 0, 44)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, unknown)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, 1) // PCM
+	header = binary.LittleEndian.AppendUint16(header, 1) // mono
+	header = binary.LittleEndian.AppendUint32(header, SampleRate)
+	header = binary.LittleEndian.AppendUint32(header, SampleRate*2)
+	header = binary.LittleEndian.AppendUint16(header, 2)
+	header = binary.LittleEndian.AppendUint16(header, 16)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, unknown)
+	_, err := w.Write(header)
+	return err
+}
+
+type synth struct {
+	rng       *rand.Rand
+	phase     float64
+	gain      float64
+	clickLeft int
+}
+
+func (s *synth) render(buf []byte, p model.AudioParams) {
+	target := p.Gain
+	if p.Muted {
+		target = 0
+	}
+	step := 1.0 / rampSamples
+
+	for i := 0; i < len(buf)/2; i++ {
+		switch {
+		case s.gain < target:
+			s.gain = math.Min(target, s.gain+step)
+		case s.gain > target:
+			s.gain = math.Max(target, s.gain-step)
+		}
+
+		var v float64
+		if p.Mode == ModeClick {
+			v = s.click(p)
+		} else {
+			v = math.Sin(s.phase)
+			s.phase += 2 * math.Pi * p.ToneHz / SampleRate
+			if s.phase > 2*math.Pi {
+				s.phase -= 2 * math.Pi
+			}
+		}
+
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(int16(v*s.gain*math.MaxInt16)))
+	}
+}
+
+// click produces Geiger-counter style clicks: a Poisson process with the
+// given mean rate, each click a short decaying burst of noise.
+func (s *synth) click(p model.AudioParams) float64 {
+	if s.clickLeft == 0 && !p.Muted && s.rng.Float64() < p.ClickRate/SampleRate {
+		s.clickLeft = clickLength
+	}
+	if s.clickLeft == 0 {
+		return 0
+	}
+	envelope := float64(s.clickLeft) / clickLength
+	s.clickLeft--
+	return (s.rng.Float64()*2 - 1) * envelope
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Role int
+
+const (
+	None Role = iota
+	// Read may view status, streams and exports.
+	Read
+	// Admin may also change state: the target, smoothing, calibration,
+	// surveys and alerts.
+	Admin
+)
+
+func (r Role) String() string {
+	switch r {
+	case Read:
+		return "read"
+	case Admin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// Cookie carries a token given as ?access_token= to later requests from
+// the same browser, so the UI's fetch, EventSource and WebSocket calls
+// are authenticated without the page having to add headers.
+const Cookie = "wifi_radar_token"
+
+type credential struct {
+	hash [32]byte
+	role Role
+}
+
+// Authenticator checks bearer tokens and basic-auth users. With nothing
+// configured every request is treated as admin.
+type Authenticator struct {
+	// Secure marks the token cookie Secure; set it when serving TLS.
+	Secure bool
+
+	tokens []credential
+	users  map[string]credential
+
+	mu     sync.Mutex
+	logins map[string]login
+}
+
+type login struct {
+	token   string
+	expires time.Time
+}
+
+func (a *Authenticator) AddToken(token string, role Role) {
+	a.tokens = append(a.tokens, credential{hash: sha256.Sum256([]byte(token)), role: role})
+}
+
+// AddUser adds a basic-auth user given as "name:password".
+func (a *Authenticator) AddUser(spec string, role Role) error {
+	name, password, ok := strings.Cut(spec, ":")
+	if !ok || name == "" || password == "" {
+		return fmt.Errorf("invalid user %q (use name:password)", spec)
+	}
+	if a.users == nil {
+		a.users = make(map[string]credential)
+	}
+	a.users[name] = credential{hash: sha256.Sum256([]byte(password)), role: role}
+	return nil
+}
+
+// IssueLogin returns a random code that ?login= exchanges once, within
+// ttl, for the token cookie. Unlike ?access_token= it is safe to put in a
+// URL that ends up in a process list or browser history.
+func (a *Authenticator) IssueLogin(token string, ttl time.Duration) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b[:])
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.logins == nil {
+		a.logins = make(map[string]login)
+	}
+	for c, l := range a.logins {
+		if now.After(l.expires) {
+			delete(a.logins, c)
+		}
+	}
+	a.logins[code] = login{token: token, expires: now.Add(ttl)}
+	return code, nil
+}
+
+// redeem returns the token for a login code and forgets the code.
+func (a *Authenticator) redeem(code string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l, ok := a.logins[code]
+	delete(a.logins, code)
+	if !ok || time.Now().After(l.expires) {
+		return "", false
+	}
+	return l.token, true
+}
+
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0 || len(a.users) > 0
+}
+
+// Authenticate returns the role of the credentials on r: an Authorization
+// header (Bearer or Basic), the access_token query parameter or the token
+// cookie.
+func (a *Authenticator) Authenticate(r *http.Request) Role {
+	if !a.Enabled() {
+		return Admin
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		if c, ok := a.users[name]; ok && match(c.hash, password) {
+			return c.role
+		}
+		return None
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.tokenRole(token)
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return a.tokenRole(token)
+	}
+	if c, err := r.Cookie(Cookie); err == nil {
+		return a.tokenRole(c.Value)
+	}
+	return None
+}
+
+func (a *Authenticator) tokenRole(token string) Role {
+	role := None
+	for _, c := range a.tokens {
+		if match(c.hash, token) && c.role > role {
+			role = c.role
+		}
+	}
+	return role
+}
+
+// match compares hashes so the comparison time does not depend on the
+// secret's length or content.
+func match(hash [32]byte, secret string) bool {
+	sum := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], sum[:]) == 1
+}
+
+// Middleware requires Read for GET, HEAD and OPTIONS and Admin for every
+// other method. The role is stored in the request context for handlers
+// that need finer checks, e.g. WebSocket commands. A valid ?login= code
+// sets the token cookie and redirects to the same URL without the code.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := r.URL.Query().Get("login"); code != "" {
+			if token, ok := a.redeem(code); ok {
+				a.setCookie(w, token)
+				q := r.URL.Query()
+				q.Del("login")
+				u := *r.URL
+				u.RawQuery = q.Encode()
+				http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+				return
+			}
+		}
+
+		role := a.Authenticate(r)
+		need := Admin
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			need = Read
+		}
+		if role == None {
+			if len(a.users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="wifi-radar", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="wifi-radar"`)
+			}
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if role < need {
+			http.Error(w, "admin role required", http.StatusForbidden)
+			return
+		}
+		if token := r.URL.Query().Get("access_token"); token != "" && a.Enabled() {
+			a.setCookie(w, token)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+func (a *Authenticator) setCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     Cookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   a.Secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+type roleKey struct{}
+
+// FromContext returns the role Middleware stored, or Admin when the
+// request did not pass through it.
+func FromContext(ctx context.Context) Role {
+	if role, ok := ctx.Value(roleKey{}).(Role); ok {
+		return role
+	}
+	return Admin
+}
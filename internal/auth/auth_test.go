@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuth(t *testing.T) (*Authenticator, http.Handler) {
+	t.Helper()
+	a := &Authenticator{}
+	a.AddToken("admin-token", Admin)
+	a.AddToken("read-token", Read)
+	if err := a.AddUser("alice:wonderland", Admin); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddUser("bob:builder", Read); err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).String()))
+	})
+	return a, a.Middleware(next)
+}
+
+func serve(h http.Handler, method, target string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if setup != nil {
+		setup(r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func bearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func basic(user, password string) func(*http.Request) {
+	return func(r *http.Request) { r.SetBasicAuth(user, password) }
+}
+
+func TestRoles(t *testing.T) {
+	_, h := newTestAuth(t)
+	for _, tt := range []struct {
+		name   string
+		setup  func(*http.Request)
+		method string
+		status int
+	}{
+		{"read GET", bearer("read-token"), http.MethodGet, http.StatusOK},
+		{"read HEAD", bearer("read-token"), http.MethodHead, http.StatusOK},
+		{"read OPTIONS", bearer("read-token"), http.MethodOptions, http.StatusOK},
+		{"read POST", bearer("read-token"), http.MethodPost, http.StatusForbidden},
+		{"read PUT", bearer("read-token"), http.MethodPut, http.StatusForbidden},
+		{"read DELETE", bearer("read-token"), http.MethodDelete, http.StatusForbidden},
+		{"admin GET", bearer("admin-token"), http.MethodGet, http.StatusOK},
+		{"admin POST", bearer("admin-token"), http.MethodPost, http.StatusOK},
+		{"admin DELETE", bearer("admin-token"), http.MethodDelete, http.StatusOK},
+		{"wrong token", bearer("admin-token2"), http.MethodGet, http.StatusUnauthorized},
+		{"no credentials", nil, http.MethodGet, http.StatusUnauthorized},
+		{"basic read GET", basic("bob", "builder"), http.MethodGet, http.StatusOK},
+		{"basic read POST", basic("bob", "builder"), http.MethodPost, http.StatusForbidden},
+		{"basic admin PATCH", basic("alice", "wonderland"), http.MethodPatch, http.StatusOK},
+		{"basic wrong password", basic("alice", "builder"), http.MethodGet, http.StatusUnauthorized},
+		{"basic unknown user", basic("carol", "wonderland"), http.MethodGet, http.StatusUnauthorized},
+		// A failed basic login does not fall through to other credentials.
+		{"basic with a token cookie", func(r *http.Request) {
+			r.SetBasicAuth("alice", "nope")
+			r.AddCookie(&http.Cookie{Name: Cookie, Value: "admin-token"})
+		}, http.MethodGet, http.StatusUnauthorized},
+		{"cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: Cookie, Value: "read-token"}) }, http.MethodGet, http.StatusOK},
+	} {
+		w := serve(h, tt.method, "/api/status", tt.setup)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
+
+func TestContextRole(t *testing.T) {
+	_, h := newTestAuth(t)
+	if got := serve(h, http.MethodGet, "/", bearer("read-token")).Body.String(); got != "read" {
+		t.Errorf("role = %q, want read", got)
+	}
+	if got := serve(h, http.MethodGet, "/", basic("alice", "wonderland")).Body.String(); got != "admin" {
+		t.Errorf("role = %q, want admin", got)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	_, h := newTestAuth(t)
+	if got := serve(h, http.MethodGet, "/", nil).Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("challenge with users = %q, want Basic", got)
+	}
+
+	tokensOnly := &Authenticator{}
+	tokensOnly.AddToken("t", Read)
+	w := serve(tokensOnly.Middleware(http.NotFoundHandler()), http.MethodGet, "/", nil)
+	if got := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(got, "Bearer ") {
+		t.Errorf("challenge with tokens only = %q, want Bearer", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	a := &Authenticator{}
+	if a.Enabled() {
+		t.Fatal("empty authenticator is enabled")
+	}
+	w := serve(a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).String()))
+	})), http.MethodPost, "/?access_token=x", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "admin" {
+		t.Errorf("without auth: %d %q, want admin", w.Code, w.Body.String())
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("cookie set with auth disabled")
+	}
+}
+
+func TestQueryTokenCookie(t *testing.T) {
+	a, h := newTestAuth(t)
+	a.Secure = true
+
+	w := serve(h, http.MethodGet, "/?access_token=read-token", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	c := cookies[0]
+	if c.Name != Cookie || c.Value != "read-token" || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode || c.Path != "/" {
+		t.Errorf("cookie = %+v", c)
+	}
+
+	// The cookie alone authenticates the next request.
+	w = serve(h, http.MethodGet, "/api/stream", func(r *http.Request) { r.AddCookie(c) })
+	if w.Code != http.StatusOK || w.Body.String() != "read" {
+		t.Errorf("with the cookie: %d %q", w.Code, w.Body.String())
+	}
+
+	// A bad query token gets no cookie.
+	w = serve(h, http.MethodGet, "/?access_token=guess", nil)
+	if w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("bad token: %d, cookies %v", w.Code, w.Result().Cookies())
+	}
+	// Nor does a token that lacks the role for the request.
+	w = serve(h, http.MethodPost, "/?access_token=read-token", nil)
+	if w.Code != http.StatusForbidden || len(w.Result().Cookies()) != 0 {
+		t.Errorf("forbidden: %d, cookies %v", w.Code, w.Result().Cookies())
+	}
+}
+
+func TestLoginCode(t *testing.T) {
+	a, h := newTestAuth(t)
+	code, err := a.IssueLogin("admin-token", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(code, "admin-token") || len(code) != 32 {
+		t.Errorf("code = %q", code)
+	}
+
+	w := serve(h, http.MethodGet, "/survey.html?login="+code+"&x=1", nil)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/survey.html?x=1" {
+		t.Fatalf("redeem: %d to %q, want 303 to /survey.html?x=1", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != "admin-token" || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	w = serve(h, http.MethodPost, "/api/target", func(r *http.Request) { r.AddCookie(cookies[0]) })
+	if w.Code != http.StatusOK || w.Body.String() != "admin" {
+		t.Errorf("after login: %d %q", w.Code, w.Body.String())
+	}
+
+	// Single use.
+	if w := serve(h, http.MethodGet, "/?login="+code, nil); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("second use: %d, cookies %v", w.Code, w.Result().Cookies())
+	}
+
+	// Short-lived.
+	expired, _ := a.IssueLogin("admin-token", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if w := serve(h, http.MethodGet, "/?login="+expired, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired code: %d", w.Code)
+	}
+	if _, ok := a.logins[expired]; ok {
+		t.Error("expired code kept after a redeem attempt")
+	}
+
+	// Unknown codes fall back to the other credentials.
+	if w := serve(h, http.MethodGet, "/?login=bogus", bearer("read-token")); w.Code != http.StatusOK {
+		t.Errorf("unknown code with a token: %d", w.Code)
+	}
+}
+
+func TestAddUser(t *testing.T) {
+	a := &Authenticator{}
+	for _, spec := range []string{"alice", ":pw", "alice:", ""} {
+		if err := a.AddUser(spec, Read); err == nil {
+			t.Errorf("AddUser(%q): want an error", spec)
+		}
+	}
+	// Passwords may contain colons.
+	if err := a.AddUser("alice:a:b", Read); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("alice", "a:b")
+	if got := a.Authenticate(r); got != Read {
+		t.Errorf("role = %v, want read", got)
+	}
+}
+
+func TestTokenRoleHighestWins(t *testing.T) {
+	a := &Authenticator{}
+	a.AddToken("shared", Read)
+	a.AddToken("shared", Admin)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer shared")
+	if got := a.Authenticate(r); got != Admin {
+		t.Errorf("role = %v, want admin", got)
+	}
+}
@@ -0,0 +1,34 @@
+// Package caps reads the process's Linux capabilities.
+package caps
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NetAdmin is CAP_NET_ADMIN, which iw needs to trigger scans.
+const NetAdmin = 12
+
+// Effective returns the effective capability mask (CapEff) from
+// /proc/self/status.
+func Effective() (uint64, error) {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		}
+	}
+	return 0, errors.New("no CapEff in /proc/self/status")
+}
+
+// Has reports whether capability c is effective. It is false when the
+// capabilities cannot be read, e.g. on other systems than Linux.
+func Has(c uint) bool {
+	eff, err := Effective()
+	return err == nil && eff&(1<<c) != 0
+}
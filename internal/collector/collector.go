@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"wifi-radar/internal/model"
+	"wifi-radar/internal/oui"
 )
 
 var ErrNotConnected = errors.New("not connected")
@@ -18,10 +19,12 @@ type Collector struct {
 	IfName string
 }
 
-func (c Collector) Collect() (model.Sample, error) {
-	out, err := exec.Command("iw", "dev", c.IfName, "link").Output()
+func (c Collector) Collect() (sample model.Sample, err error) {
+	defer func() { countError(err) }()
+
+	out, err := exec.Command("iw", "dev", c.IfName, "link").CombinedOutput()
 	if err != nil {
-		return model.Sample{}, fmt.Errorf("iw link: %w", err)
+		return model.Sample{}, iwError("link", c.IfName, out, err)
 	}
 
 	sample, connected, err := ParseLinkOutput(out, c.IfName)
@@ -93,6 +96,8 @@ func ParseLinkOutput(out []byte, ifname string) (model.Sample, bool, error) {
 		return model.Sample{}, connected, fmt.Errorf("scan iw output: %w", err)
 	}
 
+	sample.Vendor, _ = oui.Lookup(sample.BSSID)
+	sample.Randomized = oui.IsLocallyAdministered(sample.BSSID)
 	sample.TimestampUnixM = model.NowUnixMS()
 	return sample, connected, nil
 }
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// iw failures by the kernel's errno. iw prints it as e.g. "command
+// failed: Device or resource busy (-16)".
+var (
+	ErrPermission = errors.New("operation not permitted")
+	ErrBusy       = errors.New("device or resource busy")
+	ErrNetDown    = errors.New("network is down")
+	ErrNoDevice   = errors.New("no such device")
+	// ErrNotSupported is EOPNOTSUPP, e.g. a driver that cannot limit a
+	// scan to some frequencies or SSIDs.
+	ErrNotSupported = errors.New("operation not supported")
+)
+
+var iwErrnos = []struct {
+	errno int
+	text  string
+	err   error
+}{
+	{1, "operation not permitted", ErrPermission},
+	{16, "device or resource busy", ErrBusy},
+	{100, "network is down", ErrNetDown},
+	{19, "no such device", ErrNoDevice},
+	{95, "operation not supported", ErrNotSupported},
+}
+
+var errnoPattern = regexp.MustCompile(`\(-(\d+)\)\s*$`)
+
+// IwError is a failed iw command. errors.Is matches Kind, one of the
+// errors above when iw reported a known errno.
+type IwError struct {
+	Command string
+	IfName  string
+	Kind    error
+	// Message is iw's last output line.
+	Message string
+	Err     error
+}
+
+func (e *IwError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+	s := fmt.Sprintf("iw %s on %s: %s", e.Command, e.IfName, msg)
+	switch e.Kind {
+	case ErrPermission:
+		s += "; scanning needs CAP_NET_ADMIN, see wifi-radar doctor"
+	case ErrBusy:
+		s += "; another scan is running, possibly NetworkManager's or wpa_supplicant's"
+	case ErrNetDown:
+		s += "; bring it up with ip link set " + e.IfName + " up"
+	case ErrNoDevice:
+		s += "; iw dev lists the wireless interfaces"
+	case ErrNotSupported:
+		s += "; the driver does not support this kind of scan"
+	}
+	return s
+}
+
+func (e *IwError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// iwError classifies a failed iw run from its output.
+func iwError(command, ifname string, out []byte, err error) error {
+	e := &IwError{Command: command, IfName: ifname, Err: err}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	e.Message = strings.TrimSpace(lines[len(lines)-1])
+
+	errno := 0
+	if m := errnoPattern.FindStringSubmatch(e.Message); m != nil {
+		errno, _ = strconv.Atoi(m[1])
+	}
+	lower := strings.ToLower(e.Message)
+	for _, known := range iwErrnos {
+		if errno == known.errno || (errno == 0 && strings.Contains(lower, known.text)) {
+			e.Kind = known.err
+			break
+		}
+	}
+	return e
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"wifi-radar/internal/scanhelper"
+)
+
+func TestIwError(t *testing.T) {
+	exit := &scanhelper.ExitError{Code: 240}
+	for _, tt := range []struct {
+		out  string
+		kind error
+		typ  string
+		hint string
+	}{
+		{"command failed: Device or resource busy (-16)", ErrBusy, "busy", "another scan is running"},
+		{"command failed: Operation not permitted (-1)", ErrPermission, "permission", "CAP_NET_ADMIN"},
+		{"command failed: No such device (-19)", ErrNoDevice, "no_device", "iw dev lists"},
+		{"command failed: Network is down (-100)", ErrNetDown, "net_down", "ip link set wlan0 up"},
+		{"command failed: Operation not supported (-95)", ErrNotSupported, "not_supported", "does not support"},
+		// The errno decides, whatever the text says.
+		{"command failed: Resource busy (-16)", ErrBusy, "busy", ""},
+		// Only the last line counts.
+		{"some warning\ncommand failed: No such device (-19)\n", ErrNoDevice, "no_device", ""},
+		// Without an errno, the text is matched.
+		{"Operation not permitted", ErrPermission, "permission", ""},
+		{"ERROR: operation not supported", ErrNotSupported, "not_supported", ""},
+		// An unknown errno is not matched by text.
+		{"command failed: Device or resource busy, sort of (-22)", nil, "iw", ""},
+		{"command failed: Invalid argument (-22)", nil, "iw", ""},
+		{"", nil, "iw", ""},
+	} {
+		err := iwError("scan", "wlan0", []byte(tt.out), exit)
+		var iwErr *IwError
+		if !errors.As(err, &iwErr) {
+			t.Fatalf("%q: %T is not an *IwError", tt.out, err)
+		}
+		if iwErr.Kind != tt.kind {
+			t.Errorf("%q: kind %v, want %v", tt.out, iwErr.Kind, tt.kind)
+		}
+		if tt.kind != nil && !errors.Is(err, tt.kind) {
+			t.Errorf("%q: errors.Is(err, %v) is false", tt.out, tt.kind)
+		}
+		if !errors.Is(err, exit) {
+			t.Errorf("%q: the exit error is not wrapped", tt.out)
+		}
+		if got := ErrorType(err); got != tt.typ {
+			t.Errorf("%q: ErrorType = %s, want %s", tt.out, got, tt.typ)
+		}
+		if !strings.Contains(err.Error(), tt.hint) || !strings.HasPrefix(err.Error(), "iw scan on wlan0: ") {
+			t.Errorf("%q: message %q, want a hint containing %q", tt.out, err, tt.hint)
+		}
+	}
+}
+
+func TestIwErrorMessage(t *testing.T) {
+	err := iwError("scan dump", "wlan1", nil, exec.ErrNotFound)
+	if got, want := err.Error(), "iw scan dump on wlan1: "+exec.ErrNotFound.Error(); got != want {
+		t.Errorf("no output: %q, want %q", got, want)
+	}
+	err = iwError("scan", "wlan1", []byte("command failed: Network is down (-100)"), exec.ErrNotFound)
+	if got, want := err.Error(), "iw scan on wlan1: command failed: Network is down (-100); bring it up with ip link set wlan1 up"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	for err, want := range map[error]string{
+		ErrTargetNotFound:          "target_not_found",
+		ErrNotConnected:            "not_connected",
+		errors.New("parse failed"): "iw",
+	} {
+		if got := ErrorType(err); got != want {
+			t.Errorf("ErrorType(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"errors"
+
+	"wifi-radar/internal/metrics"
+)
+
+// Collector health, exported on /metrics.
+var (
+	ScanDuration = metrics.NewHistogram(0.25, 0.5, 1, 2, 3, 5, 8, 13)
+	HelperScans  metrics.Counter
+	Errors       metrics.CounterVec
+)
+
+// ErrorType classifies a collection error for the errors counter.
+func ErrorType(err error) string {
+	switch {
+	case errors.Is(err, ErrTargetNotFound):
+		return "target_not_found"
+	case errors.Is(err, ErrNotConnected):
+		return "not_connected"
+	case errors.Is(err, ErrPermission):
+		return "permission"
+	case errors.Is(err, ErrBusy):
+		return "busy"
+	case errors.Is(err, ErrNetDown):
+		return "net_down"
+	case errors.Is(err, ErrNoDevice):
+		return "no_device"
+	case errors.Is(err, ErrNotSupported):
+		return "not_supported"
+	default:
+		return "iw"
+	}
+}
+
+func countError(err error) {
+	if err != nil && !errors.Is(err, ErrStale) {
+		Errors.With(ErrorType(err)).Inc()
+	}
+}
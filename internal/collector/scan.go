@@ -6,15 +6,42 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"wifi-radar/internal/model"
+	"wifi-radar/internal/oui"
+	"wifi-radar/internal/scanhelper"
 )
 
-var ErrTargetNotFound = errors.New("target network not found")
+var (
+	ErrTargetNotFound = errors.New("target network not found")
+	// ErrStale means the cached results have no newer observation of the
+	// target than the last sample. It is not a failure.
+	ErrStale = errors.New("no new observation of the target")
+)
+
+// staleSlackMS absorbs jitter in last-seen ages between dumps; beacons
+// are about 100 ms apart.
+const staleSlackMS = 50
+
+// ScanOptions restricts the scans that are triggered.
+type ScanOptions struct {
+	// Freqs limits scans to these frequencies in MHz.
+	Freqs []int
+	// SSIDs are probed for, which also finds them when hidden.
+	SSIDs []string
+	// Passive listens for beacons instead of sending probe requests.
+	Passive bool
+}
+
+func (o ScanOptions) request(ifname, mode string) scanhelper.Request {
+	return scanhelper.Request{IfName: ifname, Mode: mode, Freqs: o.Freqs, SSIDs: o.SSIDs, Passive: o.Passive}
+}
+
+const defaultFullScanAfter = 3
 
 type ScanTarget struct {
 	SSID  string
@@ -24,53 +51,201 @@ type ScanTarget struct {
 type ScanCollector struct {
 	IfName  string
 	Target  ScanTarget
-	UseSudo bool
+	Options ScanOptions
+	// TriggerEvery, if set, makes Collect read the kernel's cached
+	// results (iw scan dump) and trigger a new scan only this often,
+	// instead of running a full scan every time.
+	TriggerEvery time.Duration
+	// MaxAge drops cached results last seen longer ago. It defaults to
+	// twice TriggerEvery.
+	MaxAge time.Duration
+	// Targeted scans only the target's frequency, probing for its SSID,
+	// once a full scan has found it. After FullScanAfter misses in a row
+	// (default 3) it goes back to full scans.
+	Targeted      bool
+	FullScanAfter int
+	// OnScan, if set, receives every network from each successful scan.
+	// A targeted scan only reports what it covered: the target's channel.
+	OnScan func(networks []model.Sample)
+
+	mu          sync.Mutex
+	lastTrigger time.Time
+	lastSeen    int64
+	targetFreq  int
+	targetSSID  string
+	misses      int
+}
+
+// SetTarget switches the tracked network; it is safe to call while
+// Collect runs.
+func (c *ScanCollector) SetTarget(target ScanTarget) {
+	c.mu.Lock()
+	c.Target = target
+	c.lastSeen = 0
+	c.targetFreq, c.targetSSID, c.misses = 0, "", 0
+	c.mu.Unlock()
 }
 
-func (c *ScanCollector) Collect() (model.Sample, error) {
-	networks, usedSudo, err := ScanNetworksWithFallback(c.IfName, c.UseSudo)
+func (c *ScanCollector) CurrentTarget() ScanTarget {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Target
+}
+
+func (c *ScanCollector) Collect() (sample model.Sample, err error) {
+	defer func() { countError(err) }()
+
+	networks, err := c.scan(c.scanOptions())
 	if err != nil {
 		return model.Sample{}, err
 	}
-	c.UseSudo = usedSudo
+	if c.OnScan != nil {
+		c.OnScan(networks)
+	}
 
-	sample, ok := PickTarget(networks, c.Target)
+	target := c.CurrentTarget()
+	sample, ok := PickTarget(networks, target)
+	c.track(sample, ok)
 	if !ok {
 		sample = model.Sample{
 			IfName:         c.IfName,
-			SSID:           c.Target.SSID,
-			BSSID:          normalizeBSSID(c.Target.BSSID),
+			SSID:           target.SSID,
+			BSSID:          normalizeBSSID(target.BSSID),
 			SignalDBM:      -100,
 			TimestampUnixM: model.NowUnixMS(),
+			Lost:           true,
 		}
 		return sample, ErrTargetNotFound
 	}
-	sample.TimestampUnixM = model.NowUnixMS()
+	if c.TriggerEvery <= 0 {
+		sample.TimestampUnixM = model.NowUnixMS()
+		return sample, nil
+	}
+
+	// Cached results say how long ago the BSS was last heard.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	heard := sample.TimestampUnixM - sample.AgeMS
+	if heard < c.lastSeen+staleSlackMS {
+		return sample, ErrStale
+	}
+	c.lastSeen = heard
 	return sample, nil
 }
 
-func ScanNetworksWithFallback(ifname string, useSudo bool) ([]model.Sample, bool, error) {
-	networks, err := ScanNetworks(ifname, useSudo)
-	if err == nil {
-		return networks, useSudo, nil
+// scan runs a full scan, or with TriggerEvery set, triggers one when due
+// and returns the fresh part of the cached results. The first call
+// always waits for a full scan so the cache is filled.
+func (c *ScanCollector) scan(opts ScanOptions) ([]model.Sample, error) {
+	if c.TriggerEvery <= 0 {
+		return ScanNetworks(c.IfName, opts)
+	}
+
+	var (
+		networks []model.Sample
+		err      error
+	)
+	switch {
+	case c.lastTrigger.IsZero():
+		c.lastTrigger = time.Now()
+		networks, err = ScanNetworks(c.IfName, opts)
+	case time.Since(c.lastTrigger) >= c.TriggerEvery:
+		c.lastTrigger = time.Now()
+		// A scan already running fills the cache just as well.
+		if err := TriggerScan(c.IfName, opts); err != nil && !errors.Is(err, ErrBusy) {
+			return nil, err
+		}
+		fallthrough
+	default:
+		networks, err = DumpNetworks(c.IfName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Scan results include everything still in the kernel's cache.
+	maxAge := c.MaxAge
+	if maxAge <= 0 {
+		maxAge = 2 * c.TriggerEvery
 	}
-	if !useSudo && isPermissionError(err) {
-		networks, err = ScanNetworks(ifname, true)
-		if err == nil {
-			return networks, true, nil
+	cutoff := model.NowUnixMS() - maxAge.Milliseconds()
+	fresh := networks[:0]
+	for _, n := range networks {
+		if n.TimestampUnixM-n.AgeMS >= cutoff {
+			fresh = append(fresh, n)
 		}
 	}
-	return nil, useSudo, err
+	return fresh, nil
+}
+
+// scanOptions returns the options for the next scan, narrowed to the
+// target's learned frequency once it is known.
+func (c *ScanCollector) scanOptions() ScanOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.Targeted || c.targetFreq == 0 {
+		return c.Options
+	}
+	opts := ScanOptions{Freqs: []int{c.targetFreq}, Passive: c.Options.Passive}
+	// An SSID iw reads as a keyword can't be probed for; the scan still
+	// finds it on its channel unless it is hidden.
+	if c.targetSSID != "" && !c.Options.Passive && !scanhelper.IwKeyword(c.targetSSID) {
+		opts.SSIDs = []string{c.targetSSID}
+	}
+	return opts
 }
 
-func ScanNetworks(ifname string, useSudo bool) ([]model.Sample, error) {
-	out, err := runIwScan(ifname, useSudo)
+// track learns the target's frequency and SSID when it was found, and
+// forgets them after too many misses so the next scan is a full one.
+// Without a target, scans stay full.
+func (c *ScanCollector) track(sample model.Sample, found bool) {
+	if !c.Targeted {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Target.SSID == "" && c.Target.BSSID == "" {
+		return
+	}
+	if found {
+		c.targetFreq, c.targetSSID, c.misses = sample.FreqMHz, sample.SSID, 0
+		return
+	}
+	limit := c.FullScanAfter
+	if limit <= 0 {
+		limit = defaultFullScanAfter
+	}
+	if c.misses++; c.misses >= limit {
+		c.targetFreq, c.targetSSID, c.misses = 0, "", 0
+	}
+}
+
+// ScanNetworks runs a scan and waits for its results.
+func ScanNetworks(ifname string, opts ScanOptions) ([]model.Sample, error) {
+	start := time.Now()
+	out, err := runIw(opts.request(ifname, ""))
+	ScanDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		msg := strings.TrimSpace(string(out))
-		if msg == "" {
-			return nil, fmt.Errorf("iw scan: %w", err)
-		}
-		return nil, fmt.Errorf("iw scan: %w: %s", err, msg)
+		return nil, iwError("scan", ifname, out, err)
+	}
+	return ParseScanOutput(out, ifname)
+}
+
+// TriggerScan starts a scan without waiting for it; DumpNetworks returns
+// the results once it is done.
+func TriggerScan(ifname string, opts ScanOptions) error {
+	out, err := runIw(opts.request(ifname, scanhelper.ModeTrigger))
+	if err != nil {
+		return iwError("scan trigger", ifname, out, err)
+	}
+	return nil
+}
+
+// DumpNetworks returns the kernel's cached scan results without scanning.
+func DumpNetworks(ifname string) ([]model.Sample, error) {
+	out, err := runIw(ScanOptions{}.request(ifname, scanhelper.ModeDump))
+	if err != nil {
+		return nil, iwError("scan dump", ifname, out, err)
 	}
 	return ParseScanOutput(out, ifname)
 }
@@ -78,7 +253,11 @@ func ScanNetworks(ifname string, useSudo bool) ([]model.Sample, error) {
 func ParseScanOutput(out []byte, ifname string) ([]model.Sample, error) {
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	results := make([]model.Sample, 0, 16)
-	var current *model.Sample
+	var (
+		current  *model.Sample
+		security securityInfo
+		ageMS    int64
+	)
 	now := model.NowUnixMS()
 
 	flush := func() {
@@ -86,6 +265,10 @@ func ParseScanOutput(out []byte, ifname string) ([]model.Sample, error) {
 			return
 		}
 		current.TimestampUnixM = now
+		current.AgeMS = ageMS
+		current.Security = security.label()
+		current.Vendor, _ = oui.Lookup(current.BSSID)
+		current.Randomized = oui.IsLocallyAdministered(current.BSSID)
 		results = append(results, *current)
 	}
 
@@ -103,15 +286,28 @@ func ParseScanOutput(out []byte, ifname string) ([]model.Sample, error) {
 				SignalDBM: -100,
 			}
 			current = &sample
+			security = securityInfo{}
+			ageMS = 0
 			continue
 		}
 		if current == nil {
 			continue
 		}
+		if security.parseLine(line) {
+			continue
+		}
 		if strings.HasPrefix(line, "freq:") {
+			// Newer iw versions print fractional MHz, e.g. "2412.0".
 			freqStr := strings.TrimSpace(strings.TrimPrefix(line, "freq:"))
-			if v, err := strconv.Atoi(freqStr); err == nil {
-				current.FreqMHz = v
+			if v, err := strconv.ParseFloat(freqStr, 64); err == nil {
+				current.FreqMHz = int(math.Round(v))
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "last seen:"); ok {
+			// "last seen: 1234 ms ago"
+			if fields := strings.Fields(v); len(fields) > 0 {
+				ageMS, _ = strconv.ParseInt(fields[0], 10, 64)
 			}
 			continue
 		}
@@ -193,27 +389,35 @@ func parseSignal(line string) (int, bool) {
 	return int(math.Round(val)), true
 }
 
-func runIwScan(ifname string, useSudo bool) ([]byte, error) {
-	args := []string{"dev", ifname, "scan"}
-	if useSudo {
-		cmd := exec.Command("sudo", append([]string{"iw"}, args...)...)
-		cmd.Stdin = os.Stdin
-		cmd.Stderr = os.Stderr
-		return cmd.Output()
+var helper *scanhelper.Client
+
+// UseScanHelper runs scans through the privileged helper at path instead
+// of running iw directly. Call it before the first scan.
+func UseScanHelper(path string) {
+	helper = &scanhelper.Client{Path: path}
+}
+
+// ScanHelper returns the helper's path, or "" when scans run iw directly.
+func ScanHelper() string {
+	if helper == nil {
+		return ""
 	}
-	return exec.Command("iw", args...).CombinedOutput()
+	return helper.Path
 }
 
-func isPermissionError(err error) bool {
-	if err == nil {
-		return false
+// runIw runs iw for req; tests replace it.
+var runIw = execIw
+
+// execIw sends scans and triggers to the helper if there is one. Reading
+// the cache needs no privileges and always runs iw directly.
+func execIw(req scanhelper.Request) ([]byte, error) {
+	if helper != nil && req.Mode != scanhelper.ModeDump {
+		HelperScans.Inc()
+		return helper.Scan(req)
 	}
-	msg := strings.ToLower(err.Error())
-	if strings.Contains(msg, "exit status 255") {
-		return true
+	args, err := scanhelper.Args(req)
+	if err != nil {
+		return nil, err
 	}
-	return strings.Contains(msg, "not permitted") ||
-		strings.Contains(msg, "permission denied") ||
-		strings.Contains(msg, "not authorized") ||
-		strings.Contains(msg, "operation not permitted")
+	return scanhelper.Run("iw", args)
 }
@@ -0,0 +1,376 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/export"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/scanhelper"
+)
+
+// bss is one entry of the fake kernel scan cache.
+type bss struct {
+	bssid, ssid string
+	freq, dbm   int
+	ageMS       int64
+}
+
+// fakeIw stands in for iw: full scans return the cached BSSes on the
+// requested frequencies, dumps return all of them.
+type fakeIw struct {
+	cache []bss
+	// fail maps a request mode to iw's output for a failed run.
+	fail map[string]string
+	reqs []scanhelper.Request
+}
+
+func useFakeIw(t *testing.T, f *fakeIw) {
+	t.Helper()
+	old := runIw
+	runIw = f.run
+	t.Cleanup(func() { runIw = old })
+}
+
+func (f *fakeIw) run(req scanhelper.Request) ([]byte, error) {
+	if _, err := scanhelper.Args(req); err != nil {
+		return nil, err
+	}
+	f.reqs = append(f.reqs, req)
+	if out, ok := f.fail[req.Mode]; ok {
+		return []byte(out), &scanhelper.ExitError{Code: 240}
+	}
+	if req.Mode == scanhelper.ModeTrigger {
+		return nil, nil
+	}
+	var b strings.Builder
+	for _, e := range f.cache {
+		if req.Mode == "" && len(req.Freqs) > 0 && !containsInt(req.Freqs, e.freq) {
+			continue
+		}
+		fmt.Fprintf(&b, "BSS %s(on %s)\n\tfreq: %d.0\n\tlast seen: %d ms ago\n\tsignal: %d.00 dBm\n\tSSID: %s\n",
+			e.bssid, req.IfName, e.freq, e.ageMS, e.dbm, e.ssid)
+	}
+	return []byte(b.String()), nil
+}
+
+// modes lists the modes of the requests since the last call, "scan"
+// standing for a full scan.
+func (f *fakeIw) modes() string {
+	var out []string
+	for _, r := range f.reqs {
+		mode := r.Mode
+		if mode == "" {
+			mode = "scan"
+		}
+		out = append(out, mode)
+	}
+	f.reqs = nil
+	return strings.Join(out, ",")
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseScanOutput(t *testing.T) {
+	out := []byte(`BSS AA:BB:CC:DD:EE:01(on wlan0) -- associated
+	TSF: 123 usec
+	freq: 2412.0
+	beacon interval: 100 TUs
+	last seen: 1500 ms ago
+	signal: -47.50 dBm
+	SSID: home
+BSS aa:bb:cc:dd:ee:02(on wlan0)
+	freq: 5180
+	signal: -71.00 dBm
+	SSID: 
+`)
+	before := model.NowUnixMS()
+	networks, err := ParseScanOutput(out, "wlan0")
+	after := model.NowUnixMS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 2 {
+		t.Fatalf("networks = %+v", networks)
+	}
+
+	a, b := networks[0], networks[1]
+	if a.BSSID != "aa:bb:cc:dd:ee:01" || a.SSID != "home" || a.FreqMHz != 2412 || a.SignalDBM != -48 || a.IfName != "wlan0" {
+		t.Errorf("first = %+v", a)
+	}
+	if b.BSSID != "aa:bb:cc:dd:ee:02" || b.SSID != "" || b.FreqMHz != 5180 || b.SignalDBM != -71 {
+		t.Errorf("second = %+v", b)
+	}
+	// The timestamp is when the scan was read; the age says how long
+	// before that the BSS was last heard.
+	for _, n := range networks {
+		if n.TimestampUnixM < before || n.TimestampUnixM > after {
+			t.Errorf("%s: ts %d not within the parse (%d-%d)", n.BSSID, n.TimestampUnixM, before, after)
+		}
+	}
+	if a.AgeMS != 1500 || b.AgeMS != 0 {
+		t.Errorf("ages = %d, %d; want 1500, 0", a.AgeMS, b.AgeMS)
+	}
+}
+
+func TestScanCollectorCached(t *testing.T) {
+	target := bss{"aa:bb:cc:dd:ee:01", "home", 2412, -50, 100}
+	for _, tt := range []struct {
+		name string
+		// sinceTrigger is the time since the last trigger; zero means
+		// no scan yet.
+		sinceTrigger time.Duration
+		fail         map[string]string
+		modes        string
+		err          error
+	}{
+		{name: "first collect waits for a full scan", modes: "scan"},
+		{name: "between triggers only the cache is read", sinceTrigger: 3 * time.Second, modes: "dump"},
+		{name: "a due trigger comes before the dump", sinceTrigger: 10 * time.Second, modes: "trigger,dump"},
+		{name: "a running scan counts as triggered", sinceTrigger: 11 * time.Second,
+			fail: map[string]string{scanhelper.ModeTrigger: "command failed: Device or resource busy (-16)"}, modes: "trigger,dump"},
+		{name: "a failed trigger is reported", sinceTrigger: 11 * time.Second,
+			fail: map[string]string{scanhelper.ModeTrigger: "command failed: Operation not permitted (-1)"}, modes: "trigger", err: ErrPermission},
+		{name: "a failed dump is reported", sinceTrigger: time.Second,
+			fail: map[string]string{scanhelper.ModeDump: "command failed: Network is down (-100)"}, modes: "dump", err: ErrNetDown},
+	} {
+		iw := &fakeIw{cache: []bss{target}, fail: tt.fail}
+		useFakeIw(t, iw)
+		c := &ScanCollector{
+			IfName:       "wlan0",
+			Target:       ScanTarget{BSSID: target.bssid},
+			Options:      ScanOptions{Freqs: []int{2412, 2437}, Passive: true},
+			TriggerEvery: 10 * time.Second,
+		}
+		if tt.sinceTrigger > 0 {
+			c.lastTrigger = time.Now().Add(-tt.sinceTrigger)
+		}
+
+		_, err := c.Collect()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		for _, req := range iw.reqs {
+			// Scan options apply to scans and triggers, not dumps.
+			if want := req.Mode != scanhelper.ModeDump; (len(req.Freqs) > 0 && req.Passive) != want {
+				t.Errorf("%s: %s request %+v", tt.name, req.Mode, req)
+			}
+		}
+		if got := iw.modes(); got != tt.modes {
+			t.Errorf("%s: ran %s, want %s", tt.name, got, tt.modes)
+		}
+	}
+}
+
+func TestScanCollectorFreshness(t *testing.T) {
+	iw := &fakeIw{cache: []bss{
+		{"aa:bb:cc:dd:ee:01", "home", 2412, -50, 1000},
+		{"aa:bb:cc:dd:ee:02", "home", 5180, -60, 5000},
+		{"aa:bb:cc:dd:ee:03", "gone", 2437, -40, 25000},
+	}}
+	useFakeIw(t, iw)
+	var scanned []model.Sample
+	c := &ScanCollector{
+		IfName:       "wlan0",
+		Target:       ScanTarget{BSSID: "AA:BB:CC:DD:EE:01"},
+		TriggerEvery: 10 * time.Second,
+		OnScan:       func(networks []model.Sample) { scanned = networks },
+	}
+
+	before := model.NowUnixMS()
+	sample, err := c.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries older than MaxAge (twice TriggerEvery here) are gone.
+	if got := len(scanned); got != 2 || scanned[1].BSSID != "aa:bb:cc:dd:ee:02" {
+		t.Errorf("fresh networks = %+v, want the first two", scanned)
+	}
+	// The sample is stamped with the scan time, not the last-seen time,
+	// so history and surveys line up with the other samples.
+	if sample.TimestampUnixM < before || sample.AgeMS != 1000 {
+		t.Errorf("sample ts %d (collect started at %d), age %d; want the scan time and age 1000", sample.TimestampUnixM, before, sample.AgeMS)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		ageMS int64
+		err   error
+	}{
+		// Heard 4 s before the previous sample: nothing new.
+		{"older entry", 5000, ErrStale},
+		{"heard again", 0, nil},
+		// Heard before the sample just taken.
+		{"earlier beacon", 1000, ErrStale},
+		{"past MaxAge", 21000, ErrTargetNotFound},
+	} {
+		iw.cache[0].ageMS = tt.ageMS
+		if _, err := c.Collect(); err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// An explicit MaxAge overrides the default.
+	c.MaxAge = 30 * time.Second
+	c.Collect()
+	if len(scanned) != 3 {
+		t.Errorf("with MaxAge 30s: %d networks, want 3", len(scanned))
+	}
+}
+
+func TestScanCollectorTargeted(t *testing.T) {
+	target := bss{"aa:bb:cc:dd:ee:01", "home", 5180, -50, 0}
+	// Named like an iw keyword, so it can't be probed for.
+	other := bss{"aa:bb:cc:dd:ee:02", "passive", 2412, -60, 0}
+
+	// Each step is one Collect: whether the target is in range, and the
+	// scan that should run, as "full" or its frequencies and SSIDs.
+	type step struct {
+		inRange bool
+		scan    string
+		err     error
+	}
+	for _, tt := range []struct {
+		name   string
+		target ScanTarget
+		opts   ScanOptions
+		after  int
+		steps  []step
+	}{
+		{"learns the frequency and SSID", ScanTarget{BSSID: target.bssid}, ScanOptions{}, 0, []step{
+			{true, "full", nil},
+			{true, "5180 home", nil},
+			{true, "5180 home", nil},
+		}},
+		{"falls back after the default 3 misses", ScanTarget{SSID: "home"}, ScanOptions{}, 0, []step{
+			{true, "full", nil},
+			{false, "5180 home", ErrTargetNotFound},
+			{false, "5180 home", ErrTargetNotFound},
+			{false, "5180 home", ErrTargetNotFound},
+			{false, "full", ErrTargetNotFound},
+			{true, "full", nil},
+			{true, "5180 home", nil},
+		}},
+		{"a hit resets the misses", ScanTarget{BSSID: target.bssid}, ScanOptions{}, 2, []step{
+			{true, "full", nil},
+			{false, "5180 home", ErrTargetNotFound},
+			{true, "5180 home", nil},
+			{false, "5180 home", ErrTargetNotFound},
+			{false, "5180 home", ErrTargetNotFound},
+			{true, "full", nil},
+		}},
+		{"passive scans do not probe", ScanTarget{BSSID: target.bssid}, ScanOptions{Passive: true}, 0, []step{
+			{true, "full", nil},
+			{true, "5180", nil},
+		}},
+		{"no probe for an iw keyword", ScanTarget{SSID: "passive"}, ScanOptions{}, 0, []step{
+			{false, "full", nil},
+			{false, "2412", nil},
+		}},
+		{"no target keeps scanning everything", ScanTarget{}, ScanOptions{Freqs: []int{2412, 5180}}, 0, []step{
+			{true, "2412,5180", nil},
+			{true, "2412,5180", nil},
+		}},
+	} {
+		iw := &fakeIw{}
+		useFakeIw(t, iw)
+		c := &ScanCollector{IfName: "wlan0", Target: tt.target, Options: tt.opts, Targeted: true, FullScanAfter: tt.after}
+		for i, s := range tt.steps {
+			iw.cache = []bss{other}
+			if s.inRange {
+				iw.cache = append(iw.cache, target)
+			}
+			_, err := c.Collect()
+			if err != s.err {
+				t.Errorf("%s, step %d: err = %v, want %v", tt.name, i+1, err, s.err)
+			}
+			if len(iw.reqs) != 1 {
+				t.Fatalf("%s, step %d: %d requests", tt.name, i+1, len(iw.reqs))
+			}
+			req := iw.reqs[0]
+			iw.reqs = nil
+			scan := "full"
+			if len(req.Freqs) > 0 {
+				scan = strings.Trim(strings.Join(strings.Fields(fmt.Sprint(req.Freqs)), ","), "[]")
+			}
+			if len(req.SSIDs) > 0 {
+				scan += " " + strings.Join(req.SSIDs, ",")
+			}
+			if scan != s.scan {
+				t.Errorf("%s, step %d: scanned %s, want %s", tt.name, i+1, scan, s.scan)
+			}
+			if req.Passive != tt.opts.Passive {
+				t.Errorf("%s, step %d: passive %v", tt.name, i+1, req.Passive)
+			}
+		}
+	}
+}
+
+func TestScanCollectorSetTarget(t *testing.T) {
+	iw := &fakeIw{cache: []bss{{"aa:bb:cc:dd:ee:01", "home", 5180, -50, 0}, {"aa:bb:cc:dd:ee:02", "cafe", 2412, -60, 0}}}
+	useFakeIw(t, iw)
+	c := &ScanCollector{IfName: "wlan0", Target: ScanTarget{SSID: "home"}, Targeted: true}
+	c.Collect()
+	c.SetTarget(ScanTarget{SSID: "cafe"})
+	iw.reqs = nil
+	if _, err := c.Collect(); err != nil || len(iw.reqs[0].Freqs) != 0 {
+		t.Errorf("after SetTarget: %v, request %+v; want a full scan", err, iw.reqs[0])
+	}
+	iw.reqs = nil
+	if c.Collect(); len(iw.reqs[0].Freqs) != 1 || iw.reqs[0].Freqs[0] != 2412 {
+		t.Errorf("request %+v, want the new target's frequency", iw.reqs[0])
+	}
+}
+
+func TestScanCollectorOnScanTargeted(t *testing.T) {
+	iw := &fakeIw{cache: []bss{
+		{"aa:bb:cc:dd:ee:01", "home", 5180, -50, 0},
+		{"aa:bb:cc:dd:ee:02", "cafe", 2412, -60, 0},
+	}}
+	useFakeIw(t, iw)
+	inv := &export.Inventory{}
+	var scanned []model.Sample
+	c := &ScanCollector{IfName: "wlan0", Target: ScanTarget{SSID: "home"}, Targeted: true,
+		OnScan: func(networks []model.Sample) {
+			scanned = networks
+			inv.Observe(networks)
+		}}
+
+	c.Collect()
+	first := scanned[0].TimestampUnixM
+	for i := 0; i < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+		start := model.NowUnixMS()
+		iw.cache[0].dbm, iw.cache[1].dbm = -45, -65
+		c.Collect()
+		// The targeted scans only cover 5180 MHz, so only the home AP is
+		// reported, stamped with this scan's time.
+		if len(scanned) != 1 || scanned[0].BSSID != "aa:bb:cc:dd:ee:01" || scanned[0].TimestampUnixM < start {
+			t.Fatalf("targeted scan %d reported %+v", i+1, scanned)
+		}
+	}
+
+	bsses := inv.BSSes()
+	byBSSID := make(map[string]model.BSS)
+	for _, b := range bsses {
+		byBSSID[b.BSSID] = b
+	}
+	home, cafe := byBSSID["aa:bb:cc:dd:ee:01"], byBSSID["aa:bb:cc:dd:ee:02"]
+	if home.Observations != 3 || home.LastSeenMS <= first || home.BestDBM != -45 {
+		t.Errorf("home = %+v, want 3 observations and seen after %d", home, first)
+	}
+	// The cafe was only seen by the first, full scan.
+	if cafe.Observations != 1 || cafe.LastSeenMS != first || cafe.BestDBM != -60 {
+		t.Errorf("cafe = %+v, want 1 observation last seen at %d", cafe, first)
+	}
+}
@@ -0,0 +1,98 @@
+package collector
+
+import "strings"
+
+const (
+	SecurityOpen = "OPEN"
+	SecurityWEP  = "WEP"
+)
+
+// securityInfo collects the capability, RSN and WPA lines of one BSS in
+// iw scan output.
+type securityInfo struct {
+	privacy bool
+	section string
+	rsn     []string
+	wpa     []string
+	hasRSN  bool
+	hasWPA  bool
+}
+
+func (s *securityInfo) parseLine(line string) bool {
+	switch {
+	case strings.HasPrefix(line, "capability:"):
+		s.privacy = strings.Contains(line, "Privacy")
+	case strings.HasPrefix(line, "RSN:"):
+		s.hasRSN = true
+		s.section = "RSN"
+	case strings.HasPrefix(line, "WPA:"):
+		s.hasWPA = true
+		s.section = "WPA"
+	case strings.Contains(line, "Authentication suites:"):
+		suites := strings.Fields(line[strings.Index(line, ":")+1:])
+		if s.section == "RSN" {
+			s.rsn = append(s.rsn, suites...)
+		} else if s.section == "WPA" {
+			s.wpa = append(s.wpa, suites...)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// label summarizes the security as e.g. "WPA2-PSK", "WPA2-PSK/WPA3-SAE"
+// (transition mode), "WPA-EAP/WPA2-EAP", "WEP" or "OPEN".
+func (s *securityInfo) label() string {
+	var parts []string
+	if s.hasWPA {
+		parts = append(parts, suiteLabels("WPA", s.wpa)...)
+	}
+	if s.hasRSN {
+		parts = append(parts, suiteLabels("WPA2", s.rsn)...)
+	}
+	switch {
+	case len(parts) > 0:
+		return strings.Join(parts, "/")
+	case s.privacy:
+		return SecurityWEP
+	default:
+		return SecurityOpen
+	}
+}
+
+func suiteLabels(generation string, suites []string) []string {
+	var out []string
+	add := func(label string) {
+		for _, l := range out {
+			if l == label {
+				return
+			}
+		}
+		out = append(out, label)
+	}
+	for i := 0; i < len(suites); i++ {
+		suite := suites[i]
+		// iw prints "IEEE 802.1X" as two fields.
+		if suite == "IEEE" && i+1 < len(suites) {
+			i++
+			suite = suites[i]
+		}
+		switch {
+		case suite == "PSK" || suite == "FT/PSK" || suite == "PSK/SHA-256":
+			add(generation + "-PSK")
+		case strings.Contains(suite, "SUITE-B"):
+			add("WPA3-EAP")
+		case strings.Contains(suite, "802.1X"):
+			add(generation + "-EAP")
+		case suite == "SAE" || suite == "FT/SAE":
+			add("WPA3-SAE")
+		case suite == "OWE":
+			add("OWE")
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, generation)
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package df
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	defaultBinDeg     = 10
+	defaultHeadingAge = 2 * time.Second
+	defaultSmoothing  = 0.3
+
+	// Fits with less swing than this carry no usable direction.
+	minAmplitudeDB = 0.5
+	minFitBins     = 4
+)
+
+const (
+	GuidanceNoHeading = "no heading"
+	GuidanceSweep     = "keep rotating"
+	GuidanceAhead     = "ahead"
+	GuidanceLeft      = "turn left"
+	GuidanceRight     = "turn right"
+)
+
+type Finder struct {
+	BinDeg        float64
+	MaxHeadingAge time.Duration
+	Smoothing     float64
+
+	mu          sync.Mutex
+	heading     float64
+	headingAt   time.Time
+	ifname      string
+	bssid       string
+	smoothed    float64
+	hasSmoothed bool
+	bins        []bin
+}
+
+type bin struct {
+	sum   float64
+	count int
+}
+
+func (f *Finder) SetHeading(deg float64) {
+	f.mu.Lock()
+	f.heading = normalize(deg)
+	f.headingAt = time.Now()
+	f.mu.Unlock()
+}
+
+func (f *Finder) Reset() {
+	f.mu.Lock()
+	f.resetLocked()
+	f.mu.Unlock()
+}
+
+// Observe feeds a sample into the sweep. Samples are tagged with the most
+// recent heading; they are ignored while no fresh heading is known.
+func (f *Finder) Observe(sample model.Sample) {
+	if sample.Lost || sample.SignalDBM == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ifname == "" {
+		f.ifname = sample.IfName
+	}
+	if sample.IfName != f.ifname {
+		return
+	}
+	if sample.BSSID != "" && f.bssid != "" && sample.BSSID != f.bssid {
+		// Target changed; bins from the old AP are meaningless now.
+		f.resetLocked()
+		f.ifname = sample.IfName
+	}
+	f.bssid = sample.BSSID
+
+	signal := float64(sample.SignalDBM)
+	if !f.hasSmoothed {
+		f.smoothed = signal
+		f.hasSmoothed = true
+	} else {
+		alpha := f.smoothing()
+		f.smoothed += alpha * (signal - f.smoothed)
+	}
+
+	if !f.headingFreshLocked() {
+		return
+	}
+	if len(f.bins) == 0 {
+		f.bins = make([]bin, int(math.Round(360/f.binDeg())))
+	}
+	idx := int(math.Round(f.heading/f.binDeg())) % len(f.bins)
+	f.bins[idx].sum += f.smoothed
+	f.bins[idx].count++
+}
+
+// Snapshot returns the current polar plot, bearing estimate and guidance.
+// It reports false until a heading has been received.
+func (f *Finder) Snapshot() (model.DF, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.headingAt.IsZero() {
+		return model.DF{}, false
+	}
+
+	out := model.DF{
+		HeadingDeg:   round1(f.heading),
+		HeadingAgeMS: time.Since(f.headingAt).Milliseconds(),
+		Bins:         make([]model.DFBin, 0, len(f.bins)),
+	}
+
+	binDeg := f.binDeg()
+	var thetas, values []float64
+	for i, b := range f.bins {
+		if b.count == 0 {
+			continue
+		}
+		bearing := float64(i) * binDeg
+		mean := b.sum / float64(b.count)
+		out.Bins = append(out.Bins, model.DFBin{
+			BearingDeg: bearing,
+			SignalDBM:  round1(mean),
+			Count:      b.count,
+		})
+		thetas = append(thetas, bearing*math.Pi/180)
+		values = append(values, mean)
+	}
+	if len(f.bins) > 0 {
+		out.Coverage = round2(float64(len(out.Bins)) / float64(len(f.bins)))
+	}
+
+	bearing, amplitude, r2, ok := fitPattern(thetas, values)
+	switch {
+	case !f.headingFreshLocked():
+		out.Guidance = GuidanceNoHeading
+	case !ok || amplitude < minAmplitudeDB:
+		out.Guidance = GuidanceSweep
+	default:
+		out.Fitted = true
+		out.BearingDeg = round1(bearing)
+		// Scale fit quality down until at least half the circle was swept.
+		out.Confidence = round2(r2 * math.Min(1, out.Coverage*2))
+		out.TurnDeg = round1(turn(f.heading, bearing))
+		switch {
+		case math.Abs(out.TurnDeg) <= binDeg:
+			out.Guidance = GuidanceAhead
+		case out.TurnDeg > 0:
+			out.Guidance = GuidanceRight
+		default:
+			out.Guidance = GuidanceLeft
+		}
+	}
+	return out, true
+}
+
+func (f *Finder) resetLocked() {
+	f.bins = nil
+	f.ifname = ""
+	f.bssid = ""
+	f.hasSmoothed = false
+}
+
+func (f *Finder) headingFreshLocked() bool {
+	if f.headingAt.IsZero() {
+		return false
+	}
+	maxAge := f.MaxHeadingAge
+	if maxAge <= 0 {
+		maxAge = defaultHeadingAge
+	}
+	return time.Since(f.headingAt) <= maxAge
+}
+
+func (f *Finder) binDeg() float64 {
+	if f.BinDeg <= 0 || f.BinDeg > 90 {
+		return defaultBinDeg
+	}
+	return f.BinDeg
+}
+
+func (f *Finder) smoothing() float64 {
+	if f.Smoothing <= 0 || f.Smoothing > 1 {
+		return defaultSmoothing
+	}
+	return f.Smoothing
+}
+
+// fitPattern fits signal(θ) = a + b·cos(θ) + c·sin(θ), a cardioid-like
+// pattern for a directional antenna, by least squares. It returns the
+// bearing of the lobe in degrees, the lobe amplitude in dB and R².
+func fitPattern(thetas []float64, values []float64) (float64, float64, float64, bool) {
+	if len(thetas) < minFitBins {
+		return 0, 0, 0, false
+	}
+
+	var m [3][4]float64
+	for i, th := range thetas {
+		row := [3]float64{1, math.Cos(th), math.Sin(th)}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				m[r][c] += row[r] * row[c]
+			}
+			m[r][3] += row[r] * values[i]
+		}
+	}
+	coef, ok := solve3(m)
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var ssRes, ssTot float64
+	for i, th := range thetas {
+		pred := coef[0] + coef[1]*math.Cos(th) + coef[2]*math.Sin(th)
+		ssRes += (values[i] - pred) * (values[i] - pred)
+		ssTot += (values[i] - mean) * (values[i] - mean)
+	}
+	r2 := 0.0
+	if ssTot > 0 {
+		r2 = math.Max(0, 1-ssRes/ssTot)
+	}
+
+	bearing := normalize(math.Atan2(coef[2], coef[1]) * 180 / math.Pi)
+	return bearing, math.Hypot(coef[1], coef[2]), r2, true
+}
+
+// solve3 solves a 3x3 linear system given as an augmented matrix using
+// Gaussian elimination with partial pivoting.
+func solve3(m [3][4]float64) ([3]float64, bool) {
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for r := col + 1; r < 3; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-9 {
+			return [3]float64{}, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for r := col + 1; r < 3; r++ {
+			factor := m[r][col] / m[col][col]
+			for c := col; c < 4; c++ {
+				m[r][c] -= factor * m[col][c]
+			}
+		}
+	}
+
+	var x [3]float64
+	for r := 2; r >= 0; r-- {
+		sum := m[r][3]
+		for c := r + 1; c < 3; c++ {
+			sum -= m[r][c] * x[c]
+		}
+		x[r] = sum / m[r][r]
+	}
+	return x, true
+}
+
+// turn returns the signed angle from heading to bearing in (-180, 180];
+// positive means clockwise (to the right).
+func turn(heading float64, bearing float64) float64 {
+	d := normalize(bearing - heading)
+	if d > 180 {
+		d -= 360
+	}
+	return d
+}
+
+func normalize(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
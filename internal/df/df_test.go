@@ -0,0 +1,186 @@
+package df
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+// angleDiff is the unsigned difference between two bearings in degrees.
+func angleDiff(a, b float64) float64 {
+	return math.Abs(turn(a, b))
+}
+
+func TestFitPatternRecoversBearing(t *testing.T) {
+	for _, tt := range []struct {
+		bearing float64
+		stepDeg float64
+		fromDeg float64
+		toDeg   float64
+	}{
+		{0, 30, 0, 360},
+		{45, 30, 0, 360},
+		{135, 10, 0, 360},
+		{200, 20, 0, 360},
+		{315, 45, 0, 360},
+		// Half a sweep is enough for an exact pattern.
+		{100, 15, 0, 180},
+		{350, 10, 270, 450},
+	} {
+		var thetas, values []float64
+		for deg := tt.fromDeg; deg < tt.toDeg; deg += tt.stepDeg {
+			th := deg * math.Pi / 180
+			thetas = append(thetas, th)
+			values = append(values, -60+8*math.Cos(th-tt.bearing*math.Pi/180))
+		}
+		bearing, amplitude, r2, ok := fitPattern(thetas, values)
+		if !ok {
+			t.Errorf("bearing %v: no fit", tt.bearing)
+			continue
+		}
+		if angleDiff(bearing, tt.bearing) > 1e-6 || !near(amplitude, 8, 1e-6) || !near(r2, 1, 1e-9) {
+			t.Errorf("bearing %v over %v-%v: got %v, amplitude %v, R² %v", tt.bearing, tt.fromDeg, tt.toDeg, bearing, amplitude, r2)
+		}
+	}
+}
+
+func TestFitPatternNoise(t *testing.T) {
+	var thetas, values []float64
+	for i := 0; i < 36; i++ {
+		th := float64(i*10) * math.Pi / 180
+		noise := []float64{1.5, -1, 0.5, -1.5, 1}[i%5]
+		thetas = append(thetas, th)
+		values = append(values, -70+6*math.Cos(th-250*math.Pi/180)+noise)
+	}
+	bearing, amplitude, r2, ok := fitPattern(thetas, values)
+	if !ok || angleDiff(bearing, 250) > 5 || !near(amplitude, 6, 0.5) || r2 < 0.8 || r2 >= 1 {
+		t.Errorf("noisy fit: bearing %v, amplitude %v, R² %v, ok %v", bearing, amplitude, r2, ok)
+	}
+
+	// A flat pattern has no direction.
+	flat := make([]float64, len(thetas))
+	for i := range flat {
+		flat[i] = -60
+	}
+	if _, amplitude, r2, ok := fitPattern(thetas, flat); !ok || amplitude > 1e-9 || r2 != 0 {
+		t.Errorf("flat pattern: amplitude %v, R² %v", amplitude, r2)
+	}
+}
+
+func TestFitPatternDegenerate(t *testing.T) {
+	if _, _, _, ok := fitPattern([]float64{0, 1, 2}, []float64{-50, -60, -70}); ok {
+		t.Error("fit from fewer than minFitBins bins")
+	}
+	// All bins at one heading (or opposite ones) cannot separate cos
+	// from sin: the normal equations are singular.
+	same := []float64{1, 1, 1, 1}
+	if _, _, _, ok := fitPattern(same, []float64{-50, -51, -52, -53}); ok {
+		t.Error("fit from a single heading")
+	}
+	line := []float64{0, math.Pi, 0, math.Pi}
+	if _, _, _, ok := fitPattern(line, []float64{-50, -60, -51, -61}); ok {
+		t.Error("fit from two opposite headings")
+	}
+}
+
+func TestSolve3(t *testing.T) {
+	// 2x + y - z = 8, -3x - y + 2z = -11, -2x + y + 2z = -3: x=2, y=3, z=-1.
+	x, ok := solve3([3][4]float64{
+		{2, 1, -1, 8},
+		{-3, -1, 2, -11},
+		{-2, 1, 2, -3},
+	})
+	if !ok || !near(x[0], 2, 1e-9) || !near(x[1], 3, 1e-9) || !near(x[2], -1, 1e-9) {
+		t.Errorf("solve3 = %v, %v; want [2 3 -1]", x, ok)
+	}
+
+	// A zero first pivot needs a row swap.
+	x, ok = solve3([3][4]float64{
+		{0, 1, 0, 5},
+		{1, 0, 0, 4},
+		{0, 0, 2, 6},
+	})
+	if !ok || x != [3]float64{4, 5, 3} {
+		t.Errorf("with pivoting: %v, %v; want [4 5 3]", x, ok)
+	}
+
+	for name, m := range map[string][3][4]float64{
+		"dependent rows": {{1, 2, 3, 1}, {2, 4, 6, 2}, {1, 0, 1, 3}},
+		"zero column":    {{1, 0, 3, 1}, {2, 0, 6, 2}, {1, 0, 1, 3}},
+		"all zero":       {},
+	} {
+		if x, ok := solve3(m); ok {
+			t.Errorf("%s: solved as %v", name, x)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	for _, tt := range []struct{ heading, bearing, want float64 }{
+		{0, 90, 90},
+		{90, 0, -90},
+		{350, 10, 20},
+		{10, 350, -20},
+		{0, 180, 180},
+		{180, 0, 180},
+		{45, 45, 0},
+		{-90, 90, 180},
+		{720, 30, 30},
+	} {
+		if got := turn(normalize(tt.heading), tt.bearing); !near(got, tt.want, 1e-9) {
+			t.Errorf("turn(%v, %v) = %v, want %v", tt.heading, tt.bearing, got, tt.want)
+		}
+	}
+}
+
+func TestFinderSweep(t *testing.T) {
+	f := &Finder{Smoothing: 1}
+	if _, ok := f.Snapshot(); ok {
+		t.Fatal("snapshot before any heading")
+	}
+
+	// A full turn past an AP at 90°, ending up facing 350°.
+	for deg := 0.0; deg < 360; deg += 10 {
+		f.SetHeading(deg)
+		dbm := -60 + 10*math.Cos((deg-90)*math.Pi/180)
+		f.Observe(model.Sample{IfName: "wlan0", BSSID: "aa", SignalDBM: int(math.Round(dbm))})
+	}
+	snap, ok := f.Snapshot()
+	if !ok || !snap.Fitted {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if angleDiff(snap.BearingDeg, 90) > 2 || snap.Coverage != 1 || snap.Confidence < 0.95 {
+		t.Errorf("bearing %v, coverage %v, confidence %v", snap.BearingDeg, snap.Coverage, snap.Confidence)
+	}
+	if snap.Guidance != GuidanceRight || !near(snap.TurnDeg, 100, 2) {
+		t.Errorf("facing 350: %s by %v, want right by about 100", snap.Guidance, snap.TurnDeg)
+	}
+	f.SetHeading(92)
+	if snap, _ := f.Snapshot(); snap.Guidance != GuidanceAhead {
+		t.Errorf("facing 92: %s, want ahead", snap.Guidance)
+	}
+	f.SetHeading(180)
+	if snap, _ := f.Snapshot(); snap.Guidance != GuidanceLeft {
+		t.Errorf("facing 180: %s, want left", snap.Guidance)
+	}
+
+	// A new BSS starts a new sweep.
+	f.Observe(model.Sample{IfName: "wlan0", BSSID: "bb", SignalDBM: -50})
+	if snap, _ := f.Snapshot(); len(snap.Bins) != 1 || snap.Fitted || snap.Guidance != GuidanceSweep {
+		t.Errorf("after a target change: %+v", snap)
+	}
+
+	// A stale heading stops tagging samples.
+	f.MaxHeadingAge = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	f.Observe(model.Sample{IfName: "wlan0", BSSID: "bb", SignalDBM: -40})
+	if snap, _ := f.Snapshot(); snap.Guidance != GuidanceNoHeading || snap.Bins[0].Count != 1 {
+		t.Errorf("with a stale heading: %+v", snap)
+	}
+}
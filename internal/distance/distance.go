@@ -0,0 +1,117 @@
+package distance
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	defaultFreqMHz     = 2437
+	defaultPathLossExp = 3.0
+)
+
+var ErrNoSignal = errors.New("no signal to estimate from")
+
+type Estimator struct {
+	TxPowerDBM  float64
+	PathLossExp float64
+	ShadowingDB float64
+
+	mu  sync.Mutex
+	ref *reference
+}
+
+// reference is the expected RSSI at 1 m, measured at freqMHz.
+type reference struct {
+	dbm     float64
+	freqMHz int
+}
+
+func (e *Estimator) Estimate(sample model.Sample) (model.Distance, bool) {
+	if sample.Lost || sample.SignalDBM == 0 {
+		return model.Distance{}, false
+	}
+
+	n := e.exponent()
+	ref, calibrated := e.refAt1m(sample.FreqMHz)
+	rssi := float64(sample.SignalDBM)
+	sigma := math.Abs(e.ShadowingDB)
+
+	return model.Distance{
+		Meters:      round1(metersFor(ref-rssi, n)),
+		MinMeters:   round1(metersFor(ref-rssi-sigma, n)),
+		MaxMeters:   round1(metersFor(ref-rssi+sigma, n)),
+		RefDBM:      round1(ref),
+		PathLossExp: n,
+		Calibrated:  calibrated,
+	}, true
+}
+
+// Calibrate records sample as taken at the given distance from the AP and
+// derives the 1 m reference level from it.
+func (e *Estimator) Calibrate(sample model.Sample, meters float64) (model.Distance, error) {
+	if sample.Lost || sample.SignalDBM == 0 {
+		return model.Distance{}, ErrNoSignal
+	}
+	if meters <= 0 {
+		meters = 1
+	}
+
+	ref := float64(sample.SignalDBM) + 10*e.exponent()*math.Log10(meters)
+	e.mu.Lock()
+	e.ref = &reference{dbm: ref, freqMHz: sample.FreqMHz}
+	e.mu.Unlock()
+
+	d, _ := e.Estimate(sample)
+	return d, nil
+}
+
+func (e *Estimator) Reset() {
+	e.mu.Lock()
+	e.ref = nil
+	e.mu.Unlock()
+}
+
+func (e *Estimator) exponent() float64 {
+	if e.PathLossExp <= 0 {
+		return defaultPathLossExp
+	}
+	return e.PathLossExp
+}
+
+func (e *Estimator) refAt1m(freqMHz int) (float64, bool) {
+	if freqMHz <= 0 {
+		freqMHz = defaultFreqMHz
+	}
+
+	e.mu.Lock()
+	ref := e.ref
+	e.mu.Unlock()
+
+	if ref != nil {
+		if ref.freqMHz <= 0 {
+			return ref.dbm, true
+		}
+		// Free-space loss grows with 20*log10(f), so shift the reference when
+		// the AP is heard on a different band than it was calibrated on.
+		return ref.dbm - 20*math.Log10(float64(freqMHz)/float64(ref.freqMHz)), true
+	}
+	return e.TxPowerDBM - freeSpaceLoss1m(freqMHz), false
+}
+
+// freeSpaceLoss1m is the free-space path loss in dB at 1 m for a frequency
+// in MHz.
+func freeSpaceLoss1m(freqMHz int) float64 {
+	return 20*math.Log10(float64(freqMHz)) - 27.55
+}
+
+func metersFor(lossDB float64, n float64) float64 {
+	return math.Pow(10, lossDB/(10*n))
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,374 @@
+// Package doctor checks whether this machine can collect samples: tools,
+// interface state, permissions and services that hold the interface.
+// Every failed check carries a fix the user can act on.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/caps"
+	"wifi-radar/internal/collector"
+	"wifi-radar/internal/scanhelper"
+)
+
+type Status string
+
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// Report is the result of Run; OK is false when any check failed.
+// Warnings do not fail the report.
+type Report struct {
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+type Options struct {
+	// IfNames are the interfaces to check; empty means every wireless
+	// interface.
+	IfNames []string
+	// StaticDir is the resolved web asset dir, or StaticErr why it could
+	// not be resolved. Both empty skips the check.
+	StaticDir string
+	StaticErr error
+	// Scan also runs one scan per interface, which takes seconds.
+	Scan bool
+}
+
+const commandTimeout = 5 * time.Second
+
+// Run performs all checks.
+func Run(opts Options) Report {
+	var r Report
+	add := func(c Check) { r.Checks = append(r.Checks, c) }
+
+	iwOK := checkIw(add)
+	privileged := checkCapabilities(add)
+	checkScanHelper(add, privileged)
+	if iwOK {
+		checkRegDomain(add)
+		for _, name := range checkInterfaces(add, opts.IfNames) {
+			checkRfkill(add, name)
+			checkServices(add, name)
+			if opts.Scan {
+				checkScan(add, name)
+			}
+		}
+	}
+	if opts.StaticDir != "" || opts.StaticErr != nil {
+		checkStaticDir(add, opts.StaticDir, opts.StaticErr)
+	}
+
+	r.OK = true
+	for _, c := range r.Checks {
+		if c.Status == Fail {
+			r.OK = false
+		}
+	}
+	return r
+}
+
+func checkIw(add func(Check)) bool {
+	path, err := exec.LookPath("iw")
+	if err != nil {
+		add(Check{Name: "iw", Status: Fail, Detail: "iw not found in PATH",
+			Fix: "install iw (apt install iw, dnf install iw or pacman -S iw)"})
+		return false
+	}
+	version, err := run("iw", "--version")
+	if err != nil {
+		add(Check{Name: "iw", Status: Fail, Detail: path + ": " + err.Error(),
+			Fix: "reinstall iw; running iw --version failed"})
+		return false
+	}
+	add(Check{Name: "iw", Status: OK, Detail: strings.TrimSpace(version) + " (" + path + ")"})
+	return true
+}
+
+type iface struct {
+	name, typ string
+}
+
+// checkInterfaces checks that the wanted interfaces exist, are managed
+// and up, and returns the ones worth checking further.
+func checkInterfaces(add func(Check), want []string) []string {
+	out, err := run("iw", "dev")
+	if err != nil {
+		add(Check{Name: "interfaces", Status: Fail, Detail: "iw dev: " + err.Error(),
+			Fix: "check that the cfg80211 driver for your card is loaded (lsmod, dmesg)"})
+		return nil
+	}
+	found := parseIwDev(out)
+	if len(want) == 0 {
+		if len(found) == 0 {
+			add(Check{Name: "interfaces", Status: Fail, Detail: "no wireless interfaces",
+				Fix: "plug in or enable a WiFi adapter; lsusb, lspci and dmesg show whether a driver is missing"})
+			return nil
+		}
+		for _, f := range found {
+			want = append(want, f.name)
+		}
+	}
+
+	var names []string
+	for _, name := range want {
+		check := Check{Name: "interface " + name}
+		typ, ok := "", false
+		for _, f := range found {
+			if f.name == name {
+				typ, ok = f.typ, true
+			}
+		}
+		if !ok {
+			check.Status, check.Detail = Fail, "no such wireless interface"
+			check.Fix = "use one of: " + ifaceNames(found)
+			if len(found) == 0 {
+				check.Fix = "plug in or enable a WiFi adapter"
+			}
+			add(check)
+			continue
+		}
+		names = append(names, name)
+
+		// operstate is "down" for an up interface without a connection,
+		// so look at IFF_UP instead.
+		up := flagsUp(readSysfs(sysNet, name, "flags"))
+		state := "down"
+		if up {
+			state = "up"
+		}
+		check.Status, check.Detail = OK, fmt.Sprintf("type %s, %s", typ, state)
+		switch {
+		case typ != "managed":
+			check.Status = Fail
+			check.Fix = fmt.Sprintf("scanning and link stats need managed mode: sudo ip link set %[1]s down && sudo iw dev %[1]s set type managed && sudo ip link set %[1]s up", name)
+		case !up:
+			check.Status = Fail
+			check.Fix = "bring the interface up: sudo ip link set " + name + " up"
+		}
+		add(check)
+	}
+	return names
+}
+
+func ifaceNames(ifs []iface) string {
+	names := make([]string, len(ifs))
+	for i, f := range ifs {
+		names[i] = f.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkRfkill reads the soft and hard block state of the interface's
+// phy from sysfs.
+func checkRfkill(add func(Check), name string) {
+	check := Check{Name: "rfkill " + name, Status: OK, Detail: "not blocked"}
+	block, found := rfkillBlock(name)
+	switch {
+	case !found:
+		check.Detail = "no rfkill switch"
+	case block == "hard":
+		check.Status, check.Detail = Fail, "hard blocked"
+		check.Fix = "turn on the wireless switch or Fn key, or enable WiFi in the BIOS"
+	case block == "soft":
+		check.Status, check.Detail = Fail, "soft blocked"
+		check.Fix = "sudo rfkill unblock wifi"
+	}
+	add(check)
+}
+
+// checkCapabilities reports whether the process may scan on its own:
+// root, or CAP_NET_ADMIN in its effective set. It also looks at file
+// capabilities on the binary so the fix can say what is missing.
+func checkCapabilities(add func(Check)) bool {
+	exe, _ := os.Executable()
+	if os.Geteuid() == 0 {
+		add(Check{Name: "capabilities", Status: OK, Detail: "running as root"})
+		return true
+	}
+	if caps.Has(caps.NetAdmin) {
+		add(Check{Name: "capabilities", Status: OK, Detail: "process has CAP_NET_ADMIN"})
+		return true
+	}
+
+	check := Check{Name: "capabilities", Status: OK,
+		Detail: "no CAP_NET_ADMIN; link mode works, scan mode needs the scan helper"}
+	if out, err := run("getcap", exe); err == nil && strings.Contains(strings.ToLower(out), "cap_net_admin") {
+		check.Status = Warn
+		check.Detail = "the binary has CAP_NET_ADMIN but the process does not"
+		check.Fix = "the file system may be mounted nosuid, or the binary was started through a wrapper that drops capabilities; run it directly"
+	}
+	add(check)
+	return false
+}
+
+// checkScanHelper checks the setcap'd helper that scans for an
+// unprivileged process.
+func checkScanHelper(add func(Check), privileged bool) {
+	const install = "go build -o /usr/local/bin/wifi-radar-scan ./cmd/wifi-radar-scan && sudo setcap cap_net_admin+ep /usr/local/bin/wifi-radar-scan"
+	path := collector.ScanHelper()
+	switch {
+	case privileged:
+		add(Check{Name: "scan helper", Status: OK, Detail: "not needed"})
+	case path == "":
+		add(Check{Name: "scan helper", Status: Fail,
+			Detail: scanhelper.Name + " not found next to the binary or in PATH; scan mode cannot work",
+			Fix:    install + " (or set WIFI_RADAR_SCAN_HELPER to its path)"})
+	default:
+		out, err := run("getcap", path)
+		switch {
+		case err != nil:
+			add(Check{Name: "scan helper", Status: OK, Detail: path + " (getcap unavailable, capabilities not verified)"})
+		case !strings.Contains(strings.ToLower(out), "cap_net_admin"):
+			add(Check{Name: "scan helper", Status: Fail, Detail: path + " has no CAP_NET_ADMIN",
+				Fix: "sudo setcap cap_net_admin+ep " + path})
+		default:
+			add(Check{Name: "scan helper", Status: OK, Detail: path + " has CAP_NET_ADMIN"})
+		}
+	}
+}
+
+// checkServices reports NetworkManager or wpa_supplicant managing the
+// interface. That is normal on a laptop but explains busy scans and
+// mode changes that get reverted.
+func checkServices(add func(Check), name string) {
+	var holders []string
+	if out, err := run("nmcli", "-t", "-f", "DEVICE,STATE", "device", "status"); err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			dev, state, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if ok && dev == name && state != "unmanaged" {
+				holders = append(holders, "NetworkManager ("+state+")")
+			}
+		}
+	}
+	for _, args := range processes("wpa_supplicant") {
+		if wpaInterface(args, name) {
+			holders = append(holders, "wpa_supplicant")
+			break
+		}
+	}
+
+	if len(holders) == 0 {
+		add(Check{Name: "services " + name, Status: OK, Detail: "not managed by NetworkManager or wpa_supplicant"})
+		return
+	}
+	add(Check{Name: "services " + name, Status: Warn,
+		Detail: "held by " + strings.Join(holders, " and "),
+		Fix:    "fine for link and scan mode; if scans fail with \"Device or resource busy\" or mode changes are reverted, run nmcli device set " + name + " managed no"})
+}
+
+// processes returns the command lines of running processes named comm.
+func processes(comm string) [][]string {
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	var found [][]string
+	for _, dir := range dirs {
+		if readSysfs(dir, "comm") != comm {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		found = append(found, strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"))
+	}
+	return found
+}
+
+func checkRegDomain(add func(Check)) {
+	out, err := run("iw", "reg", "get")
+	if err != nil {
+		add(Check{Name: "regulatory domain", Status: Warn, Detail: "iw reg get: " + err.Error()})
+		return
+	}
+	switch country := parseRegCountry(out); country {
+	case "":
+		add(Check{Name: "regulatory domain", Status: Warn, Detail: "not reported by iw reg get"})
+	case "00":
+		add(Check{Name: "regulatory domain", Status: Warn,
+			Detail: "world domain (00): channels 12-13 and most 5 GHz channels are passive or disabled, so networks there may be missing",
+			Fix:    "set your country, e.g. sudo iw reg set DE, or persist it in /etc/default/crda or the cfg80211 ieee80211_regdom option"})
+	default:
+		add(Check{Name: "regulatory domain", Status: OK, Detail: country})
+	}
+}
+
+func checkScan(add func(Check), name string) {
+	networks, err := collector.ScanNetworks(name, collector.ScanOptions{})
+	switch {
+	case errors.Is(err, collector.ErrPermission):
+		add(Check{Name: "scan " + name, Status: Fail, Detail: err.Error(),
+			Fix: "install the scan helper or give the binary CAP_NET_ADMIN (see scan helper)"})
+	case errors.Is(err, collector.ErrBusy):
+		add(Check{Name: "scan " + name, Status: Warn, Detail: err.Error(),
+			Fix: "another scan was running; try again, or stop NetworkManager's background scans with nmcli device set " + name + " managed no"})
+	case errors.Is(err, collector.ErrNetDown):
+		add(Check{Name: "scan " + name, Status: Fail, Detail: err.Error(),
+			Fix: "sudo ip link set " + name + " up"})
+	case errors.Is(err, collector.ErrNotSupported):
+		add(Check{Name: "scan " + name, Status: Fail, Detail: err.Error(),
+			Fix: "the driver refuses to scan; check iw phy for the interface's capabilities, or use another adapter"})
+	case err != nil:
+		add(Check{Name: "scan " + name, Status: Fail, Detail: err.Error(),
+			Fix: "run iw dev " + name + " scan yourself to see the driver's error; \"Device or resource busy\" usually means another scan is running"})
+	case len(networks) == 0:
+		add(Check{Name: "scan " + name, Status: Warn, Detail: "no networks found",
+			Fix: "check the regulatory domain and rfkill checks; some drivers return nothing until the interface has been up for a few seconds"})
+	default:
+		add(Check{Name: "scan " + name, Status: OK, Detail: fmt.Sprintf("%d networks", len(networks))})
+	}
+}
+
+func checkStaticDir(add func(Check), dir string, err error) {
+	if err != nil {
+		add(Check{Name: "static assets", Status: Fail, Detail: err.Error(),
+			Fix: "run from the repository root, keep web/static next to the binary, or set WIFI_RADAR_STATIC_DIR"})
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+		add(Check{Name: "static assets", Status: Fail, Detail: dir + " has no index.html",
+			Fix: "point WIFI_RADAR_STATIC_DIR at the web/static folder of a complete checkout"})
+		return
+	}
+	add(Check{Name: "static assets", Status: OK, Detail: dir})
+}
+
+// run runs a command with a timeout and returns its combined output;
+// on failure the error includes the output's first line.
+func run(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		msg, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+		if msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+func readSysfs(parts ...string) string {
+	data, err := os.ReadFile(filepath.Join(parts...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,96 @@
+package doctor
+
+import (
+	"bufio"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysNet is where network interfaces show up in sysfs; tests replace it.
+var sysNet = "/sys/class/net"
+
+// parseIwDev lists the interfaces in iw dev output. Devices without a
+// netdev, such as P2P-device, are skipped.
+func parseIwDev(out string) []iface {
+	var ifs []iface
+	var cur *iface
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Interface "):
+			ifs = append(ifs, iface{name: strings.TrimSpace(strings.TrimPrefix(line, "Interface "))})
+			cur = &ifs[len(ifs)-1]
+		case strings.HasPrefix(line, "phy#"), strings.HasPrefix(line, "Unnamed/non-netdev interface"):
+			cur = nil
+		case strings.HasPrefix(line, "type ") && cur != nil:
+			cur.typ = strings.TrimSpace(strings.TrimPrefix(line, "type "))
+		}
+	}
+	return ifs
+}
+
+// parseRegCountry returns the first country in iw reg get output, which
+// is the global domain when phys report their own.
+func parseRegCountry(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "country "); ok {
+			country, _, _ := strings.Cut(v, ":")
+			return country
+		}
+	}
+	return ""
+}
+
+// flagsUp reports whether a sysfs flags value such as 0x1003 has IFF_UP
+// set.
+func flagsUp(flags string) bool {
+	v, err := strconv.ParseUint(strings.TrimPrefix(flags, "0x"), 16, 64)
+	return err == nil && v&0x1 != 0
+}
+
+// rfkillBlock returns "hard", "soft" or "" for the rfkill switches of
+// the interface's phy; found is false when it has none. A hard block
+// wins over a soft one.
+func rfkillBlock(name string) (block string, found bool) {
+	dirs, _ := filepath.Glob(filepath.Join(sysNet, name, "phy80211", "rfkill*"))
+	for _, dir := range dirs {
+		switch {
+		case readSysfs(dir, "hard") == "1":
+			block = "hard"
+		case readSysfs(dir, "soft") == "1" && block == "":
+			block = "soft"
+		}
+	}
+	return block, len(dirs) > 0
+}
+
+// wpaArgOptions are wpa_supplicant's options that take an argument.
+const wpaArgOptions = "bcCDefgGiImoOpPz"
+
+// wpaInterface reports whether wpa_supplicant's arguments name the
+// interface: -iwlan0, -i wlan0, or -i inside a group such as -Bi wlan0.
+func wpaInterface(args []string, name string) bool {
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if len(a) < 2 || a[0] != '-' || a == "--" {
+			continue
+		}
+		for j := 1; j < len(a); j++ {
+			if !strings.ContainsRune(wpaArgOptions, rune(a[j])) {
+				continue
+			}
+			value := a[j+1:]
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			if a[j] == 'i' && value == name {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
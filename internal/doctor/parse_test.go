@@ -0,0 +1,163 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const iwDevLaptop = `phy#0
+	Unnamed/non-netdev interface
+		wdev 0x2
+		addr 3c:a9:f4:12:34:57
+		type P2P-device
+		txpower 0.00 dBm
+	Interface wlp2s0
+		ifindex 3
+		wdev 0x1
+		addr 3c:a9:f4:12:34:56
+		ssid Home
+		type managed
+		channel 36 (5180 MHz), width: 80 MHz, center1: 5210 MHz
+		txpower 22.00 dBm
+		multicast TXQ:
+			qsz-byts	qsz-pkts	flows	drops	marks	overlmt	hashcol	tx-bytes	tx-packets
+			0	0	0	0	0	0	0	0	0
+`
+
+// Two phys, the second listing its P2P device after the first phy's
+// interface.
+const iwDevTwoPhys = `phy#1
+	Interface wlx00c0ca123456
+		ifindex 5
+		wdev 0x100000001
+		addr 00:c0:ca:12:34:56
+		type monitor
+		channel 6 (2437 MHz), width: 20 MHz (no HT), center1: 2437 MHz
+		txpower 20.00 dBm
+phy#0
+	Unnamed/non-netdev interface
+		wdev 0x2
+		addr 3c:a9:f4:12:34:57
+		type P2P-device
+	Interface wlan0
+		ifindex 3
+		wdev 0x1
+		addr 3c:a9:f4:12:34:56
+		type managed
+		txpower 22.00 dBm
+`
+
+func TestParseIwDev(t *testing.T) {
+	for _, tt := range []struct {
+		name, out, want string
+	}{
+		{"none", "", "[]"},
+		{"laptop", iwDevLaptop, "[{wlp2s0 managed}]"},
+		{"two phys", iwDevTwoPhys, "[{wlx00c0ca123456 monitor} {wlan0 managed}]"},
+		{"ssid that looks like a type", "phy#0\n\tInterface wlan0\n\t\tssid type AP\n\t\ttype managed\n", "[{wlan0 managed}]"},
+	} {
+		if got := fmt.Sprintf("%v", parseIwDev(tt.out)); got != tt.want {
+			t.Errorf("%s: %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRegCountry(t *testing.T) {
+	for _, tt := range []struct {
+		name, out, want string
+	}{
+		{"empty", "", ""},
+		{"single", "country US: DFS-FCC\n\t(902 - 904 @ 2), (N/A, 30), (N/A)\n\t(2400 - 2472 @ 40), (N/A, 30), (N/A)\n", "US"},
+		{"world", "global\ncountry 00: DFS-UNSET\n\t(2402 - 2472 @ 40), (6, 20), (N/A)\n\t(2457 - 2482 @ 20), (6, 20), (N/A), AUTO-BW, PASSIVE-SCAN\n", "00"},
+		{
+			"global and self-managed phy",
+			"global\ncountry DE: DFS-ETSI\n\t(2400 - 2483 @ 40), (N/A, 20), (N/A)\n\t(5150 - 5250 @ 80), (N/A, 23), (N/A), NO-OUTDOOR, AUTO-BW\n\n" +
+				"phy#0 (self-managed)\ncountry 00: DFS-UNSET\n\t(2402 - 2437 @ 40), (6, 22), (N/A), AUTO-BW, NO-HT40MINUS, NO-80MHZ, NO-160MHZ\n",
+			"DE",
+		},
+	} {
+		if got := parseRegCountry(tt.out); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFlagsUp(t *testing.T) {
+	for flags, want := range map[string]bool{
+		"0x1003": true, // up, broadcast, multicast
+		"0x1002": false,
+		"0x1":    true,
+		"":       false,
+		"bogus":  false,
+	} {
+		if got := flagsUp(flags); got != want {
+			t.Errorf("flagsUp(%q) = %v", flags, got)
+		}
+	}
+}
+
+func TestRfkillBlock(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		switches [][2]string // hard, soft
+		block    string
+		found    bool
+	}{
+		{"no switch", nil, "", false},
+		{"not blocked", [][2]string{{"0", "0"}}, "", true},
+		{"soft", [][2]string{{"0", "1"}}, "soft", true},
+		{"hard", [][2]string{{"1", "0"}}, "hard", true},
+		{"hard and soft", [][2]string{{"1", "1"}}, "hard", true},
+		{"hard before soft", [][2]string{{"1", "0"}, {"0", "1"}}, "hard", true},
+		{"soft before hard", [][2]string{{"0", "1"}, {"1", "0"}}, "hard", true},
+	} {
+		dir := t.TempDir()
+		old := sysNet
+		sysNet = dir
+		phy := filepath.Join(dir, "wlan0", "phy80211")
+		if err := os.MkdirAll(phy, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for i, s := range tt.switches {
+			rfkill := filepath.Join(phy, fmt.Sprintf("rfkill%d", i))
+			os.Mkdir(rfkill, 0o755)
+			os.WriteFile(filepath.Join(rfkill, "hard"), []byte(s[0]+"\n"), 0o644)
+			os.WriteFile(filepath.Join(rfkill, "soft"), []byte(s[1]+"\n"), 0o644)
+		}
+		block, found := rfkillBlock("wlan0")
+		sysNet = old
+		if block != tt.block || found != tt.found {
+			t.Errorf("%s: %q %v, want %q %v", tt.name, block, found, tt.block, tt.found)
+		}
+	}
+}
+
+func TestWpaInterface(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		// D-Bus activated, as on most desktops: no interface on the
+		// command line.
+		{[]string{"/sbin/wpa_supplicant", "-u", "-s", "-O", "/run/wpa_supplicant"}, false},
+		{[]string{"/sbin/wpa_supplicant", "-u", "-s", "-O", "DIR=/run/wpa_supplicant GROUP=netdev"}, false},
+		// ifupdown and systemd's wpa_supplicant@wlan0.service.
+		{[]string{"/sbin/wpa_supplicant", "-s", "-B", "-P", "/run/wpa_supplicant.wlan0.pid", "-i", "wlan0", "-D", "nl80211,wext", "-c", "/etc/wpa_supplicant/wpa_supplicant.conf"}, true},
+		{[]string{"/usr/sbin/wpa_supplicant", "-c/etc/wpa_supplicant/wpa_supplicant-wlan0.conf", "-Dnl80211", "-iwlan0"}, true},
+		{[]string{"wpa_supplicant", "-B", "-iwlan01", "-c", "/etc/wpa.conf"}, false},
+		{[]string{"wpa_supplicant", "-Bi", "wlan0", "-c", "/etc/wpa.conf"}, true},
+		{[]string{"wpa_supplicant", "-Biwlan0"}, true},
+		// Another interface, then -N for a second one.
+		{[]string{"wpa_supplicant", "-i", "wlan1", "-c", "a.conf", "-N", "-i", "wlan0", "-c", "b.conf"}, true},
+		// wlan0 as the argument of another option.
+		{[]string{"wpa_supplicant", "-i", "wlan1", "-P", "wlan0"}, false},
+		{[]string{"wpa_supplicant", "-c", "-iwlan0", "-i", "wlan1"}, false},
+		{[]string{"wpa_supplicant", "-i"}, false},
+	} {
+		if got := wpaInterface(tt.args, "wlan0"); got != tt.want {
+			t.Errorf("wpaInterface(%q) = %v", tt.args, got)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	FormatWiGLE   = "wigle"
+	FormatKML     = "kml"
+	FormatGeoJSON = "geojson"
+	FormatCSV     = "csv"
+
+	wigleHeader = "WigleWifi-1.4,appRelease=wifi-radar,model=wifi-radar,release=1,device=wifi-radar,display=,board=,brand=wifi-radar"
+	wigleTime   = "2006-01-02 15:04:05"
+)
+
+var Formats = []string{FormatWiGLE, FormatKML, FormatGeoJSON, FormatCSV}
+
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	default:
+		return "text/csv"
+	}
+}
+
+func FileName(format string) string {
+	stamp := time.Now().Format("20060102-150405")
+	switch format {
+	case FormatWiGLE:
+		return "wifi-radar-" + stamp + ".wigle.csv"
+	default:
+		return "wifi-radar-" + stamp + "." + format
+	}
+}
+
+// Write encodes bsss in format. The WiGLE, KML and GeoJSON outputs only
+// include BSSes with a location; CSV includes all of them.
+func Write(w io.Writer, format string, bsss []model.BSS) error {
+	switch format {
+	case FormatWiGLE:
+		return writeWiGLE(w, bsss)
+	case FormatKML:
+		return writeKML(w, bsss)
+	case FormatGeoJSON:
+		return writeGeoJSON(w, bsss)
+	case FormatCSV:
+		return writeCSV(w, bsss)
+	default:
+		return fmt.Errorf("unknown export format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeWiGLE(w io.Writer, bsss []model.BSS) error {
+	if _, err := fmt.Fprintln(w, wigleHeader); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"MAC", "SSID", "AuthMode", "FirstSeen", "Channel", "RSSI",
+		"CurrentLatitude", "CurrentLongitude", "AltitudeMeters", "AccuracyMeters", "Type",
+	})
+	for _, b := range bsss {
+		if b.Location == nil {
+			continue
+		}
+		_ = cw.Write([]string{
+			b.BSSID,
+			b.SSID,
+			wigleAuthMode(b.Security),
+			time.UnixMilli(b.FirstSeenMS).UTC().Format(wigleTime),
+			strconv.Itoa(b.Channel),
+			strconv.Itoa(b.BestDBM),
+			formatCoord(b.Location.Lat),
+			formatCoord(b.Location.Lon),
+			formatFloat(b.Location.AltM),
+			formatFloat(b.Location.AccuracyM),
+			"WIFI",
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// wigleAuthMode renders security in the Android capabilities style WiGLE
+// expects, e.g. "[WPA2-PSK][ESS]".
+func wigleAuthMode(security string) string {
+	var b strings.Builder
+	if security != "" && security != "OPEN" {
+		for _, part := range strings.Split(security, "/") {
+			b.WriteString("[" + part + "]")
+		}
+	}
+	b.WriteString("[ESS]")
+	return b.String()
+}
+
+type kmlStyle struct {
+	id    string
+	color string
+}
+
+// KML colors are aabbggrr.
+var kmlStyles = []kmlStyle{
+	{"strong", "ff50c878"},
+	{"medium", "ff3cd2ff"},
+	{"weak", "ff3c3cdc"},
+}
+
+func signalClass(dbm int) string {
+	switch {
+	case dbm >= -60:
+		return "strong"
+	case dbm >= -75:
+		return "medium"
+	default:
+		return "weak"
+	}
+}
+
+func writeKML(w io.Writer, bsss []model.BSS) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2">` + "\n<Document>\n<name>WiFi Radar</name>\n")
+	for _, s := range kmlStyles {
+		fmt.Fprintf(&b, `<Style id="%s"><IconStyle><color>%s</color><Icon><href>http://maps.google.com/mapfiles/kml/shapes/placemark_circle.png</href></Icon></IconStyle></Style>`+"\n", s.id, s.color)
+	}
+	for _, bss := range bsss {
+		if bss.Location == nil {
+			continue
+		}
+		name := bss.SSID
+		if name == "" {
+			name = bss.BSSID
+		}
+		desc := fmt.Sprintf("BSSID: %s\nSecurity: %s\nChannel: %d\nBest signal: %d dBm\nFirst seen: %s\nLast seen: %s",
+			bss.BSSID, bss.Security, bss.Channel, bss.BestDBM,
+			time.UnixMilli(bss.FirstSeenMS).UTC().Format(time.RFC3339),
+			time.UnixMilli(bss.LastSeenMS).UTC().Format(time.RFC3339))
+
+		b.WriteString("<Placemark><name>")
+		xml.EscapeText(&b, []byte(name))
+		b.WriteString("</name><description>")
+		xml.EscapeText(&b, []byte(desc))
+		fmt.Fprintf(&b, "</description><styleUrl>#%s</styleUrl><Point><coordinates>%s,%s,%s</coordinates></Point></Placemark>\n",
+			signalClass(bss.BestDBM), formatCoord(bss.Location.Lon), formatCoord(bss.Location.Lat), formatFloat(bss.Location.AltM))
+	}
+	b.WriteString("</Document>\n</kml>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string         `json:"type"`
+	Geometry   geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func writeGeoJSON(w io.Writer, bsss []model.BSS) error {
+	fc := featureCollection{Type: "FeatureCollection", Features: []feature{}}
+	for _, b := range bsss {
+		if b.Location == nil {
+			continue
+		}
+		coords := []float64{b.Location.Lon, b.Location.Lat}
+		if b.Location.AltM != 0 {
+			coords = append(coords, b.Location.AltM)
+		}
+		fc.Features = append(fc.Features, feature{
+			Type:     "Feature",
+			Geometry: geometry{Type: "Point", Coordinates: coords},
+			Properties: map[string]any{
+				"bssid":              b.BSSID,
+				"ssid":               b.SSID,
+				"security":           b.Security,
+				"channel":            b.Channel,
+				"freq_mhz":           b.FreqMHz,
+				"best_dbm":           b.BestDBM,
+				"signal_class":       signalClass(b.BestDBM),
+				"observations":       b.Observations,
+				"first_seen_unix_ms": b.FirstSeenMS,
+				"last_seen_unix_ms":  b.LastSeenMS,
+			},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fc)
+}
+
+func writeCSV(w io.Writer, bsss []model.BSS) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"bssid", "ssid", "security", "channel", "freq_mhz", "best_dbm", "observations",
+		"first_seen", "last_seen", "lat", "lon", "alt_m", "accuracy_m",
+	})
+	for _, b := range bsss {
+		lat, lon, alt, acc := "", "", "", ""
+		if b.Location != nil {
+			lat = formatCoord(b.Location.Lat)
+			lon = formatCoord(b.Location.Lon)
+			alt = formatFloat(b.Location.AltM)
+			acc = formatFloat(b.Location.AccuracyM)
+		}
+		_ = cw.Write([]string{
+			b.BSSID,
+			b.SSID,
+			b.Security,
+			strconv.Itoa(b.Channel),
+			strconv.Itoa(b.FreqMHz),
+			strconv.Itoa(b.BestDBM),
+			strconv.Itoa(b.Observations),
+			time.UnixMilli(b.FirstSeenMS).UTC().Format(time.RFC3339),
+			time.UnixMilli(b.LastSeenMS).UTC().Format(time.RFC3339),
+			lat, lon, alt, acc,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 7, 64)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wifi-radar/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// t0 is 2024-03-01 12:00:00 UTC.
+const t0 = 1709294400000
+
+var fixture = []model.BSS{
+	{
+		BSSID: "aa:bb:cc:dd:ee:01", SSID: `Café "<Guest>" & co, ltd`, Security: "WPA2-PSK",
+		FreqMHz: 2437, Channel: 6, FirstSeenMS: t0, LastSeenMS: t0 + 90_000, BestDBM: -48, Observations: 12,
+		Location: &model.Location{Lat: 52.5200066, Lon: 13.404954, AltM: 34.5, AccuracyM: 4.2, Fix: 3},
+	},
+	{
+		BSSID: "aa:bb:cc:dd:ee:02", SSID: "", Security: "WPA2-PSK/SAE",
+		FreqMHz: 5180, Channel: 36, FirstSeenMS: t0 + 1000, LastSeenMS: t0 + 2000, BestDBM: -71, Observations: 2,
+		Location: &model.Location{Lat: -33.8688197, Lon: 151.2092955, AccuracyM: 12, Fix: 2},
+	},
+	{
+		BSSID: "aa:bb:cc:dd:ee:03", SSID: "open-net", Security: "OPEN",
+		FreqMHz: 2412, Channel: 1, FirstSeenMS: t0, LastSeenMS: t0, BestDBM: -88, Observations: 1,
+	},
+}
+
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+func TestFormatsGolden(t *testing.T) {
+	for format, file := range map[string]string{
+		FormatWiGLE:   "export.wigle.csv",
+		FormatKML:     "export.kml",
+		FormatGeoJSON: "export.geojson",
+		FormatCSV:     "export.csv",
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, fixture); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		golden(t, file, buf.Bytes())
+	}
+}
+
+func TestWiGLE(t *testing.T) {
+	var buf bytes.Buffer
+	Write(&buf, FormatWiGLE, fixture)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// WiGLE's importer wants its pre-header, then the column header.
+	if !strings.HasPrefix(lines[0], "WigleWifi-1.4,") || !strings.HasPrefix(lines[1], "MAC,SSID,AuthMode,FirstSeen,Channel,RSSI,") {
+		t.Errorf("headers = %q", lines[:2])
+	}
+	// The BSS without a location is left out.
+	if len(lines) != 4 || strings.Contains(buf.String(), "open-net") {
+		t.Errorf("rows = %q", lines[2:])
+	}
+	for security, want := range map[string]string{
+		"":             "[ESS]",
+		"OPEN":         "[ESS]",
+		"WPA2-PSK":     "[WPA2-PSK][ESS]",
+		"WPA2-PSK/SAE": "[WPA2-PSK][SAE][ESS]",
+	} {
+		if got := wigleAuthMode(security); got != want {
+			t.Errorf("wigleAuthMode(%q) = %q, want %q", security, got, want)
+		}
+	}
+}
+
+func TestGeoJSONCoordinates(t *testing.T) {
+	var buf bytes.Buffer
+	Write(&buf, FormatGeoJSON, fixture)
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 2 {
+		t.Fatalf("%d features, want the 2 with a location", len(fc.Features))
+	}
+	// GeoJSON is longitude first (RFC 7946); altitude only when known.
+	if c := fc.Features[0].Geometry.Coordinates; len(c) != 3 || c[0] != 13.404954 || c[1] != 52.5200066 || c[2] != 34.5 {
+		t.Errorf("Berlin coordinates = %v", c)
+	}
+	if c := fc.Features[1].Geometry.Coordinates; len(c) != 2 || c[0] != 151.2092955 || c[1] != -33.8688197 {
+		t.Errorf("Sydney coordinates = %v", c)
+	}
+	if p := fc.Features[0].Properties; p["ssid"] != fixture[0].SSID || p["signal_class"] != "strong" {
+		t.Errorf("properties = %v", p)
+	}
+}
+
+func TestKMLEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	Write(&buf, FormatKML, fixture)
+	kml := buf.String()
+	if !strings.Contains(kml, "<name>Café &#34;&lt;Guest&gt;&#34; &amp; co, ltd</name>") {
+		t.Errorf("SSID not escaped:\n%s", kml)
+	}
+	// A hidden network is named by its BSSID; KML is lon,lat,alt.
+	if !strings.Contains(kml, "<name>aa:bb:cc:dd:ee:02</name>") || !strings.Contains(kml, "<coordinates>151.2092955,-33.8688197,0</coordinates>") {
+		t.Errorf("hidden network placemark missing:\n%s", kml)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "gpx", fixture); err == nil || ValidFormat("gpx") {
+		t.Error("gpx accepted")
+	}
+	for _, f := range Formats {
+		if !ValidFormat(f) || ContentType(f) == "" || !strings.HasPrefix(FileName(f), "wifi-radar-") {
+			t.Errorf("format %s", f)
+		}
+	}
+	if !strings.HasSuffix(FileName(FormatWiGLE), ".wigle.csv") {
+		t.Errorf("WiGLE file name = %s", FileName(FormatWiGLE))
+	}
+}
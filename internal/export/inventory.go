@@ -0,0 +1,108 @@
+package export
+
+import (
+	"sort"
+	"sync"
+
+	"wifi-radar/internal/gps"
+	"wifi-radar/internal/model"
+)
+
+// Inventory remembers every BSS seen since start: when it was first and
+// last seen, its strongest reading and where that reading was taken.
+type Inventory struct {
+	// Locator, if set, supplies the RSSI-weighted location estimate used
+	// in place of the strongest sighting's location.
+	Locator *gps.Estimator
+
+	mu   sync.Mutex
+	bsss map[string]*entry
+}
+
+type entry struct {
+	bss model.BSS
+	// locDBM is the signal of the reading bss.Location was taken from.
+	locDBM int
+}
+
+func (inv *Inventory) Observe(networks []model.Sample) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if inv.bsss == nil {
+		inv.bsss = make(map[string]*entry)
+	}
+	for _, n := range networks {
+		if n.Lost || n.BSSID == "" {
+			continue
+		}
+		ts := n.TimestampUnixM
+		if ts == 0 {
+			ts = model.NowUnixMS()
+		}
+
+		e := inv.bsss[n.BSSID]
+		if e == nil {
+			e = &entry{bss: model.BSS{
+				BSSID:       n.BSSID,
+				FirstSeenMS: ts,
+				BestDBM:     n.SignalDBM,
+			}}
+			inv.bsss[n.BSSID] = e
+		}
+		b := &e.bss
+		if n.SSID != "" {
+			b.SSID = n.SSID
+		}
+		if n.Security != "" {
+			b.Security = n.Security
+		}
+		if n.FreqMHz != 0 {
+			b.FreqMHz = n.FreqMHz
+			b.Channel = model.Channel(n.FreqMHz)
+		}
+		if ts > b.LastSeenMS {
+			b.LastSeenMS = ts
+		}
+		b.BestDBM = max(b.BestDBM, n.SignalDBM)
+		if n.Location != nil && (b.Location == nil || n.SignalDBM > e.locDBM) {
+			b.Location = n.Location
+			e.locDBM = n.SignalDBM
+		}
+		b.Observations++
+	}
+}
+
+func (inv *Inventory) BSSes() []model.BSS {
+	inv.mu.Lock()
+	out := make([]model.BSS, 0, len(inv.bsss))
+	for _, e := range inv.bsss {
+		out = append(out, e.bss)
+	}
+	inv.mu.Unlock()
+
+	if inv.Locator != nil {
+		for i, b := range out {
+			est, ok := inv.Locator.Estimate(b.BSSID)
+			if !ok {
+				continue
+			}
+			loc := model.Location{Lat: est.Lat, Lon: est.Lon}
+			if b.Location != nil {
+				loc.AltM = b.Location.AltM
+				loc.AccuracyM = b.Location.AccuracyM
+				loc.Fix = b.Location.Fix
+				loc.TimestampUnixM = b.Location.TimestampUnixM
+			}
+			out[i].Location = &loc
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].FirstSeenMS == out[j].FirstSeenMS {
+			return out[i].BSSID < out[j].BSSID
+		}
+		return out[i].FirstSeenMS < out[j].FirstSeenMS
+	})
+	return out
+}
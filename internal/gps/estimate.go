@@ -0,0 +1,102 @@
+package gps
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"wifi-radar/internal/model"
+)
+
+// Estimator places each BSS at the RSSI-weighted centroid of the locations
+// it was heard from. Weights are received power in mW, so a reading 10 dB
+// stronger counts ten times as much.
+type Estimator struct {
+	mu   sync.Mutex
+	bsss map[string]*accumulator
+}
+
+type accumulator struct {
+	ssid    string
+	sumW    float64
+	sumLat  float64
+	sumLon  float64
+	count   int
+	best    int
+	bestLat float64
+	bestLon float64
+	hasBest bool
+}
+
+func (e *Estimator) Observe(networks []model.Sample) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.bsss == nil {
+		e.bsss = make(map[string]*accumulator)
+	}
+	for _, n := range networks {
+		if n.Location == nil || n.Lost || n.BSSID == "" || n.SignalDBM == 0 {
+			continue
+		}
+		acc := e.bsss[n.BSSID]
+		if acc == nil {
+			acc = &accumulator{}
+			e.bsss[n.BSSID] = acc
+		}
+		if n.SSID != "" {
+			acc.ssid = n.SSID
+		}
+
+		w := math.Pow(10, float64(n.SignalDBM)/10)
+		acc.sumW += w
+		acc.sumLat += w * n.Location.Lat
+		acc.sumLon += w * n.Location.Lon
+		acc.count++
+		if !acc.hasBest || n.SignalDBM > acc.best {
+			acc.best = n.SignalDBM
+			acc.bestLat = n.Location.Lat
+			acc.bestLon = n.Location.Lon
+			acc.hasBest = true
+		}
+	}
+}
+
+func (e *Estimator) Estimate(bssid string) (model.LocationEstimate, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	acc := e.bsss[bssid]
+	if acc == nil || acc.sumW == 0 {
+		return model.LocationEstimate{}, false
+	}
+	return acc.estimate(bssid), true
+}
+
+func (e *Estimator) Estimates() []model.LocationEstimate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out := make([]model.LocationEstimate, 0, len(e.bsss))
+	for bssid, acc := range e.bsss {
+		if acc.sumW == 0 {
+			continue
+		}
+		out = append(out, acc.estimate(bssid))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BSSID < out[j].BSSID })
+	return out
+}
+
+func (a *accumulator) estimate(bssid string) model.LocationEstimate {
+	return model.LocationEstimate{
+		BSSID:        bssid,
+		SSID:         a.ssid,
+		Lat:          a.sumLat / a.sumW,
+		Lon:          a.sumLon / a.sumW,
+		Observations: a.count,
+		BestDBM:      a.best,
+		BestLat:      a.bestLat,
+		BestLon:      a.bestLon,
+	}
+}
@@ -0,0 +1,181 @@
+package gps
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	DefaultAddr = "localhost:2947"
+
+	defaultMaxAge = 5 * time.Second
+	dialTimeout   = 5 * time.Second
+	readTimeout   = 10 * time.Second
+	maxBackoff    = 30 * time.Second
+	maxLine       = 1 << 20
+
+	watchCommand = `?WATCH={"enable":true,"json":true};` + "\n"
+)
+
+// Client follows a gpsd daemon over its JSON protocol and keeps the latest
+// position fix.
+type Client struct {
+	Addr string
+	// MaxAge is how long a fix is used after gpsd last reported it.
+	MaxAge time.Duration
+
+	mu  sync.RWMutex
+	fix *model.Location
+	at  time.Time
+}
+
+type tpv struct {
+	Class  string   `json:"class"`
+	Mode   int      `json:"mode"`
+	Time   string   `json:"time"`
+	Lat    *float64 `json:"lat"`
+	Lon    *float64 `json:"lon"`
+	Alt    *float64 `json:"alt"`
+	AltHAE *float64 `json:"altHAE"`
+	Eph    *float64 `json:"eph"`
+	Epx    *float64 `json:"epx"`
+	Epy    *float64 `json:"epy"`
+	Speed  *float64 `json:"speed"`
+}
+
+// Run connects to gpsd and reads reports until ctx is done, reconnecting
+// with backoff when the connection fails.
+func (c *Client) Run(ctx context.Context) {
+	backoff := time.Second
+	failing := false
+	for {
+		connected, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		// Log lost connections, but only the first of a run of failed dials.
+		if connected || !failing {
+			log.Printf("gpsd %s: %v; reconnecting", c.addr(), err)
+		}
+		failing = !connected
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if !connected {
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// Location returns the latest fix, or nil when there is no fresh 2D/3D fix.
+func (c *Client) Location() *model.Location {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	maxAge := c.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxAge
+	}
+	if c.fix == nil || time.Since(c.at) > maxAge {
+		return nil
+	}
+	fix := *c.fix
+	return &fix
+}
+
+// session reads reports from one connection. It reports whether the
+// connection was established before it failed.
+func (c *Client) session(ctx context.Context) (bool, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr())
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := conn.Write([]byte(watchCommand)); err != nil {
+		return true, fmt.Errorf("send watch: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return true, err
+			}
+			return true, fmt.Errorf("connection closed")
+		}
+		c.handle(scanner.Bytes())
+	}
+}
+
+func (c *Client) handle(line []byte) {
+	var report tpv
+	if err := json.Unmarshal(line, &report); err != nil || report.Class != "TPV" {
+		return
+	}
+	if report.Mode < 2 || report.Lat == nil || report.Lon == nil {
+		c.mu.Lock()
+		c.fix = nil
+		c.mu.Unlock()
+		return
+	}
+
+	fix := &model.Location{
+		Lat:            *report.Lat,
+		Lon:            *report.Lon,
+		Fix:            report.Mode,
+		TimestampUnixM: model.NowUnixMS(),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, report.Time); err == nil {
+		fix.TimestampUnixM = t.UnixMilli()
+	}
+	if report.Mode >= 3 {
+		if report.AltHAE != nil {
+			fix.AltM = *report.AltHAE
+		} else if report.Alt != nil {
+			fix.AltM = *report.Alt
+		}
+	}
+	switch {
+	case report.Eph != nil:
+		fix.AccuracyM = *report.Eph
+	case report.Epx != nil && report.Epy != nil:
+		fix.AccuracyM = math.Hypot(*report.Epx, *report.Epy)
+	}
+	if report.Speed != nil {
+		fix.SpeedMPS = *report.Speed
+	}
+
+	c.mu.Lock()
+	c.fix = fix
+	c.at = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *Client) addr() string {
+	if c.Addr == "" {
+		return DefaultAddr
+	}
+	return c.Addr
+}
@@ -0,0 +1,176 @@
+package gps
+
+import (
+	"bufio"
+	"context"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+// fakeGPSD accepts connections on a local port and hands each one to the
+// next session function.
+type fakeGPSD struct {
+	ln       net.Listener
+	watches  chan string
+	sessions []func(conn net.Conn)
+}
+
+func newFakeGPSD(t *testing.T, sessions ...func(conn net.Conn)) *fakeGPSD {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeGPSD{ln: ln, watches: make(chan string, len(sessions)), sessions: sessions}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeGPSD) serve() {
+	for _, session := range f.sessions {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		f.watches <- line
+		conn.Write([]byte(`{"class":"VERSION","release":"3.25","proto_major":3,"proto_minor":15}` + "\n"))
+		session(conn)
+	}
+}
+
+func send(conn net.Conn, lines ...string) {
+	for _, line := range lines {
+		conn.Write([]byte(line + "\n"))
+	}
+}
+
+// waitFor polls the client until check accepts its location.
+func waitFor(t *testing.T, c *Client, what string, check func(*model.Location) bool) *model.Location {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if loc := c.Location(); check(loc) {
+			return loc
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s; last location %+v", what, c.Location())
+	return nil
+}
+
+func TestClientFollowsGPSD(t *testing.T) {
+	dropped := make(chan struct{})
+	fake := newFakeGPSD(t,
+		func(conn net.Conn) {
+			send(conn,
+				`{"class":"SKY","satellites":[]}`,
+				`{"class":"TPV","mode":3,"time":"2024-05-01T12:00:00.000Z","lat":52.52,"lon":13.405,"altHAE":48.5,"alt":34.2,"epx":3,"epy":4,"speed":1.25}`,
+			)
+			<-dropped
+			conn.Close()
+		},
+		func(conn net.Conn) {
+			defer conn.Close()
+			send(conn,
+				`{"class":"TPV","mode":2,"lat":48.1,"lon":11.6,"alt":500,"eph":7.5}`,
+			)
+			time.Sleep(time.Second)
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &Client{Addr: fake.ln.Addr().String(), MaxAge: time.Minute}
+	go c.Run(ctx)
+
+	if watch := <-fake.watches; !strings.HasPrefix(watch, `?WATCH={"enable":true,"json":true}`) {
+		t.Errorf("handshake = %q, want a ?WATCH command", watch)
+	}
+
+	fix := waitFor(t, c, "3D fix", func(l *model.Location) bool { return l != nil })
+	want := model.Location{
+		Lat: 52.52, Lon: 13.405, AltM: 48.5, AccuracyM: 5, SpeedMPS: 1.25, Fix: 3,
+		TimestampUnixM: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).UnixMilli(),
+	}
+	if *fix != want {
+		t.Errorf("3D fix = %+v, want %+v", *fix, want)
+	}
+
+	// After the server drops the connection the client dials again.
+	close(dropped)
+	if watch := <-fake.watches; !strings.HasPrefix(watch, "?WATCH=") {
+		t.Errorf("reconnect handshake = %q", watch)
+	}
+	fix = waitFor(t, c, "2D fix after reconnect", func(l *model.Location) bool { return l != nil && l.Lat == 48.1 })
+	if fix.Fix != 2 || fix.AltM != 0 || fix.AccuracyM != 7.5 || fix.Lon != 11.6 {
+		t.Errorf("2D fix = %+v; want mode 2, no altitude, eph accuracy", *fix)
+	}
+}
+
+func TestClientHandle(t *testing.T) {
+	c := &Client{MaxAge: time.Minute}
+	c.handle([]byte(`{"class":"TPV","mode":3,"lat":1,"lon":2}`))
+	if c.Location() == nil {
+		t.Fatal("no fix after a 3D TPV")
+	}
+	for _, line := range []string{
+		`{"class":"DEVICES"}`,
+		`not json`,
+	} {
+		c.handle([]byte(line))
+		if c.Location() == nil {
+			t.Errorf("%s cleared the fix", line)
+		}
+	}
+	c.handle([]byte(`{"class":"TPV","mode":1}`))
+	if loc := c.Location(); loc != nil {
+		t.Errorf("fix after mode 1 = %+v, want nil", loc)
+	}
+
+	c.handle([]byte(`{"class":"TPV","mode":2,"lat":1,"lon":2}`))
+	c.mu.Lock()
+	c.at = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+	if loc := c.Location(); loc != nil {
+		t.Errorf("stale fix = %+v, want nil", loc)
+	}
+}
+
+func TestEstimatorCentroid(t *testing.T) {
+	at := func(lat, lon float64) *model.Location { return &model.Location{Lat: lat, Lon: lon} }
+	var e Estimator
+	e.Observe([]model.Sample{
+		{BSSID: "aa", SSID: "Home", SignalDBM: -50, Location: at(10, 20)},
+		{BSSID: "bb", SignalDBM: -70, Location: at(0, 0)},
+		{BSSID: "cc", SignalDBM: -40}, // no location
+	})
+	e.Observe([]model.Sample{
+		// 10 dB weaker, so it weighs a tenth as much.
+		{BSSID: "aa", SignalDBM: -60, Location: at(21, 31)},
+		{BSSID: "aa", SignalDBM: -30, Location: at(50, 50), Lost: true},
+	})
+
+	est, ok := e.Estimate("aa")
+	if !ok {
+		t.Fatal("no estimate for aa")
+	}
+	if math.Abs(est.Lat-11) > 1e-9 || math.Abs(est.Lon-21) > 1e-9 {
+		t.Errorf("centroid = %v, %v; want 11, 21", est.Lat, est.Lon)
+	}
+	if est.SSID != "Home" || est.Observations != 2 || est.BestDBM != -50 || est.BestLat != 10 || est.BestLon != 20 {
+		t.Errorf("estimate = %+v", est)
+	}
+	if _, ok := e.Estimate("cc"); ok {
+		t.Error("estimate for a BSS never heard with a location")
+	}
+	if all := e.Estimates(); len(all) != 2 || all[0].BSSID != "aa" || all[1].BSSID != "bb" {
+		t.Errorf("Estimates() = %+v, want aa and bb in order", all)
+	}
+}
@@ -0,0 +1,207 @@
+package hunt
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	defaultWindow   = 5 * time.Second
+	defaultMinSlope = 0.3
+	maxTStat        = 999
+)
+
+const (
+	TrendWarmer = "warmer"
+	TrendColder = "colder"
+	TrendSteady = "steady"
+)
+
+// tCritical95 holds two-sided 95% Student t critical values indexed by
+// degrees of freedom; larger samples use the normal approximation.
+var tCritical95 = []float64{
+	0, 12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262,
+	2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093,
+	2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045,
+	2.042,
+}
+
+type Tracker struct {
+	Window   time.Duration
+	MinSlope float64
+
+	mu      sync.Mutex
+	ifname  string
+	bssid   string
+	started int64
+	points  []point
+	peak    point
+	hasPeak bool
+}
+
+type point struct {
+	tsMS int64
+	dbm  int
+}
+
+func (t *Tracker) Observe(sample model.Sample) {
+	if sample.Lost || sample.SignalDBM == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ifname == "" {
+		t.ifname = sample.IfName
+	}
+	if sample.IfName != t.ifname {
+		return
+	}
+	if sample.BSSID != "" && t.bssid != "" && sample.BSSID != t.bssid {
+		t.resetLocked()
+		t.ifname = sample.IfName
+	}
+	t.bssid = sample.BSSID
+
+	p := point{tsMS: sample.TimestampUnixM, dbm: sample.SignalDBM}
+	if t.started == 0 {
+		t.started = p.tsMS
+	}
+	if !t.hasPeak || p.dbm > t.peak.dbm {
+		t.peak = p
+		t.hasPeak = true
+	}
+
+	t.points = append(t.points, p)
+	cutoff := p.tsMS - t.window().Milliseconds()
+	drop := 0
+	for drop < len(t.points) && t.points[drop].tsMS < cutoff {
+		drop++
+	}
+	t.points = t.points[drop:]
+}
+
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	t.resetLocked()
+	t.mu.Unlock()
+}
+
+// Snapshot reports the trend over the window. The slope only counts as
+// warmer or colder when it is both statistically significant and steeper
+// than MinSlope dB/s.
+func (t *Tracker) Snapshot() (model.Hunt, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.points) == 0 {
+		return model.Hunt{}, false
+	}
+
+	last := t.points[len(t.points)-1]
+	out := model.Hunt{
+		Trend:          TrendSteady,
+		Samples:        len(t.points),
+		WindowMS:       t.window().Milliseconds(),
+		SignalDBM:      last.dbm,
+		PeakDBM:        t.peak.dbm,
+		PeakUnixMS:     t.peak.tsMS,
+		SincePeakMS:    model.NowUnixMS() - t.peak.tsMS,
+		SessionStartMS: t.started,
+	}
+
+	slope, tStat, df, ok := regress(t.points)
+	if !ok {
+		return out, true
+	}
+	out.SlopeDBPerSec = math.Round(slope*100) / 100
+	out.TStat = math.Round(tStat*100) / 100
+	out.Significant = math.Abs(tStat) >= critical(df)
+	if out.Significant && math.Abs(slope) >= t.minSlope() {
+		if slope > 0 {
+			out.Trend = TrendWarmer
+		} else {
+			out.Trend = TrendColder
+		}
+	}
+	return out, true
+}
+
+func (t *Tracker) resetLocked() {
+	t.ifname = ""
+	t.bssid = ""
+	t.started = 0
+	t.points = nil
+	t.hasPeak = false
+}
+
+func (t *Tracker) window() time.Duration {
+	if t.Window <= 0 {
+		return defaultWindow
+	}
+	return t.Window
+}
+
+func (t *Tracker) minSlope() float64 {
+	if t.MinSlope <= 0 {
+		return defaultMinSlope
+	}
+	return t.MinSlope
+}
+
+// regress fits dBm over time in seconds and returns the slope, its t
+// statistic and the residual degrees of freedom.
+func regress(points []point) (float64, float64, int, bool) {
+	n := len(points)
+	if n < 3 {
+		return 0, 0, 0, false
+	}
+
+	origin := points[0].tsMS
+	var meanX, meanY float64
+	for _, p := range points {
+		meanX += float64(p.tsMS-origin) / 1000
+		meanY += float64(p.dbm)
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sxx, sxy float64
+	for _, p := range points {
+		dx := float64(p.tsMS-origin)/1000 - meanX
+		sxx += dx * dx
+		sxy += dx * (float64(p.dbm) - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+	slope := sxy / sxx
+
+	var ssRes float64
+	for _, p := range points {
+		x := float64(p.tsMS-origin)/1000 - meanX
+		r := float64(p.dbm) - meanY - slope*x
+		ssRes += r * r
+	}
+	df := n - 2
+	se := math.Sqrt(ssRes / float64(df) / sxx)
+	if se == 0 {
+		// A perfect fit; cap t so it stays representable in JSON.
+		if slope == 0 {
+			return 0, 0, df, true
+		}
+		return slope, math.Copysign(maxTStat, slope), df, true
+	}
+	return slope, math.Max(-maxTStat, math.Min(maxTStat, slope/se)), df, true
+}
+
+func critical(df int) float64 {
+	if df < len(tCritical95) {
+		return tCritical95[df]
+	}
+	return 1.96
+}
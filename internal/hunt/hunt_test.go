@@ -0,0 +1,129 @@
+package hunt
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+// series returns points every stepMS starting at t0 with the given dBm.
+func series(t0 int64, stepMS int64, dbm ...int) []point {
+	out := make([]point, len(dbm))
+	for i, v := range dbm {
+		out[i] = point{tsMS: t0 + int64(i)*stepMS, dbm: v}
+	}
+	return out
+}
+
+func TestRegressKnownValues(t *testing.T) {
+	// x = 0, 1, 2 s; y = -60, -59, -57: slope 1.5, se sqrt(1/12), t 5.196.
+	slope, tStat, df, ok := regress(series(1000, 1000, -60, -59, -57))
+	if !ok || df != 1 || math.Abs(slope-1.5) > 1e-9 || math.Abs(tStat-1.5/math.Sqrt(1.0/12)) > 1e-9 {
+		t.Errorf("regress = %v, %v, %d, %v; want 1.5, 5.196, 1", slope, tStat, df, ok)
+	}
+	// With one degree of freedom that is not significant at 95%.
+	if math.Abs(tStat) >= critical(df) {
+		t.Errorf("t %v passes the critical value %v", tStat, critical(df))
+	}
+
+	if _, _, _, ok := regress(series(0, 100, -60, -50)); ok {
+		t.Error("regression from two points")
+	}
+	if _, _, _, ok := regress([]point{{5, -60}, {5, -50}, {5, -55}}); ok {
+		t.Error("regression with no spread in time")
+	}
+	// A perfect fit caps t instead of dividing by zero.
+	if slope, tStat, _, ok := regress(series(0, 500, -70, -69, -68, -67)); !ok || slope != 2 || tStat != maxTStat {
+		t.Errorf("perfect rise: slope %v, t %v", slope, tStat)
+	}
+	if slope, tStat, _, _ := regress(series(0, 500, -67, -68, -69, -70)); slope != -2 || tStat != -maxTStat {
+		t.Errorf("perfect fall: slope %v, t %v", slope, tStat)
+	}
+	if slope, tStat, _, ok := regress(series(0, 500, -60, -60, -60)); !ok || slope != 0 || tStat != 0 {
+		t.Errorf("flat: slope %v, t %v", slope, tStat)
+	}
+}
+
+func TestCritical(t *testing.T) {
+	for df, want := range map[int]float64{1: 12.706, 2: 4.303, 10: 2.228, 30: 2.042, 31: 1.96, 1000: 1.96} {
+		if got := critical(df); got != want {
+			t.Errorf("critical(%d) = %v, want %v", df, got, want)
+		}
+	}
+	for df := 2; df < len(tCritical95); df++ {
+		if tCritical95[df] >= tCritical95[df-1] || tCritical95[df] <= 1.96 {
+			t.Errorf("tCritical95[%d] = %v out of order", df, tCritical95[df])
+		}
+	}
+}
+
+func TestTrend(t *testing.T) {
+	noise := []int{1, -1, 0, 1, -1, 0, -1, 1}
+	// trendSeries goes from -70 at slope dB/s over 5 s in 250 ms steps,
+	// with ±1 dB of noise.
+	trendSeries := func(slope float64) []int {
+		var out []int
+		for i := 0; i < 20; i++ {
+			out = append(out, int(math.Round(-70+slope*float64(i)*0.25))+noise[i%len(noise)])
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		name        string
+		dbm         []int
+		minSlope    float64
+		trend       string
+		significant bool
+	}{
+		{"rising", trendSeries(2), 0, TrendWarmer, true},
+		{"falling", trendSeries(-2), 0, TrendColder, true},
+		{"noise only", trendSeries(0), 0, TrendSteady, false},
+		// Significant but shallower than MinSlope.
+		{"slow rise", trendSeries(1), 3, TrendSteady, true},
+		{"too few samples", []int{-70, -60}, 0, TrendSteady, false},
+	} {
+		tr := &Tracker{Window: 10 * time.Second, MinSlope: tt.minSlope}
+		t0 := model.NowUnixMS() - 5000
+		for i, dbm := range tt.dbm {
+			tr.Observe(model.Sample{IfName: "wlan0", BSSID: "aa", SignalDBM: dbm, TimestampUnixM: t0 + int64(i)*250})
+		}
+		h, ok := tr.Snapshot()
+		if !ok || h.Trend != tt.trend || h.Significant != tt.significant {
+			t.Errorf("%s: trend %s (slope %v, t %v, significant %v), want %s, %v",
+				tt.name, h.Trend, h.SlopeDBPerSec, h.TStat, h.Significant, tt.trend, tt.significant)
+		}
+		if tt.significant && (h.SlopeDBPerSec > 0) != (tt.trend != TrendColder) {
+			t.Errorf("%s: slope sign %v", tt.name, h.SlopeDBPerSec)
+		}
+	}
+}
+
+func TestTrackerWindowAndPeak(t *testing.T) {
+	tr := &Tracker{Window: time.Second}
+	if _, ok := tr.Snapshot(); ok {
+		t.Fatal("snapshot with no samples")
+	}
+	t0 := model.NowUnixMS() - 3000
+	for i, dbm := range []int{-70, -50, -60, -65, -66, -67} {
+		tr.Observe(model.Sample{IfName: "wlan0", BSSID: "aa", SignalDBM: dbm, TimestampUnixM: t0 + int64(i)*400})
+	}
+	// Lost and zero samples and other interfaces are ignored.
+	tr.Observe(model.Sample{IfName: "wlan0", BSSID: "aa", Lost: true, SignalDBM: -100, TimestampUnixM: t0 + 2400})
+	tr.Observe(model.Sample{IfName: "wlan1", BSSID: "aa", SignalDBM: -30, TimestampUnixM: t0 + 2400})
+
+	h, _ := tr.Snapshot()
+	// Only the samples within a second of the newest are kept.
+	if h.Samples != 3 || h.SignalDBM != -67 || h.PeakDBM != -50 || h.PeakUnixMS != t0+400 || h.SessionStartMS != t0 {
+		t.Errorf("snapshot = %+v", h)
+	}
+	if h.SincePeakMS < 2600 {
+		t.Errorf("since peak %d ms", h.SincePeakMS)
+	}
+
+	tr.Observe(model.Sample{IfName: "wlan0", BSSID: "bb", SignalDBM: -80, TimestampUnixM: t0 + 2800})
+	if h, _ := tr.Snapshot(); h.Samples != 1 || h.PeakDBM != -80 || h.SessionStartMS != t0+2800 {
+		t.Errorf("after a target change: %+v", h)
+	}
+}
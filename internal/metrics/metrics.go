@@ -0,0 +1,195 @@
+// Package metrics implements the few Prometheus metric types the app needs
+// and writes them in the text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type Counter struct {
+	v atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// CounterVec is a set of counters told apart by the value of one label.
+type CounterVec struct {
+	mu     sync.Mutex
+	values map[string]*Counter
+}
+
+func (v *CounterVec) With(label string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.values == nil {
+		v.values = make(map[string]*Counter)
+	}
+	c := v.values[label]
+	if c == nil {
+		c = &Counter{}
+		v.values[label] = c
+	}
+	return c
+}
+
+func (v *CounterVec) snapshot() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make(map[string]uint64, len(v.values))
+	for label, c := range v.values {
+		out[label] = c.Value()
+	}
+	return out
+}
+
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram returns a histogram with the given upper bounds, which must
+// be increasing. The +Inf bucket is implicit.
+func NewHistogram(buckets ...float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Writer writes metric families. Samples of one family must be written
+// right after its Family call.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) Family(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one value; labels are name/value pairs.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+func (w *Writer) Gauge(name, help string, value float64) {
+	w.Family(name, help, "gauge")
+	w.Sample(name, value)
+}
+
+func (w *Writer) Counter(name, help string, c *Counter) {
+	w.Family(name, help, "counter")
+	w.Sample(name, float64(c.Value()))
+}
+
+func (w *Writer) CounterVec(name, help, label string, v *CounterVec) {
+	w.Family(name, help, "counter")
+	values := v.snapshot()
+	labels := make([]string, 0, len(values))
+	for l := range values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		w.Sample(name, float64(values[l]), label, l)
+	}
+}
+
+func (w *Writer) Histogram(name, help string, h *Histogram) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	w.Family(name, help, "histogram")
+	for i, upper := range h.buckets {
+		w.Sample(name+"_bucket", float64(counts[i]), "le", formatValue(upper))
+	}
+	w.Sample(name+"_bucket", float64(count), "le", "+Inf")
+	w.Sample(name+"_sum", sum)
+	w.Sample(name+"_count", float64(count))
+}
+
+func (w *Writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
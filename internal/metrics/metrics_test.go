@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriterGolden(t *testing.T) {
+	var c Counter
+	c.Inc()
+	c.Inc()
+	var v CounterVec
+	v.With("permission").Inc()
+	v.With("busy").Inc()
+	v.With("busy").Inc()
+	v.With(`a "quoted"` + "\nlabel\\").Inc()
+	h := NewHistogram(0.5, 1, 2.5)
+	for _, x := range []float64{0.2, 0.5, 0.7, 3, 10} {
+		h.Observe(x)
+	}
+
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Gauge("wifi_radar_signal_dbm", "Signal strength\nin dBm, path C:\\wifi.", -52.5)
+	w.Counter("wifi_radar_scans_total", "Scans run.", &c)
+	w.CounterVec("wifi_radar_scan_errors_total", "Scan errors by type.", "type", &v)
+	w.Histogram("wifi_radar_scan_seconds", "Scan duration.", h)
+	w.Family("wifi_radar_special", "Special values.", "gauge")
+	w.Sample("wifi_radar_special", math.Inf(1), "v", "inf")
+	w.Sample("wifi_radar_special", math.Inf(-1), "v", "-inf")
+	w.Sample("wifi_radar_special", math.NaN(), "v", "nan")
+	w.Sample("wifi_radar_special", 1e-7, "v", "small", "unit", "s")
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP wifi_radar_signal_dbm Signal strength\nin dBm, path C:\\wifi.
+# TYPE wifi_radar_signal_dbm gauge
+wifi_radar_signal_dbm -52.5
+# HELP wifi_radar_scans_total Scans run.
+# TYPE wifi_radar_scans_total counter
+wifi_radar_scans_total 2
+# HELP wifi_radar_scan_errors_total Scan errors by type.
+# TYPE wifi_radar_scan_errors_total counter
+wifi_radar_scan_errors_total{type="a \"quoted\"\nlabel\\"} 1
+wifi_radar_scan_errors_total{type="busy"} 2
+wifi_radar_scan_errors_total{type="permission"} 1
+# HELP wifi_radar_scan_seconds Scan duration.
+# TYPE wifi_radar_scan_seconds histogram
+wifi_radar_scan_seconds_bucket{le="0.5"} 2
+wifi_radar_scan_seconds_bucket{le="1"} 3
+wifi_radar_scan_seconds_bucket{le="2.5"} 3
+wifi_radar_scan_seconds_bucket{le="+Inf"} 5
+wifi_radar_scan_seconds_sum 14.4
+wifi_radar_scan_seconds_count 5
+# HELP wifi_radar_special Special values.
+# TYPE wifi_radar_special gauge
+wifi_radar_special{v="inf"} +Inf
+wifi_radar_special{v="-inf"} -Inf
+wifi_radar_special{v="nan"} NaN
+wifi_radar_special{v="small",unit="s"} 1e-07
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	var b strings.Builder
+	NewWriter(&b).Histogram("h", "Empty.", NewHistogram(1))
+	want := "# HELP h Empty.\n# TYPE h histogram\nh_bucket{le=\"1\"} 0\nh_bucket{le=\"+Inf\"} 0\nh_sum 0\nh_count 0\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s", b.String())
+	}
+}
+
+type failWriter struct{ n int }
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	f.n++
+	return 0, errors.New("closed")
+}
+
+func TestWriterStopsAtFirstError(t *testing.T) {
+	f := &failWriter{}
+	w := NewWriter(f)
+	w.Gauge("a", "A.", 1)
+	w.Gauge("b", "B.", 2)
+	if w.Err() == nil || f.n != 1 {
+		t.Errorf("err = %v after %d writes, want one failed write", w.Err(), f.n)
+	}
+}
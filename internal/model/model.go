@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Sample struct {
 	IfName         string  `json:"ifname"`
@@ -8,13 +11,194 @@ type Sample struct {
 	BSSID          string  `json:"bssid"`
 	FreqMHz        int     `json:"freq_mhz"`
 	SignalDBM      int     `json:"signal_dbm"`
+	Security       string  `json:"security,omitempty"`
+	Vendor         string  `json:"vendor,omitempty"`
+	Randomized     bool    `json:"randomized,omitempty"`
 	RxBitrateMbps  float64 `json:"rx_mbps"`
 	TxBitrateMbps  float64 `json:"tx_mbps"`
 	TimestampUnixM int64   `json:"ts_unix_ms"`
+	// AgeMS is how long before TimestampUnixM a scan result was last
+	// heard, from iw's "last seen".
+	AgeMS    int64     `json:"age_ms,omitempty"`
+	Lost     bool      `json:"lost,omitempty"`
+	Distance *Distance `json:"distance,omitempty"`
+	Location *Location `json:"location,omitempty"`
+}
+
+type Location struct {
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	AltM           float64 `json:"alt_m,omitempty"`
+	AccuracyM      float64 `json:"accuracy_m,omitempty"`
+	SpeedMPS       float64 `json:"speed_mps,omitempty"`
+	Fix            int     `json:"fix"`
+	TimestampUnixM int64   `json:"ts_unix_ms"`
+}
+
+type LocationEstimate struct {
+	BSSID        string  `json:"bssid"`
+	SSID         string  `json:"ssid"`
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	Observations int     `json:"observations"`
+	BestDBM      int     `json:"best_dbm"`
+	BestLat      float64 `json:"best_lat"`
+	BestLon      float64 `json:"best_lon"`
+}
+
+type Distance struct {
+	Meters      float64 `json:"meters"`
+	MinMeters   float64 `json:"min_meters"`
+	MaxMeters   float64 `json:"max_meters"`
+	RefDBM      float64 `json:"ref_dbm"`
+	PathLossExp float64 `json:"path_loss_exp"`
+	Calibrated  bool    `json:"calibrated"`
 }
 
 type Status struct {
 	Interfaces []Sample `json:"interfaces"`
+	Networks   []Sample `json:"networks,omitempty"`
+	DF         *DF      `json:"df,omitempty"`
+}
+
+// StatusDelta is a status reduced to the networks that changed since the
+// previous one a client received. Interfaces are always complete.
+type StatusDelta struct {
+	Interfaces []Sample     `json:"interfaces"`
+	Networks   []Sample     `json:"networks,omitempty"`
+	Removed    []NetworkRef `json:"removed,omitempty"`
+	DF         *DF          `json:"df,omitempty"`
+}
+
+type NetworkRef struct {
+	IfName string `json:"ifname"`
+	BSSID  string `json:"bssid"`
+}
+
+type DF struct {
+	HeadingDeg   float64 `json:"heading_deg"`
+	HeadingAgeMS int64   `json:"heading_age_ms"`
+	Bins         []DFBin `json:"bins"`
+	Coverage     float64 `json:"coverage"`
+	Fitted       bool    `json:"fitted"`
+	BearingDeg   float64 `json:"bearing_deg"`
+	Confidence   float64 `json:"confidence"`
+	TurnDeg      float64 `json:"turn_deg"`
+	Guidance     string  `json:"guidance"`
+}
+
+type DFBin struct {
+	BearingDeg float64 `json:"bearing_deg"`
+	SignalDBM  float64 `json:"signal_dbm"`
+	Count      int     `json:"count"`
+}
+
+type Hunt struct {
+	Trend          string  `json:"trend"`
+	SlopeDBPerSec  float64 `json:"slope_db_per_s"`
+	TStat          float64 `json:"t_stat"`
+	Significant    bool    `json:"significant"`
+	Samples        int     `json:"samples"`
+	WindowMS       int64   `json:"window_ms"`
+	SignalDBM      int     `json:"signal_dbm"`
+	PeakDBM        int     `json:"peak_dbm"`
+	PeakUnixMS     int64   `json:"peak_ts_unix_ms"`
+	SincePeakMS    int64   `json:"since_peak_ms"`
+	SessionStartMS int64   `json:"session_start_unix_ms"`
+}
+
+type AudioParams struct {
+	Mode      string  `json:"mode"`
+	Curve     string  `json:"curve"`
+	Muted     bool    `json:"muted"`
+	Level     float64 `json:"level"`
+	ToneHz    float64 `json:"tone_hz"`
+	ClickRate float64 `json:"click_rate"`
+	Gain      float64 `json:"gain"`
+	SignalDBM int     `json:"signal_dbm"`
+}
+
+type BSS struct {
+	BSSID        string    `json:"bssid"`
+	SSID         string    `json:"ssid"`
+	Security     string    `json:"security"`
+	FreqMHz      int       `json:"freq_mhz"`
+	Channel      int       `json:"channel"`
+	FirstSeenMS  int64     `json:"first_seen_unix_ms"`
+	LastSeenMS   int64     `json:"last_seen_unix_ms"`
+	BestDBM      int       `json:"best_dbm"`
+	Observations int       `json:"observations"`
+	Location     *Location `json:"location,omitempty"`
+}
+
+type Alert struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Rule        string `json:"rule,omitempty"`
+	State       string `json:"state"`
+	Severity    string `json:"severity"`
+	IfName      string `json:"ifname,omitempty"`
+	SSID        string `json:"ssid,omitempty"`
+	BSSID       string `json:"bssid,omitempty"`
+	Message     string `json:"message"`
+	SignalDBM   int    `json:"signal_dbm"`
+	FirstSeenMS int64  `json:"first_seen_unix_ms"`
+	LastSeenMS  int64  `json:"last_seen_unix_ms"`
+	Count       int    `json:"count"`
+}
+
+type RuleState struct {
+	Rule           string  `json:"rule"`
+	When           string  `json:"when"`
+	Severity       string  `json:"severity"`
+	HoldMS         int64   `json:"for_ms"`
+	CooldownMS     int64   `json:"cooldown_ms"`
+	Key            string  `json:"key,omitempty"`
+	State          string  `json:"state"`
+	SinceMS        int64   `json:"since_unix_ms,omitempty"`
+	Value          float64 `json:"value"`
+	LastFiredMS    int64   `json:"last_fired_unix_ms,omitempty"`
+	LastResolvedMS int64   `json:"last_resolved_unix_ms,omitempty"`
+	FireCount      int     `json:"fire_count"`
+}
+
+// Event is a named message pushed to stream subscribers alongside status
+// updates, e.g. an "alert".
+type Event struct {
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	Data any    `json:"data"`
+	// Payload is Data encoded once when the event is journaled, shared by
+	// every stream client.
+	Payload json.RawMessage `json:"-"`
+}
+
+const (
+	EventStatus    = "status"
+	EventAlert     = "alert"
+	EventTarget    = "target"
+	EventScan      = "scan"
+	EventCollector = "collector"
+)
+
+type CollectorStatus struct {
+	IfName   string `json:"ifname"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	TSUnixMS int64  `json:"ts_unix_ms"`
+}
+
+type ScanComplete struct {
+	IfName   string `json:"ifname"`
+	Networks int    `json:"networks"`
+	TSUnixMS int64  `json:"ts_unix_ms"`
+}
+
+type Target struct {
+	IfName string `json:"ifname"`
+	SSID   string `json:"ssid"`
+	BSSID  string `json:"bssid"`
 }
 
 type Best struct {
@@ -22,6 +206,23 @@ type Best struct {
 	Score  int    `json:"score"`
 }
 
+// Channel returns the IEEE channel number for a 2.4, 5 or 6 GHz frequency,
+// or 0 if it is not a Wi-Fi channel.
+func Channel(freqMHz int) int {
+	switch {
+	case freqMHz == 2484:
+		return 14
+	case freqMHz >= 2412 && freqMHz <= 2472:
+		return (freqMHz - 2407) / 5
+	case freqMHz >= 5160 && freqMHz <= 5895:
+		return (freqMHz - 5000) / 5
+	case freqMHz >= 5955 && freqMHz <= 7115:
+		return (freqMHz - 5950) / 5
+	default:
+		return 0
+	}
+}
+
 func NowUnixMS() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
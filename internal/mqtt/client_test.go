@@ -0,0 +1,497 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+// fakeBroker is a minimal MQTT broker on a local listener. It answers
+// CONNECT with connackCode and records everything clients send.
+type fakeBroker struct {
+	t           *testing.T
+	ln          net.Listener
+	version     byte
+	connackCode byte
+	// silent stops the broker answering PINGREQ.
+	silent bool
+
+	connects chan connectPacket
+	messages chan message
+	pings    chan struct{}
+	conns    chan net.Conn
+}
+
+type connectPacket struct {
+	version     byte
+	flags       byte
+	keepAlive   uint16
+	clientID    string
+	willTopic   string
+	willPayload string
+	username    string
+	password    string
+}
+
+type message struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+func newFakeBroker(t *testing.T, version byte) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{
+		t: t, ln: ln, version: version,
+		connects: make(chan connectPacket, 16),
+		messages: make(chan message, 256),
+		pings:    make(chan struct{}, 256),
+		conns:    make(chan net.Conn, 16),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go b.accept()
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *fakeBroker) accept() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.t.Cleanup(func() { conn.Close() })
+		go b.serve(conn)
+	}
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	p, err := readPacket(r)
+	if err != nil || p.kind != packetConnect {
+		conn.Close()
+		return
+	}
+	b.connects <- parseConnect(p.body)
+	connack := []byte{0, b.connackCode}
+	if b.version == V5 {
+		connack = append(connack, 0)
+	}
+	conn.Write(encodePacket(packetConnack<<4, connack))
+	if b.connackCode != 0 {
+		conn.Close()
+		return
+	}
+	b.conns <- conn
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			body := p.body
+			n := int(binary.BigEndian.Uint16(body))
+			m := message{topic: string(body[2 : 2+n]), retain: p.flags&1 != 0}
+			body = body[2+n:]
+			if b.version == V5 {
+				body = body[1:] // property length 0
+			}
+			m.payload = body
+			b.messages <- m
+		case packetPingreq:
+			b.pings <- struct{}{}
+			if !b.silent {
+				conn.Write(encodePacket(packetPingresp<<4, nil))
+			}
+		case packetDisconnect:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func parseConnect(body []byte) connectPacket {
+	str := func() string {
+		n := int(binary.BigEndian.Uint16(body))
+		s := string(body[2 : 2+n])
+		body = body[2+n:]
+		return s
+	}
+	var c connectPacket
+	if str() != "MQTT" {
+		return c
+	}
+	c.version, c.flags = body[0], body[1]
+	c.keepAlive = binary.BigEndian.Uint16(body[2:])
+	body = body[4:]
+	if c.version == V5 {
+		body = body[1:]
+	}
+	c.clientID = str()
+	if c.flags&0x04 != 0 {
+		if c.version == V5 {
+			body = body[1:]
+		}
+		c.willTopic, c.willPayload = str(), str()
+	}
+	if c.flags&0x80 != 0 {
+		c.username = str()
+	}
+	if c.flags&0x40 != 0 {
+		c.password = str()
+	}
+	return c
+}
+
+func recv[T any](t *testing.T, ch chan T, what string) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		var zero T
+		return zero
+	}
+}
+
+// collect reads messages until every topic in want has arrived and
+// returns the last message seen per topic.
+func collect(t *testing.T, b *fakeBroker, want ...string) map[string]message {
+	t.Helper()
+	got := make(map[string]message)
+	missing := func() bool {
+		for _, topic := range want {
+			if _, ok := got[topic]; !ok {
+				return true
+			}
+		}
+		return false
+	}
+	for missing() {
+		m := recv(t, b.messages, "publishes "+strings.Join(want, ", "))
+		got[m.topic] = m
+	}
+	return got
+}
+
+func runClient(t *testing.T, c *Client) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestConnect(t *testing.T) {
+	for _, version := range []byte{V311, V5} {
+		b := newFakeBroker(t, version)
+		c := &Client{
+			URL: strings.Replace(b.url(), "tcp://", "tcp://radar:hunter2@", 1), ClientID: "radar-1",
+			Version: version, KeepAlive: 20 * time.Second,
+			WillTopic: "wifi-radar/status", WillPayload: []byte("offline"),
+		}
+		runClient(t, c)
+
+		got := recv(t, b.connects, "CONNECT")
+		want := connectPacket{
+			version: version, flags: 0x02 | 0x04 | 0x20 | 0x80 | 0x40, keepAlive: 20, clientID: "radar-1",
+			willTopic: "wifi-radar/status", willPayload: "offline", username: "radar", password: "hunter2",
+		}
+		if got != want {
+			t.Errorf("v%d CONNECT = %+v\nwant %+v", version, got, want)
+		}
+		recv(t, b.conns, "connection")
+		for deadline := time.Now().Add(5 * time.Second); !c.Connected(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("v%d: not connected after CONNACK", version)
+			}
+		}
+	}
+}
+
+func TestConnackRefused(t *testing.T) {
+	for _, tt := range []struct {
+		version byte
+		code    byte
+		want    string
+	}{
+		{V311, 4, "bad user name or password"},
+		{V311, 5, "not authorized"},
+		{V5, 0x87, "reason code 0x87"},
+	} {
+		b := newFakeBroker(t, tt.version)
+		b.connackCode = tt.code
+		c := &Client{URL: b.url(), Version: tt.version}
+		_, _, err := c.dial(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("v%d code %d: err = %v, want %q", tt.version, tt.code, err, tt.want)
+		}
+	}
+}
+
+// A packet the broker sends right behind the CONNACK must not be lost
+// between dial's reader and serve's.
+func TestPacketAfterConnack(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		readPacket(bufio.NewReader(conn))
+		var out []byte
+		out = append(out, encodePacket(packetConnack<<4, []byte{0, 0})...)
+		out = append(out, encodePacket(packetPingresp<<4, nil)...)
+		conn.Write(out)
+		time.Sleep(time.Second)
+	}()
+
+	c := &Client{URL: ln.Addr().String()}
+	conn, r, err := c.dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	p, err := readPacket(r)
+	if err != nil || p.kind != packetPingresp {
+		t.Errorf("packet after CONNACK = %+v, %v; want PINGRESP", p, err)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	b := newFakeBroker(t, V311)
+	c := &Client{URL: b.url()}
+	p := NewPublisher(c, "radar/", "homeassistant")
+
+	if c.WillTopic != "radar/status" || string(c.WillPayload) != "offline" {
+		t.Errorf("will = %s %q", c.WillTopic, c.WillPayload)
+	}
+
+	// Published before the connection is up: retained state is queued.
+	sample := model.Sample{IfName: "wlan0", BSSID: "AA:BB:CC:00:11:22", SSID: "Home", SignalDBM: -55, Vendor: "Netgear"}
+	p.Observe(sample)
+	if err := c.Publish("radar/dropped", []byte("x"), false); err != ErrNotConnected {
+		t.Errorf("non-retained publish while disconnected: err = %v", err)
+	}
+
+	runClient(t, c)
+	signalConfig := "homeassistant/sensor/wifi_radar_wlan0_signal/config"
+	bssConfig := "homeassistant/sensor/wifi_radar_bss_aa_bb_cc_00_11_22_signal/config"
+	got := collect(t, b, "radar/status", "radar/wlan0/state", "radar/bss/aa_bb_cc_00_11_22/state",
+		signalConfig, bssConfig, "homeassistant/sensor/wifi_radar_wlan0_ssid/config")
+	for topic, m := range got {
+		if !m.retain {
+			t.Errorf("%s was not retained", topic)
+		}
+	}
+	if _, ok := got["radar/dropped"]; ok {
+		t.Error("non-retained message was queued")
+	}
+	if s := string(got["radar/status"].payload); s != "online" {
+		t.Errorf("status = %q", s)
+	}
+	var state model.Sample
+	if err := json.Unmarshal(got["radar/wlan0/state"].payload, &state); err != nil || state.SignalDBM != -55 {
+		t.Errorf("state = %s", got["radar/wlan0/state"].payload)
+	}
+
+	var config struct {
+		Name              string `json:"name"`
+		UniqueID          string `json:"unique_id"`
+		StateTopic        string `json:"state_topic"`
+		ValueTemplate     string `json:"value_template"`
+		AvailabilityTopic string `json:"availability_topic"`
+		Unit              string `json:"unit_of_measurement"`
+		DeviceClass       string `json:"device_class"`
+		Device            struct {
+			Identifiers  []string   `json:"identifiers"`
+			Manufacturer string     `json:"manufacturer"`
+			Connections  [][]string `json:"connections"`
+			ViaDevice    string     `json:"via_device"`
+		} `json:"device"`
+	}
+	if err := json.Unmarshal(got[signalConfig].payload, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.StateTopic != "radar/wlan0/state" || config.ValueTemplate != "{{ value_json.signal_dbm }}" ||
+		config.AvailabilityTopic != "radar/status" || config.Unit != "dBm" || config.DeviceClass != "signal_strength" ||
+		config.UniqueID != "wifi_radar_wlan0_signal" || config.Device.Identifiers[0] != "wifi_radar_wlan0" {
+		t.Errorf("interface sensor config = %s", got[signalConfig].payload)
+	}
+	config.Device.Identifiers = nil
+	if err := json.Unmarshal(got[bssConfig].payload, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.StateTopic != "radar/bss/aa_bb_cc_00_11_22/state" || config.Device.Manufacturer != "Netgear" ||
+		config.Device.ViaDevice != "wifi_radar_wlan0" || len(config.Device.Connections) != 1 || config.Device.Connections[0][1] != sample.BSSID {
+		t.Errorf("BSS sensor config = %s", got[bssConfig].payload)
+	}
+
+	// Alerts go to a retained per-alert topic and the shared stream.
+	alert := model.Alert{ID: "weak/wlan0", State: "firing"}
+	if err := p.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	got = collect(t, b, "radar/alerts/weak_wlan0", "radar/alerts")
+	if !got["radar/alerts/weak_wlan0"].retain || got["radar/alerts"].retain {
+		t.Errorf("alert retain flags: per-alert %v, stream %v", got["radar/alerts/weak_wlan0"].retain, got["radar/alerts"].retain)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	b := newFakeBroker(t, V5)
+	c := &Client{URL: b.url(), Version: V5}
+	p := NewPublisher(c, "", "")
+	runClient(t, c)
+
+	recv(t, b.connects, "CONNECT")
+	conn := recv(t, b.conns, "connection")
+	collect(t, b, "wifi-radar/status")
+
+	conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); c.Connected(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not notice the dropped connection")
+		}
+	}
+	// Retained state published while down goes out after reconnecting,
+	// behind the availability message.
+	p.Observe(model.Sample{IfName: "wlan0", SignalDBM: -60})
+	recv(t, b.connects, "CONNECT after the drop")
+	if m := recv(t, b.messages, "status"); m.topic != "wifi-radar/status" || string(m.payload) != "online" {
+		t.Errorf("first message after reconnect = %s %q", m.topic, m.payload)
+	}
+	if m := recv(t, b.messages, "queued state"); m.topic != "wifi-radar/wlan0/state" || !bytes.Contains(m.payload, []byte(`"signal_dbm":-60`)) {
+		t.Errorf("queued message = %s %s", m.topic, m.payload)
+	}
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+	b := newFakeBroker(t, V311)
+	b.silent = true
+	c := &Client{URL: b.url(), KeepAlive: time.Second}
+	runClient(t, c)
+
+	first := recv(t, b.connects, "CONNECT")
+	if first.keepAlive != 1 {
+		t.Errorf("keepalive = %d", first.keepAlive)
+	}
+	start := time.Now()
+	recv(t, b.pings, "PINGREQ")
+	// No PINGRESP ever comes: after 1.5s the client gives up on the
+	// connection and dials again after its backoff.
+	recv(t, b.connects, "CONNECT after the keepalive timeout")
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("reconnected after %v, before the read deadline", elapsed)
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	for raw, want := range map[string]broker{
+		"localhost":                 {addr: "localhost:1883", host: "localhost"},
+		"mqtt://10.0.0.2:1884":      {addr: "10.0.0.2:1884", host: "10.0.0.2"},
+		"mqtts://u:p@broker.lan":    {addr: "broker.lan:8883", host: "broker.lan", tls: true, username: "u", password: "p"},
+		"ssl://[fd00::1]:8884":      {addr: "[fd00::1]:8884", host: "fd00::1", tls: true},
+		"tcp://user@broker.lan:123": {addr: "broker.lan:123", host: "broker.lan", username: "user"},
+	} {
+		got, err := parseBroker(raw)
+		if err != nil || got != want {
+			t.Errorf("parseBroker(%q) = %+v, %v; want %+v", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"ws://broker", "tcp://:1883"} {
+		if _, err := parseBroker(raw); err == nil {
+			t.Errorf("parseBroker(%q): want an error", raw)
+		}
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 268435455} {
+		b := appendVarint(nil, n)
+		got, err := readVarint(bytes.NewReader(b))
+		if err != nil || got != n {
+			t.Errorf("varint %d: encoded % x, decoded %d, %v", n, b, got, err)
+		}
+	}
+	if _, err := readVarint(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x7f})); err == nil {
+		t.Error("five-byte length: want an error")
+	}
+}
+
+// A broker that stops reading must not block publishers: the store calls
+// Publisher.Observe synchronously on every sample.
+func TestPublishDoesNotBlock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	stalled := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		readPacket(bufio.NewReader(conn))
+		conn.Write(encodePacket(packetConnack<<4, []byte{0, 0}))
+		// Never read again.
+		stalled <- conn
+	}()
+
+	c := &Client{URL: ln.Addr().String()}
+	runClient(t, c)
+	conn := recv(t, stalled, "connection")
+	defer conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); !c.Connected(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("not connected")
+		}
+	}
+
+	// Far more than the socket buffers hold, so the writer gets stuck.
+	big := bytes.Repeat([]byte("x"), 8<<20)
+	if err := c.Publish("radar/big", big, true); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var full error
+	for i := 0; i < queueSize+10 && full == nil; i++ {
+		full = c.Publish("radar/wlan0/state", []byte("{}"), true)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("publishing took %v while the broker was stalled", elapsed)
+	}
+	if full != ErrQueueFull {
+		t.Errorf("after %d messages: err = %v, want ErrQueueFull", queueSize+10, full)
+	}
+}
@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// Protocol levels sent in CONNECT.
+const (
+	V311 = 4
+	V5   = 5
+)
+
+type connectOptions struct {
+	version     byte
+	clientID    string
+	username    string
+	password    string
+	keepAlive   uint16
+	willTopic   string
+	willPayload []byte
+	willRetain  bool
+}
+
+func encodeConnect(o connectOptions) []byte {
+	var vh []byte
+	vh = appendString(vh, "MQTT")
+	vh = append(vh, o.version)
+
+	flags := byte(0x02) // clean session / clean start
+	if o.willTopic != "" {
+		flags |= 0x04
+		if o.willRetain {
+			flags |= 0x20
+		}
+	}
+	if o.username != "" {
+		flags |= 0x80
+	}
+	if o.password != "" {
+		flags |= 0x40
+	}
+	vh = append(vh, flags)
+	vh = binary.BigEndian.AppendUint16(vh, o.keepAlive)
+	if o.version == V5 {
+		vh = append(vh, 0) // no properties
+	}
+
+	payload := appendString(nil, o.clientID)
+	if o.willTopic != "" {
+		if o.version == V5 {
+			payload = append(payload, 0) // no will properties
+		}
+		payload = appendString(payload, o.willTopic)
+		payload = appendBytes(payload, o.willPayload)
+	}
+	if o.username != "" {
+		payload = appendString(payload, o.username)
+	}
+	if o.password != "" {
+		payload = appendString(payload, o.password)
+	}
+	return encodePacket(packetConnect<<4, append(vh, payload...))
+}
+
+func encodePublish(version byte, topic string, payload []byte, retain bool) []byte {
+	header := byte(packetPublish << 4)
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	if version == V5 {
+		body = append(body, 0)
+	}
+	return encodePacket(header, append(body, payload...))
+}
+
+func encodePacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	out = appendVarint(out, len(body))
+	return append(out, body...)
+}
+
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	n, err := readVarint(r)
+	if err != nil {
+		return packet{}, err
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// connackError interprets a CONNACK body. Both 3.1.1 return codes and
+// MQTT 5 reason codes use 0 for success.
+func connackError(version byte, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("short CONNACK")
+	}
+	code := body[1]
+	if code == 0 {
+		return nil
+	}
+	if version == V5 {
+		return fmt.Errorf("connection refused: reason code 0x%02x", code)
+	}
+	switch code {
+	case 1:
+		return errors.New("connection refused: unacceptable protocol version")
+	case 2:
+		return errors.New("connection refused: client identifier rejected")
+	case 3:
+		return errors.New("connection refused: server unavailable")
+	case 4:
+		return errors.New("connection refused: bad user name or password")
+	case 5:
+		return errors.New("connection refused: not authorized")
+	default:
+		return fmt.Errorf("connection refused: code %d", code)
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func appendVarint(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func readVarint(r io.ByteReader) (int, error) {
+	n, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(digit&0x7f) * mult
+		if digit&0x80 == 0 {
+			return n, nil
+		}
+		mult *= 128
+	}
+	return 0, errors.New("malformed remaining length")
+}
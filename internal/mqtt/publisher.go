@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	DefaultPrefix          = "wifi-radar"
+	DefaultDiscoveryPrefix = "homeassistant"
+
+	online  = "online"
+	offline = "offline"
+)
+
+// Publisher sends samples and alerts over a Client:
+//
+//	<prefix>/status                   online/offline (retained, also the will)
+//	<prefix>/<ifname>/state           latest sample per interface (retained)
+//	<prefix>/bss/<bssid>/state        latest sample per tracked BSS (retained)
+//	<prefix>/alerts                   every alert
+//	<prefix>/alerts/<id>              latest state per alert (retained)
+//
+// With a discovery prefix it also announces Home Assistant sensors for
+// each interface and tracked BSS.
+type Publisher struct {
+	Client          *Client
+	Prefix          string
+	DiscoveryPrefix string
+
+	mu        sync.Mutex
+	announced map[string]model.Sample
+}
+
+// NewPublisher wires the client's will and reconnect hook to p.
+func NewPublisher(client *Client, prefix, discoveryPrefix string) *Publisher {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	p := &Publisher{
+		Client:          client,
+		Prefix:          strings.TrimRight(prefix, "/"),
+		DiscoveryPrefix: strings.TrimRight(discoveryPrefix, "/"),
+		announced:       make(map[string]model.Sample),
+	}
+	client.WillTopic = p.availabilityTopic()
+	client.WillPayload = []byte(offline)
+	client.OnConnect = p.onConnect
+	return p
+}
+
+// Observe publishes a sample; it is meant to run on every store update.
+func (p *Publisher) Observe(sample model.Sample) {
+	payload, err := json.Marshal(sample)
+	if err != nil {
+		return
+	}
+	p.announce(sample)
+	_ = p.Client.Publish(p.interfaceTopic(sample.IfName), payload, true)
+	if sample.BSSID != "" {
+		_ = p.Client.Publish(p.bssTopic(sample.BSSID), payload, true)
+	}
+}
+
+func (p *Publisher) Name() string {
+	return "mqtt " + p.Client.URL
+}
+
+// Send publishes an alert, so the publisher can be used as a notify sink.
+func (p *Publisher) Send(ctx context.Context, alert model.Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	if err := p.Client.Publish(p.Prefix+"/alerts/"+topicSafe(alert.ID), payload, true); err != nil {
+		return err
+	}
+	return p.Client.Publish(p.Prefix+"/alerts", payload, false)
+}
+
+func (p *Publisher) onConnect() {
+	_ = p.Client.Publish(p.availabilityTopic(), []byte(online), true)
+
+	// Discovery configs are retained, but re-announce in case the broker
+	// lost them.
+	p.mu.Lock()
+	samples := make([]model.Sample, 0, len(p.announced))
+	for _, s := range p.announced {
+		samples = append(samples, s)
+	}
+	p.mu.Unlock()
+	for _, s := range samples {
+		p.publishDiscovery(s)
+	}
+}
+
+func (p *Publisher) announce(sample model.Sample) {
+	if p.DiscoveryPrefix == "" {
+		return
+	}
+	key := sample.IfName + "|" + sample.BSSID
+	p.mu.Lock()
+	_, seen := p.announced[key]
+	p.announced[key] = sample
+	p.mu.Unlock()
+	if !seen {
+		p.publishDiscovery(sample)
+	}
+}
+
+type sensor struct {
+	key    string
+	name   string
+	field  string
+	unit   string
+	class  string
+	perBSS bool
+}
+
+var sensors = []sensor{
+	{key: "signal", name: "Signal", field: "signal_dbm", unit: "dBm", class: "signal_strength"},
+	{key: "rx", name: "RX bitrate", field: "rx_mbps", unit: "Mbit/s", class: "data_rate"},
+	{key: "tx", name: "TX bitrate", field: "tx_mbps", unit: "Mbit/s", class: "data_rate"},
+	{key: "freq", name: "Frequency", field: "freq_mhz", unit: "MHz", class: "frequency"},
+	{key: "ssid", name: "SSID", field: "ssid"},
+	{key: "bssid", name: "BSSID", field: "bssid"},
+	{key: "bss_signal", name: "Signal", field: "signal_dbm", unit: "dBm", class: "signal_strength", perBSS: true},
+}
+
+func (p *Publisher) publishDiscovery(sample model.Sample) {
+	ifDevice := map[string]any{
+		"identifiers":  []string{"wifi_radar_" + topicSafe(sample.IfName)},
+		"name":         "WiFi Radar " + sample.IfName,
+		"manufacturer": "wifi-radar",
+	}
+	bssName := sample.SSID
+	if bssName == "" {
+		bssName = sample.BSSID
+	}
+	bssDevice := map[string]any{
+		"identifiers": []string{"wifi_radar_bss_" + topicSafe(sample.BSSID)},
+		"name":        "Wi-Fi " + bssName,
+		"connections": [][]string{{"mac", sample.BSSID}},
+		"via_device":  "wifi_radar_" + topicSafe(sample.IfName),
+	}
+	if sample.Vendor != "" {
+		bssDevice["manufacturer"] = sample.Vendor
+	}
+
+	for _, s := range sensors {
+		if s.perBSS && sample.BSSID == "" {
+			continue
+		}
+		objectID := "wifi_radar_" + topicSafe(sample.IfName) + "_" + s.key
+		stateTopic := p.interfaceTopic(sample.IfName)
+		device := ifDevice
+		if s.perBSS {
+			objectID = "wifi_radar_bss_" + topicSafe(sample.BSSID) + "_signal"
+			stateTopic = p.bssTopic(sample.BSSID)
+			device = bssDevice
+		}
+		config := map[string]any{
+			"name":                  s.name,
+			"unique_id":             objectID,
+			"object_id":             objectID,
+			"state_topic":           stateTopic,
+			"value_template":        "{{ value_json." + s.field + " }}",
+			"availability_topic":    p.availabilityTopic(),
+			"json_attributes_topic": stateTopic,
+			"device":                device,
+		}
+		if s.unit != "" {
+			config["unit_of_measurement"] = s.unit
+			config["state_class"] = "measurement"
+		}
+		if s.class != "" {
+			config["device_class"] = s.class
+		}
+		payload, err := json.Marshal(config)
+		if err != nil {
+			continue
+		}
+		_ = p.Client.Publish(p.DiscoveryPrefix+"/sensor/"+objectID+"/config", payload, true)
+	}
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.Prefix + "/status"
+}
+
+func (p *Publisher) interfaceTopic(ifname string) string {
+	return p.Prefix + "/" + topicSafe(ifname) + "/state"
+}
+
+func (p *Publisher) bssTopic(bssid string) string {
+	return p.Prefix + "/bss/" + topicSafe(bssid) + "/state"
+}
+
+// topicSafe strips characters that are special in MQTT topics or Home
+// Assistant object IDs.
+func topicSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"wifi-radar/internal/model"
+)
+
+// Exec runs a command for each alert with the alert JSON on stdin and its
+// main fields in WIFI_RADAR_ALERT_* environment variables. The command is
+// run directly, not through a shell.
+type Exec struct {
+	Command []string
+}
+
+func (e *Exec) Name() string {
+	return "exec " + strings.Join(e.Command, " ")
+}
+
+func (e *Exec) Send(ctx context.Context, alert model.Alert) error {
+	if len(e.Command) == 0 {
+		return fmt.Errorf("no command")
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"WIFI_RADAR_ALERT_ID="+alert.ID,
+		"WIFI_RADAR_ALERT_KIND="+alert.Kind,
+		"WIFI_RADAR_ALERT_STATE="+alert.State,
+		"WIFI_RADAR_ALERT_SEVERITY="+alert.Severity,
+		"WIFI_RADAR_ALERT_MESSAGE="+alert.Message,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
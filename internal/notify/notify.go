@@ -0,0 +1,59 @@
+// Package notify delivers alerts to outside sinks: HTTP webhooks and local
+// commands.
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, alert model.Alert) error
+}
+
+const (
+	queueSize   = 64
+	sendTimeout = 2 * time.Minute
+)
+
+// Dispatcher fans alerts out to its sinks. Each sink has its own queue, so
+// a webhook stuck in retries does not hold up the others; when a queue is
+// full, new alerts for that sink are dropped.
+type Dispatcher struct {
+	queues []chan model.Alert
+	sinks  []Sink
+}
+
+func New(sinks ...Sink) *Dispatcher {
+	d := &Dispatcher{sinks: sinks}
+	for _, sink := range sinks {
+		q := make(chan model.Alert, queueSize)
+		d.queues = append(d.queues, q)
+		go run(sink, q)
+	}
+	return d
+}
+
+func (d *Dispatcher) Notify(alert model.Alert) {
+	for i, q := range d.queues {
+		select {
+		case q <- alert:
+		default:
+			log.Printf("notify %s: queue full, dropping alert %s", d.sinks[i].Name(), alert.ID)
+		}
+	}
+}
+
+func run(sink Sink, q chan model.Alert) {
+	for alert := range q {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		if err := sink.Send(ctx, alert); err != nil {
+			log.Printf("notify %s: %v", sink.Name(), err)
+		}
+		cancel()
+	}
+}
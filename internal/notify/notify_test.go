@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+var testAlert = model.Alert{ID: "weak/wlan0", Kind: "rule", State: "firing", Severity: "warning", Message: "weak firing"}
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	const want = "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", "1700000000", []byte("{}")); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	wh := &Webhook{URL: srv.URL, Secret: "s3cret"}
+	if err := wh.Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := header.Get(TimestampHeader)
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)).Abs() > time.Minute {
+		t.Errorf("%s = %q, want the current Unix time in seconds", TimestampHeader, ts)
+	}
+	if got, want := header.Get(SignatureHeader), "sha256="+Sign("s3cret", ts, body); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q", header.Get("Content-Type"))
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Alert != testAlert || payload.SentMS/1000 != mustAtoi(t, ts) {
+		t.Errorf("payload = %s (%v)", body, err)
+	}
+
+	// Without a secret there is nothing to sign.
+	if err := (&Webhook{URL: srv.URL}).Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get(SignatureHeader) != "" || header.Get(TimestampHeader) != "" {
+		t.Errorf("unsigned webhook sent %v", header)
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int64 {
+	t.Helper()
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestWebhookRetries(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		statuses []int // per attempt; 0 drops the connection
+		retries  int
+		attempts int
+		wantErr  string
+	}{
+		{"ok", []int{200}, 3, 1, ""},
+		{"5xx then ok", []int{503, 500, 204}, 3, 3, ""},
+		{"429 then ok", []int{429, 200}, 3, 2, ""},
+		{"connection error then ok", []int{0, 0, 200}, 3, 3, ""},
+		{"permanent 4xx", []int{400, 200}, 3, 1, "400 Bad Request"},
+		{"not found", []int{404}, 3, 1, "404 Not Found"},
+		{"gives up", []int{502, 502, 502, 502}, 2, 3, "502 Bad Gateway"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				times []time.Time
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				n := len(times)
+				times = append(times, time.Now())
+				mu.Unlock()
+				status := 200
+				if n < len(tt.statuses) {
+					status = tt.statuses[n]
+				}
+				if status == 0 {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			wh := &Webhook{URL: srv.URL, Retries: tt.retries, Backoff: 20 * time.Millisecond}
+			err := wh.Send(context.Background(), testAlert)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if len(times) != tt.attempts {
+				t.Fatalf("%d attempts, want %d", len(times), tt.attempts)
+			}
+			// The backoff doubles: 20ms, 40ms, ...
+			for i := 1; i < len(times); i++ {
+				want := 20 * time.Millisecond << (i - 1)
+				if gap := times[i].Sub(times[i-1]); gap < want {
+					t.Errorf("attempt %d came %v after the previous one, want at least %v", i+1, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := (&Webhook{URL: srv.URL, Retries: 10, Backoff: time.Second}).Send(ctx, testAlert)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v, want the deadline and the last error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Send took %v after the context expired", elapsed)
+	}
+}
+
+func requireSh(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+}
+
+func TestExec(t *testing.T) {
+	requireSh(t)
+	dir := t.TempDir()
+	stdin, env := filepath.Join(dir, "stdin"), filepath.Join(dir, "env")
+	e := &Exec{Command: []string{"sh", "-c", `cat > "$1"; env | grep ^WIFI_RADAR_ALERT_ | sort > "$2"`, "hook", stdin, env}}
+	if err := e.Send(context.Background(), testAlert); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got model.Alert
+	if err := json.Unmarshal(data, &got); err != nil || got != testAlert {
+		t.Errorf("stdin = %s (%v)", data, err)
+	}
+	data, _ = os.ReadFile(env)
+	want := "WIFI_RADAR_ALERT_ID=weak/wlan0\n" +
+		"WIFI_RADAR_ALERT_KIND=rule\n" +
+		"WIFI_RADAR_ALERT_MESSAGE=weak firing\n" +
+		"WIFI_RADAR_ALERT_SEVERITY=warning\n" +
+		"WIFI_RADAR_ALERT_STATE=firing\n"
+	if string(data) != want {
+		t.Errorf("env =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	requireSh(t)
+	if err := (&Exec{}).Send(context.Background(), testAlert); err == nil {
+		t.Error("empty command: want an error")
+	}
+
+	err := (&Exec{Command: []string{"sh", "-c", "echo 'hook failed' >&2; exit 3"}}).Send(context.Background(), testAlert)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: hook failed") {
+		t.Errorf("failing hook: err = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = (&Exec{Command: []string{"sleep", "10"}}).Send(ctx, testAlert)
+	if err == nil {
+		t.Error("hook outlived its timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hook was not killed at the timeout: ran %v", elapsed)
+	}
+}
+
+// countSink counts deliveries and blocks until released.
+type countSink struct {
+	release chan struct{}
+	sent    atomic.Int32
+}
+
+func (s *countSink) Name() string { return "count" }
+
+func (s *countSink) Send(ctx context.Context, alert model.Alert) error {
+	<-s.release
+	s.sent.Add(1)
+	return nil
+}
+
+func TestDispatcherQueue(t *testing.T) {
+	stuck := &countSink{release: make(chan struct{})}
+	free := &countSink{release: make(chan struct{})}
+	close(free.release)
+	d := New(stuck, free)
+
+	// The stuck sink holds one alert in Send and queues queueSize more;
+	// the rest are dropped without holding up the other sink.
+	total := queueSize + 10
+	for i := 0; i < total; i++ {
+		d.Notify(testAlert)
+		time.Sleep(time.Millisecond)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for free.sent.Load() < int32(total) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := free.sent.Load(); n != int32(total) {
+		t.Errorf("free sink got %d alerts, want %d", n, total)
+	}
+
+	close(stuck.release)
+	deadline = time.Now().Add(5 * time.Second)
+	for stuck.sent.Load() < queueSize+1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := stuck.sent.Load(); n != queueSize+1 {
+		t.Errorf("stuck sink got %d alerts, want %d", n, queueSize+1)
+	}
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	SignatureHeader = "X-Wifi-Radar-Signature"
+	TimestampHeader = "X-Wifi-Radar-Timestamp"
+
+	defaultRetries    = 5
+	defaultBackoff    = time.Second
+	maxBackoff        = time.Minute
+	defaultReqTimeout = 10 * time.Second
+)
+
+// Webhook POSTs each alert as JSON. With a Secret, requests carry
+// "sha256=<hex>" in SignatureHeader: the HMAC-SHA256 of the timestamp
+// header, a ".", and the body.
+type Webhook struct {
+	URL    string
+	Secret string
+	// Retries is how many times a failed delivery is retried; network
+	// errors, 429 and 5xx responses are retried, other statuses are not.
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+}
+
+type webhookPayload struct {
+	Alert  model.Alert `json:"alert"`
+	SentMS int64       `json:"sent_unix_ms"`
+}
+
+func (w *Webhook) Name() string {
+	return "webhook " + w.URL
+}
+
+func (w *Webhook) Send(ctx context.Context, alert model.Alert) error {
+	retries := w.Retries
+	if retries <= 0 {
+		retries = defaultRetries
+	}
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = w.post(ctx, alert)
+		if err == nil || !retry || attempt >= retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	return err
+}
+
+func (w *Webhook) post(ctx context.Context, alert model.Alert) (bool, error) {
+	sent := model.NowUnixMS()
+	body, err := json.Marshal(webhookPayload{Alert: alert, SentMS: sent})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wifi-radar")
+	if w.Secret != "" {
+		ts := strconv.FormatInt(sent/1000, 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.Secret, ts, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultReqTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("%s", resp.Status)
+	default:
+		return false, fmt.Errorf("%s", resp.Status)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 a receiver should compare against the
+// signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
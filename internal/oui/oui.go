@@ -0,0 +1,117 @@
+// Package oui maps MAC addresses to the organization the IEEE assigned
+// their prefix to. The table is embedded; regenerate it from the IEEE
+// registry CSVs with cmd/ouigen.
+package oui
+
+//go:generate go run ../../cmd/ouigen -out oui.txt oui.csv mam.csv oui36.csv
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// oui.txt holds one assignment per line: the prefix in hex (6 digits for
+// MA-L, 7 for MA-M, 9 for MA-S), a tab and the organization name.
+//
+//go:embed oui.txt
+var table string
+
+var (
+	loadOnce sync.Once
+	// prefixes maps prefix length in bits to prefix value to vendor.
+	prefixes map[int]map[uint64]string
+)
+
+// Prefix lengths in bits, longest first so more specific blocks win.
+var prefixBits = []int{36, 28, 24}
+
+// Lookup returns the organization registered for mac's prefix.
+func Lookup(mac string) (string, bool) {
+	addr, ok := parseMAC(mac)
+	if !ok || IsLocallyAdministered(mac) {
+		return "", false
+	}
+	loadOnce.Do(load)
+	for _, bits := range prefixBits {
+		if vendor, ok := prefixes[bits][addr>>(48-bits)]; ok {
+			return vendor, true
+		}
+	}
+	return "", false
+}
+
+// IsLocallyAdministered reports whether mac has the U/L bit set, as
+// randomized client and virtual AP addresses do. Such addresses carry no
+// vendor information.
+func IsLocallyAdministered(mac string) bool {
+	addr, ok := parseMAC(mac)
+	return ok && addr&(0x02<<40) != 0
+}
+
+// Len returns the number of assignments in the embedded table.
+func Len() int {
+	loadOnce.Do(load)
+	n := 0
+	for _, m := range prefixes {
+		n += len(m)
+	}
+	return n
+}
+
+func load() {
+	prefixes = parseTable(table)
+}
+
+func parseTable(table string) map[int]map[uint64]string {
+	prefixes := make(map[int]map[uint64]string, len(prefixBits))
+	for _, bits := range prefixBits {
+		prefixes[bits] = make(map[uint64]string)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		hex, vendor, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || vendor == "" {
+			continue
+		}
+		bits := len(hex) * 4
+		m, ok := prefixes[bits]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			continue
+		}
+		m[v] = vendor
+	}
+	return prefixes
+}
+
+// parseMAC accepts aa:bb:cc:dd:ee:ff, aa-bb-cc-dd-ee-ff and aabb.ccdd.eeff
+// forms, case-insensitively.
+func parseMAC(mac string) (uint64, bool) {
+	var v uint64
+	n := 0
+	for i := 0; i < len(mac); i++ {
+		c := mac[i]
+		var d byte
+		switch {
+		case c >= '0' && c <= '9':
+			d = c - '0'
+		case c >= 'a' && c <= 'f':
+			d = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			d = c - 'A' + 10
+		case c == ':' || c == '-' || c == '.':
+			continue
+		default:
+			return 0, false
+		}
+		v = v<<4 | uint64(d)
+		n++
+	}
+	return v, n == 12
+}
@@ -0,0 +1,121 @@
+package oui
+
+import (
+	"strings"
+	"testing"
+)
+
+// fixture nests an MA-S block inside an MA-M block inside an MA-L block.
+const fixture = "001122\tLarge\n" +
+	"0011223\tMedium\n" +
+	"001122334\tSmall\n" +
+	"0011\tTooShort\n" +
+	"ZZZZZZ\tNotHex\n" +
+	"AABBCC\t\n" +
+	"no tab\n"
+
+// useFixture swaps the embedded table for fixture for one test.
+func useFixture(t *testing.T) {
+	t.Helper()
+	loadOnce.Do(load)
+	saved := prefixes
+	prefixes = parseTable(fixture)
+	t.Cleanup(func() { prefixes = saved })
+}
+
+func TestLookupLongestPrefix(t *testing.T) {
+	useFixture(t)
+	for _, tt := range []struct {
+		mac, want string
+	}{
+		{"00:11:22:33:44:55", "Small"},  // MA-S 00:11:22:33:4
+		{"00:11:22:33:54:55", "Medium"}, // MA-M 00:11:22:3
+		{"00:11:22:43:44:55", "Large"},  // MA-L 00:11:22
+		{"00-11-22-33-4F-FF", "Small"},
+		{"0011.2233.4400", "Small"},
+		{"00:11:23:00:00:00", ""},
+		{"AA:BB:CC:00:00:00", ""}, // local bit, and an empty name
+	} {
+		got, ok := Lookup(tt.mac)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", tt.mac, got, ok, tt.want)
+		}
+	}
+	if n := Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3 valid fixture rows", n)
+	}
+}
+
+func TestLocallyAdministered(t *testing.T) {
+	for _, tt := range []struct {
+		mac  string
+		want bool
+	}{
+		{"00:0c:29:01:02:03", false},
+		{"02:0c:29:01:02:03", true},
+		{"da:a1:19:00:00:01", true}, // randomized client address
+		{"f8:1a:67:00:00:01", false},
+		{"not a mac", false},
+		{"02:00:00:00:00", false}, // too short
+	} {
+		if got := IsLocallyAdministered(tt.mac); got != tt.want {
+			t.Errorf("IsLocallyAdministered(%q) = %v, want %v", tt.mac, got, tt.want)
+		}
+	}
+
+	// A local address never resolves, even when its prefix is registered.
+	if vendor, ok := Lookup("00:00:0c:12:34:56"); !ok {
+		t.Fatal("00:00:0c is not in the embedded table")
+	} else if _, ok := Lookup("02:00:0c:12:34:56"); ok {
+		t.Errorf("Lookup with the local bit set matched %q's block", vendor)
+	}
+}
+
+func TestEmbeddedTable(t *testing.T) {
+	if Len() == 0 {
+		t.Fatal("embedded table is empty")
+	}
+	if vendor, ok := Lookup("00:0C:29:AB:CD:EF"); !ok || vendor != "VMware" {
+		t.Errorf("Lookup(VMware MAC) = %q, %v", vendor, ok)
+	}
+}
+
+func TestEmbeddedTableWellFormed(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(table, "\n"), "\n")
+	for i, line := range lines {
+		hex, vendor, ok := strings.Cut(line, "\t")
+		if !ok || vendor == "" || strings.TrimSpace(vendor) != vendor {
+			t.Errorf("line %d: %q is not prefix<TAB>name", i+1, line)
+			continue
+		}
+		if n := len(hex); n != 6 && n != 7 && n != 9 || strings.ToUpper(hex) != hex {
+			t.Errorf("line %d: bad prefix %q", i+1, hex)
+		}
+		if i > 0 && lines[i-1] >= line {
+			t.Errorf("line %d: %q is not sorted after %q", i+1, line, lines[i-1])
+		}
+	}
+	if Len() != len(lines) {
+		t.Errorf("Len() = %d, but the table has %d lines", Len(), len(lines))
+	}
+}
+
+// The full registry has over 35000 MA-L, 5000 MA-M and 6000 MA-S
+// assignments; the seed table in the repository has a few dozen MA-L ones.
+func TestEmbeddedTableComplete(t *testing.T) {
+	loadOnce.Do(load)
+	if len(prefixes[24]) < 30000 {
+		t.Skipf("seed table with %d MA-L entries; run go generate ./internal/oui with the IEEE CSVs", len(prefixes[24]))
+	}
+	if len(prefixes[28]) < 4000 || len(prefixes[36]) < 4000 {
+		t.Errorf("%d MA-M and %d MA-S entries, want the full registry", len(prefixes[28]), len(prefixes[36]))
+	}
+	// 70:B3:D5 is the IEEE block carved up into MA-S assignments.
+	var mas bool
+	for v := range prefixes[36] {
+		mas = mas || v>>12 == 0x70B3D5
+	}
+	if !mas {
+		t.Error("no MA-S assignment under 70:B3:D5")
+	}
+}
@@ -0,0 +1,150 @@
+package record
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+const (
+	KindSample = "sample"
+	KindScan   = "scan"
+)
+
+// Entry is one line of a recording: a collected sample or the networks
+// from one scan.
+type Entry struct {
+	TimeMS   int64          `json:"t"`
+	Kind     string         `json:"kind"`
+	IfName   string         `json:"ifname,omitempty"`
+	Sample   *model.Sample  `json:"sample,omitempty"`
+	Networks []model.Sample `json:"networks,omitempty"`
+}
+
+// Writer writes a recording as JSON lines. Its methods fit
+// store.Observe and store.ObserveNetworks.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	n   int
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+func (w *Writer) Sample(sample model.Sample) {
+	w.write(Entry{Kind: KindSample, IfName: sample.IfName, Sample: &sample})
+}
+
+// Networks records a scan. Empty scans are skipped since the interface
+// name comes from the results.
+func (w *Writer) Networks(networks []model.Sample) {
+	if len(networks) == 0 {
+		return
+	}
+	w.write(Entry{Kind: KindScan, IfName: networks[0].IfName, Networks: networks})
+}
+
+func (w *Writer) write(e Entry) {
+	e.TimeMS = model.NowUnixMS()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.Encode(e)
+	w.n++
+}
+
+// Count returns the number of entries written.
+func (w *Writer) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+// Err returns the first write error; later entries are dropped.
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+type Reader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func NewReader(r io.Reader) *Reader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	return &Reader{sc: sc}
+}
+
+// Next returns the next entry, or io.EOF at the end of the recording.
+func (r *Reader) Next() (Entry, error) {
+	for r.sc.Scan() {
+		r.line++
+		if len(r.sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(r.sc.Bytes(), &e); err != nil {
+			return Entry{}, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return e, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Entry{}, err
+	}
+	return Entry{}, io.EOF
+}
+
+// Play feeds a recording into st, keeping the recorded spacing divided by
+// speed; speed <= 0 plays as fast as possible. Timestamps are moved to
+// the playback time so time-windowed consumers behave as they do live.
+func Play(ctx context.Context, r *Reader, st *store.Store, speed float64) error {
+	var last int64
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if last != 0 && speed > 0 && e.TimeMS > last {
+			wait := time.Duration(float64(time.Duration(e.TimeMS-last)*time.Millisecond) / speed)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		last = e.TimeMS
+
+		now := model.NowUnixMS()
+		switch e.Kind {
+		case KindSample:
+			if e.Sample != nil {
+				sample := *e.Sample
+				sample.TimestampUnixM = now
+				st.Update(sample)
+			}
+		case KindScan:
+			for i := range e.Networks {
+				e.Networks[i].TimestampUnixM = now
+			}
+			st.UpdateNetworks(e.IfName, e.Networks)
+		}
+	}
+}
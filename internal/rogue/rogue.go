@@ -0,0 +1,342 @@
+// Package rogue flags access points that impersonate the networks in an
+// allowlist: unknown BSSIDs advertising a protected SSID, and BSSes whose
+// security, vendor, channel or signal do not fit the legitimate ones.
+package rogue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	KindUnauthorized     = "unauthorized_bssid"
+	KindSecurityMismatch = "security_mismatch"
+	KindVendorMismatch   = "vendor_mismatch"
+	KindChannelMismatch  = "channel_mismatch"
+	KindSignalJump       = "signal_jump"
+
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+
+	defaultJumpDB     = 25
+	defaultJumpWindow = 10 * time.Second
+	defaultExpire     = 5 * time.Minute
+)
+
+// Allowlist describes the networks to protect. Empty fields are learned
+// from the listed BSSIDs, or from the first scan the SSID shows up in when
+// no BSSIDs are listed.
+type Allowlist struct {
+	Networks []Network `json:"networks"`
+}
+
+type Network struct {
+	SSID     string   `json:"ssid"`
+	BSSIDs   []string `json:"bssids,omitempty"`
+	Security []string `json:"security,omitempty"`
+	Vendors  []string `json:"vendors,omitempty"`
+	Channels []int    `json:"channels,omitempty"`
+}
+
+func LoadAllowlist(path string) (Allowlist, error) {
+	var list Allowlist
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return list, err
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return list, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, n := range list.Networks {
+		if n.SSID == "" {
+			return list, fmt.Errorf("%s: network %d has no ssid", path, i+1)
+		}
+		for j, b := range n.BSSIDs {
+			list.Networks[i].BSSIDs[j] = strings.ToLower(strings.TrimSpace(b))
+		}
+	}
+	return list, nil
+}
+
+type Detector struct {
+	Allowlist Allowlist
+	// JumpDB is the signal change between scans within JumpWindow that
+	// counts as implausible for a fixed AP.
+	JumpDB     int
+	JumpWindow time.Duration
+	// Expire drops alerts whose BSS has not been seen for this long.
+	Expire time.Duration
+	// OnAlert is called for each new alert, outside the detector's lock.
+	OnAlert func(model.Alert)
+
+	mu        sync.Mutex
+	baselines map[string]*baseline
+	last      map[string]reading
+	alerts    map[string]*model.Alert
+}
+
+type baseline struct {
+	frozen   bool
+	security []string
+	vendors  []string
+	channels []int
+}
+
+type reading struct {
+	signal int
+	ts     int64
+}
+
+func (d *Detector) Observe(networks []model.Sample) {
+	var raised []model.Alert
+
+	d.mu.Lock()
+	if d.baselines == nil {
+		d.baselines = make(map[string]*baseline)
+		d.last = make(map[string]reading)
+		d.alerts = make(map[string]*model.Alert)
+	}
+	now := model.NowUnixMS()
+	d.expireLocked(now)
+
+	for _, n := range d.monitored(networks) {
+		d.learnLocked(n.network, n.samples)
+	}
+	for _, n := range d.monitored(networks) {
+		for _, s := range n.samples {
+			raised = append(raised, d.checkLocked(n.network, s)...)
+		}
+	}
+	d.mu.Unlock()
+
+	if d.OnAlert != nil {
+		for _, a := range raised {
+			d.OnAlert(a)
+		}
+	}
+}
+
+func (d *Detector) Alerts() []model.Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expireLocked(model.NowUnixMS())
+	out := make([]model.Alert, 0, len(d.alerts))
+	for _, a := range d.alerts {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].LastSeenMS == out[j].LastSeenMS {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].LastSeenMS > out[j].LastSeenMS
+	})
+	return out
+}
+
+// Reset clears active alerts and everything learned so far.
+func (d *Detector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.baselines = nil
+	d.last = nil
+	d.alerts = nil
+}
+
+type monitoredNetwork struct {
+	network *Network
+	samples []model.Sample
+}
+
+func (d *Detector) monitored(networks []model.Sample) []monitoredNetwork {
+	var out []monitoredNetwork
+	for i := range d.Allowlist.Networks {
+		network := &d.Allowlist.Networks[i]
+		var samples []model.Sample
+		for _, s := range networks {
+			if !s.Lost && s.SSID == network.SSID && s.BSSID != "" {
+				samples = append(samples, s)
+			}
+		}
+		if len(samples) > 0 {
+			out = append(out, monitoredNetwork{network: network, samples: samples})
+		}
+	}
+	return out
+}
+
+func (d *Detector) learnLocked(network *Network, samples []model.Sample) {
+	b := d.baselines[network.SSID]
+	if b == nil {
+		b = &baseline{}
+		d.baselines[network.SSID] = b
+	}
+	if b.frozen {
+		return
+	}
+	for _, s := range samples {
+		if len(network.BSSIDs) > 0 && !slices.Contains(network.BSSIDs, strings.ToLower(s.BSSID)) {
+			continue
+		}
+		b.security = appendUnique(b.security, s.Security)
+		b.vendors = appendUnique(b.vendors, s.Vendor)
+		if ch := model.Channel(s.FreqMHz); ch != 0 && !slices.Contains(b.channels, ch) {
+			b.channels = append(b.channels, ch)
+		}
+	}
+	// Without a BSSID list there is nothing to tell the real AP from a
+	// twin later on, so trust the first sighting only.
+	if len(network.BSSIDs) == 0 {
+		b.frozen = true
+	}
+}
+
+func (d *Detector) checkLocked(network *Network, s model.Sample) []model.Alert {
+	var raised []model.Alert
+	raise := func(kind, severity, message string) {
+		if a, ok := d.raiseLocked(kind, severity, message, s); ok {
+			raised = append(raised, a)
+		}
+	}
+
+	bssid := strings.ToLower(s.BSSID)
+	listed := slices.Contains(network.BSSIDs, bssid)
+	b := d.baselines[network.SSID]
+
+	if len(network.BSSIDs) > 0 && !listed {
+		raise(KindUnauthorized, SeverityHigh, fmt.Sprintf("%s advertises %q but is not on the allowlist", s.BSSID, s.SSID))
+	}
+
+	security := network.Security
+	if len(security) == 0 {
+		security = b.security
+	}
+	if s.Security != "" && len(security) > 0 && !slices.Contains(security, s.Security) {
+		severity := SeverityMedium
+		if s.Security == "OPEN" || s.Security == "WEP" {
+			severity = SeverityHigh
+		}
+		raise(KindSecurityMismatch, severity, fmt.Sprintf("%s advertises %q with %s, expected %s", s.BSSID, s.SSID, s.Security, strings.Join(security, " or ")))
+	}
+
+	// The vendor follows from the BSSID, so listed BSSes always match.
+	vendors := network.Vendors
+	if len(vendors) == 0 {
+		vendors = b.vendors
+	}
+	if !listed && len(vendors) > 0 && !slices.Contains(vendors, s.Vendor) {
+		vendor := s.Vendor
+		switch {
+		case s.Randomized:
+			vendor = "a locally administered address"
+		case vendor == "":
+			vendor = "an unknown vendor"
+		}
+		raise(KindVendorMismatch, SeverityMedium, fmt.Sprintf("%s advertises %q from %s, expected %s", s.BSSID, s.SSID, vendor, strings.Join(vendors, " or ")))
+	}
+
+	// Legitimate APs move channels on their own, so learned channels are
+	// only held against BSSes that are not on the allowlist.
+	channels := network.Channels
+	if len(channels) == 0 && !listed {
+		channels = b.channels
+	}
+	if ch := model.Channel(s.FreqMHz); ch != 0 && len(channels) > 0 && !slices.Contains(channels, ch) {
+		raise(KindChannelMismatch, SeverityMedium, fmt.Sprintf("%s advertises %q on channel %d, expected %s", s.BSSID, s.SSID, ch, joinInts(channels)))
+	}
+
+	ts := s.TimestampUnixM
+	if ts == 0 {
+		ts = model.NowUnixMS()
+	}
+	if prev, ok := d.last[bssid]; ok && ts-prev.ts <= d.jumpWindow().Milliseconds() {
+		delta := s.SignalDBM - prev.signal
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta >= d.jumpDB() {
+			raise(KindSignalJump, SeverityMedium, fmt.Sprintf("%s (%q) jumped from %d to %d dBm; its BSSID may be spoofed", s.BSSID, s.SSID, prev.signal, s.SignalDBM))
+		}
+	}
+	d.last[bssid] = reading{signal: s.SignalDBM, ts: ts}
+
+	return raised
+}
+
+// raiseLocked records an alert and reports whether it is new. Repeats
+// of an active alert only bump its count and last-seen time.
+func (d *Detector) raiseLocked(kind, severity, message string, s model.Sample) (model.Alert, bool) {
+	now := model.NowUnixMS()
+	id := kind + "/" + strings.ToLower(s.BSSID)
+	if a, ok := d.alerts[id]; ok {
+		a.LastSeenMS = now
+		a.Count++
+		a.SignalDBM = s.SignalDBM
+		a.Message = message
+		return *a, false
+	}
+	a := &model.Alert{
+		ID:          id,
+		Kind:        kind,
+		State:       "firing",
+		Severity:    severity,
+		SSID:        s.SSID,
+		BSSID:       s.BSSID,
+		Message:     message,
+		SignalDBM:   s.SignalDBM,
+		FirstSeenMS: now,
+		LastSeenMS:  now,
+		Count:       1,
+	}
+	d.alerts[id] = a
+	return *a, true
+}
+
+func (d *Detector) expireLocked(now int64) {
+	expire := d.Expire
+	if expire <= 0 {
+		expire = defaultExpire
+	}
+	for id, a := range d.alerts {
+		if now-a.LastSeenMS > expire.Milliseconds() {
+			delete(d.alerts, id)
+		}
+	}
+}
+
+func (d *Detector) jumpDB() int {
+	if d.JumpDB > 0 {
+		return d.JumpDB
+	}
+	return defaultJumpDB
+}
+
+func (d *Detector) jumpWindow() time.Duration {
+	if d.JumpWindow > 0 {
+		return d.JumpWindow
+	}
+	return defaultJumpWindow
+}
+
+func appendUnique(list []string, v string) []string {
+	if v == "" || slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
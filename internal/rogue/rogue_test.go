@@ -0,0 +1,182 @@
+package rogue
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+func ap(bssid, ssid, security, vendor string, freq, signal int) model.Sample {
+	return model.Sample{
+		BSSID: bssid, SSID: ssid, Security: security, Vendor: vendor,
+		FreqMHz: freq, SignalDBM: signal, TimestampUnixM: model.NowUnixMS(),
+	}
+}
+
+// kinds returns "kind/bssid" for each alert, sorted.
+func kinds(alerts []model.Alert) []string {
+	var out []string
+	for _, a := range alerts {
+		out = append(out, a.ID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func checkKinds(t *testing.T, name string, alerts []model.Alert, want ...string) {
+	t.Helper()
+	got := kinds(alerts)
+	sort.Strings(want)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("%s: alerts = %v, want %v", name, got, want)
+	}
+}
+
+func TestListedBSSIDs(t *testing.T) {
+	var raised []model.Alert
+	d := &Detector{
+		Allowlist: Allowlist{Networks: []Network{{SSID: "Corp", BSSIDs: []string{"00:11:22:00:00:01", "00:11:22:00:00:02"}}}},
+		OnAlert:   func(a model.Alert) { raised = append(raised, a) },
+	}
+	// The baseline is learned from the listed BSSes only, even when a
+	// twin shows up in the same scan.
+	d.Observe([]model.Sample{
+		ap("00:11:22:00:00:01", "Corp", "WPA2", "Cisco", 2412, -50),
+		ap("00:11:22:00:00:02", "Corp", "WPA2", "Cisco", 5180, -60),
+		ap("66:77:88:00:00:09", "Corp", "OPEN", "Espressif", 2437, -40),
+		ap("66:77:88:00:00:0a", "Guest", "OPEN", "Espressif", 2437, -40),
+	})
+	checkKinds(t, "first scan", raised,
+		"unauthorized_bssid/66:77:88:00:00:09",
+		"security_mismatch/66:77:88:00:00:09",
+		"vendor_mismatch/66:77:88:00:00:09",
+		"channel_mismatch/66:77:88:00:00:09",
+	)
+	for _, a := range raised {
+		want := SeverityMedium
+		if a.Kind == KindUnauthorized || a.Kind == KindSecurityMismatch {
+			want = SeverityHigh // an open twin of a WPA2 network
+		}
+		if a.Severity != want || a.State != "firing" || a.SSID != "Corp" {
+			t.Errorf("%s: severity %s state %s ssid %q", a.ID, a.Severity, a.State, a.SSID)
+		}
+	}
+
+	// A listed AP changing channel is normal; repeats of the twin only
+	// bump the active alerts.
+	raised = nil
+	d.Observe([]model.Sample{
+		ap("00:11:22:00:00:01", "Corp", "WPA2", "Cisco", 2462, -50),
+		ap("66:77:88:00:00:09", "Corp", "OPEN", "Espressif", 2437, -40),
+	})
+	checkKinds(t, "second scan", raised)
+	for _, a := range d.Alerts() {
+		if a.Count != 2 {
+			t.Errorf("%s: count = %d, want 2", a.ID, a.Count)
+		}
+	}
+}
+
+func TestLearnedBaseline(t *testing.T) {
+	d := &Detector{Allowlist: Allowlist{Networks: []Network{{SSID: "Home"}}}}
+	d.Observe([]model.Sample{ap("aa:00:00:00:00:01", "Home", "WPA2", "Netgear", 2412, -50)})
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Fatalf("first sighting raised %v", kinds(alerts))
+	}
+
+	// The first sighting is frozen as the baseline: a twin on another
+	// channel with weaker security does not widen it.
+	twin := ap("02:00:00:00:00:07", "Home", "WPA", "", 2437, -45)
+	twin.Randomized = true
+	d.Observe([]model.Sample{twin})
+	d.Observe([]model.Sample{twin})
+	checkKinds(t, "twin", d.Alerts(),
+		"security_mismatch/02:00:00:00:00:07",
+		"vendor_mismatch/02:00:00:00:00:07",
+		"channel_mismatch/02:00:00:00:00:07",
+	)
+	for _, a := range d.Alerts() {
+		if a.Kind == KindSecurityMismatch && a.Severity != SeverityMedium {
+			t.Errorf("WPA twin severity = %s, want medium", a.Severity)
+		}
+		if a.Kind == KindVendorMismatch && !strings.Contains(a.Message, "locally administered") {
+			t.Errorf("vendor message = %q", a.Message)
+		}
+	}
+
+	d.Reset()
+	d.Observe([]model.Sample{twin})
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Errorf("after Reset the twin is the baseline, got %v", kinds(alerts))
+	}
+}
+
+func TestExplicitAllowlistFields(t *testing.T) {
+	d := &Detector{Allowlist: Allowlist{Networks: []Network{{
+		SSID: "Lab", Security: []string{"WPA3", "WPA2"}, Vendors: []string{"Aruba"}, Channels: []int{1, 36},
+	}}}}
+	d.Observe([]model.Sample{
+		ap("aa:00:00:00:00:01", "Lab", "WPA3", "Aruba", 5180, -50),
+		ap("aa:00:00:00:00:02", "Lab", "WPA2", "Aruba", 2437, -50),
+		ap("aa:00:00:00:00:03", "Lab", "WEP", "Aruba", 2412, -50),
+	})
+	checkKinds(t, "explicit", d.Alerts(),
+		"channel_mismatch/aa:00:00:00:00:02",
+		"security_mismatch/aa:00:00:00:00:03",
+	)
+}
+
+func TestSignalJump(t *testing.T) {
+	d := &Detector{Allowlist: Allowlist{Networks: []Network{{SSID: "Home"}}}, JumpDB: 20, JumpWindow: 5 * time.Second}
+	s := ap("aa:00:00:00:00:01", "Home", "WPA2", "", 2412, -70)
+	obs := func(signal int, ts int64) {
+		s.SignalDBM, s.TimestampUnixM = signal, ts
+		d.Observe([]model.Sample{s})
+	}
+
+	obs(-70, 1000)
+	obs(-55, 2000) // 15 dB
+	obs(-70, 9000) // 15 dB, outside the window anyway
+	obs(-40, 60000)
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Fatalf("no jump expected, got %v", kinds(alerts))
+	}
+	obs(-75, 61000) // 35 dB within a second
+	checkKinds(t, "jump", d.Alerts(), "signal_jump/aa:00:00:00:00:01")
+}
+
+func TestExpire(t *testing.T) {
+	d := &Detector{Allowlist: Allowlist{Networks: []Network{{SSID: "Corp", BSSIDs: []string{"00:11:22:00:00:01"}}}}, Expire: time.Millisecond}
+	d.Observe([]model.Sample{ap("66:77:88:00:00:09", "Corp", "", "", 0, -40)})
+	if n := len(d.Alerts()); n != 1 {
+		t.Fatalf("alerts = %d, want 1", n)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if alerts := d.Alerts(); len(alerts) != 0 {
+		t.Errorf("alerts after expiry = %v", kinds(alerts))
+	}
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"networks":[{"ssid":"Corp","bssids":[" 00:11:22:AA:BB:CC "]}]}`), 0o644)
+	list, err := LoadAllowlist(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := list.Networks[0].BSSIDs[0]; got != "00:11:22:aa:bb:cc" {
+		t.Errorf("bssid = %q, want it trimmed and lowercased", got)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"networks":[{"bssids":["00:11:22:aa:bb:cc"]}]}`), 0o644)
+	if _, err := LoadAllowlist(bad); err == nil || !strings.Contains(err.Error(), "no ssid") {
+		t.Errorf("network without ssid: err = %v", err)
+	}
+}
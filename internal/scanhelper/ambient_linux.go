@@ -0,0 +1,17 @@
+//go:build linux
+
+package scanhelper
+
+import (
+	"os"
+	"syscall"
+
+	"wifi-radar/internal/caps"
+)
+
+func sysProcAttr() *syscall.SysProcAttr {
+	if os.Geteuid() == 0 || !caps.Has(caps.NetAdmin) {
+		return nil
+	}
+	return &syscall.SysProcAttr{AmbientCaps: []uintptr{caps.NetAdmin}}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package scanhelper
+
+import "syscall"
+
+func sysProcAttr() *syscall.SysProcAttr { return nil }
@@ -0,0 +1,37 @@
+package scanhelper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// trustedIw is where the helper looks for iw. It never uses PATH, which
+// whoever starts the helper controls.
+var trustedIw = []string{"/usr/sbin/iw", "/sbin/iw", "/usr/bin/iw", "/bin/iw"}
+
+const runTimeout = 30 * time.Second
+
+// TrustedIw returns the first iw found in a system directory.
+func TrustedIw() (string, error) {
+	for _, path := range trustedIw {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", errors.New("iw not found in /usr/sbin, /sbin, /usr/bin or /bin")
+}
+
+// Run runs iw at path with a minimal environment and returns its combined
+// output. A CAP_NET_ADMIN that came from file capabilities is passed on
+// to iw as an ambient capability; exec would drop it otherwise.
+func Run(path string, args []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin", "LC_ALL=C"}
+	cmd.SysProcAttr = sysProcAttr()
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,246 @@
+// Package scanhelper runs iw scans in a small separate process that holds
+// CAP_NET_ADMIN (via setcap), so the main program needs no privileges.
+//
+// The protocol is one JSON Request per line on the helper's stdin and
+// one JSON Response per line on its stdout. The helper only ever builds
+// "iw dev <ifname> scan [trigger|dump] [freq ...] [ssid ...|passive]"
+// command lines from a request; it never runs arbitrary commands.
+package scanhelper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Name is the helper binary's name, looked up next to the main binary
+// and in PATH.
+const Name = "wifi-radar-scan"
+
+// Request modes besides the default full scan, which waits for results.
+const (
+	// ModeTrigger starts a scan and returns right away.
+	ModeTrigger = "trigger"
+	// ModeDump reads the kernel's cached results without scanning.
+	ModeDump = "dump"
+)
+
+type Request struct {
+	IfName string `json:"ifname"`
+	Mode   string `json:"mode,omitempty"`
+	// Freqs limits the scan to these frequencies in MHz.
+	Freqs []int `json:"freqs,omitempty"`
+	// SSIDs are probed for actively; they are ignored with Passive. iw
+	// cannot tell an SSID that is one of its keywords, such as "passive",
+	// from the keyword, so Args rejects those; see IwKeyword.
+	SSIDs   []string `json:"ssids,omitempty"`
+	Passive bool     `json:"passive,omitempty"`
+}
+
+type Response struct {
+	// Output is iw's combined stdout and stderr.
+	Output   []byte `json:"output"`
+	ExitCode int    `json:"exit_code"`
+	// Error is set when iw could not be run at all.
+	Error string `json:"error,omitempty"`
+}
+
+// ExitError reports iw exiting with a non-zero status in the helper.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+var ifnamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:@-]{1,15}$`)
+
+// iwScanKeywords are the words iw dev <if> scan takes anywhere in its
+// arguments, including in its SSID list.
+var iwScanKeywords = map[string]bool{
+	"freq": true, "ies": true, "meshid": true, "lowpri": true, "flush": true,
+	"ap-force": true, "duration": true, "duration-mandatory": true,
+	"randomise": true, "randomize": true, "coloc": true, "ssid": true, "passive": true,
+}
+
+// IwKeyword reports whether iw would read ssid as one of its scan
+// keywords rather than as an SSID.
+func IwKeyword(ssid string) bool {
+	return iwScanKeywords[ssid]
+}
+
+// Args validates req and returns iw's arguments for it.
+func Args(req Request) ([]string, error) {
+	if !ifnamePattern.MatchString(req.IfName) || strings.HasPrefix(req.IfName, "-") {
+		return nil, fmt.Errorf("invalid interface name %q", req.IfName)
+	}
+	args := []string{"dev", req.IfName, "scan"}
+	switch req.Mode {
+	case "":
+	case ModeTrigger:
+		args = append(args, ModeTrigger)
+	case ModeDump:
+		return append(args, ModeDump), nil
+	default:
+		return nil, fmt.Errorf("invalid mode %q", req.Mode)
+	}
+	if len(req.Freqs) > 0 {
+		args = append(args, "freq")
+		for _, f := range req.Freqs {
+			if f <= 0 || f > 100000 {
+				return nil, fmt.Errorf("invalid frequency %d", f)
+			}
+			args = append(args, strconv.Itoa(f))
+		}
+	}
+	switch {
+	case req.Passive:
+		args = append(args, "passive")
+	case len(req.SSIDs) > 0:
+		args = append(args, "ssid")
+		for _, ssid := range req.SSIDs {
+			if ssid == "" || len(ssid) > 32 || strings.ContainsRune(ssid, 0) {
+				return nil, fmt.Errorf("invalid SSID %q", ssid)
+			}
+			if IwKeyword(ssid) {
+				return nil, fmt.Errorf("invalid SSID %q: iw reads it as a keyword", ssid)
+			}
+			args = append(args, ssid)
+		}
+	}
+	return args, nil
+}
+
+// Serve answers requests from in until it is closed. run executes iw
+// with the given arguments.
+func Serve(in io.Reader, out io.Writer, run func(args []string) ([]byte, error)) error {
+	dec := json.NewDecoder(in)
+	enc := json.NewEncoder(out)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var resp Response
+		args, err := Args(req)
+		if err == nil {
+			resp.Output, err = run(args)
+		}
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			resp.ExitCode = exitErr.ExitCode()
+		case err != nil:
+			resp.Error = err.Error()
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Client talks to one helper process, starting it on first use and
+// again after it dies. It is safe for concurrent use; scans are
+// serialized.
+type Client struct {
+	Path string
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *json.Decoder
+}
+
+// Scan runs a scan in the helper and returns iw's output. A non-zero
+// exit status is returned as *ExitError along with the output.
+func (c *Client) Scan(req Request) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return nil, fmt.Errorf("start %s: %w", c.Path, err)
+		}
+	}
+	var resp Response
+	err := json.NewEncoder(c.in).Encode(req)
+	if err == nil {
+		err = c.out.Decode(&resp)
+	}
+	if err != nil {
+		c.stop()
+		return nil, fmt.Errorf("%s: %w", c.Path, err)
+	}
+	switch {
+	case resp.Error != "":
+		return resp.Output, errors.New(resp.Error)
+	case resp.ExitCode != 0:
+		return resp.Output, &ExitError{Code: resp.ExitCode}
+	}
+	return resp.Output, nil
+}
+
+// Close stops the helper process.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd != nil {
+		c.stop()
+	}
+	return nil
+}
+
+func (c *Client) start() error {
+	cmd := exec.Command(c.Path)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.cmd, c.in, c.out = cmd, in, json.NewDecoder(bufio.NewReader(out))
+	return nil
+}
+
+func (c *Client) stop() {
+	c.in.Close()
+	_ = c.cmd.Process.Kill()
+	_ = c.cmd.Wait()
+	c.cmd = nil
+}
+
+// Find returns the helper's path from $WIFI_RADAR_SCAN_HELPER, next to
+// the running binary or in PATH, or "" when there is none.
+func Find() string {
+	if env := strings.TrimSpace(os.Getenv("WIFI_RADAR_SCAN_HELPER")); env != "" {
+		return env
+	}
+	if exe, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exe), Name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	if path, err := exec.LookPath(Name); err == nil {
+		return path
+	}
+	return ""
+}
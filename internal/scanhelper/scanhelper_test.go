@@ -0,0 +1,125 @@
+package scanhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	for _, tt := range []struct {
+		req  Request
+		want string
+	}{
+		{Request{IfName: "wlan0"}, "dev wlan0 scan"},
+		{Request{IfName: "wlp3s0", Mode: ModeTrigger}, "dev wlp3s0 scan trigger"},
+		{Request{IfName: "wlan0", Mode: ModeDump}, "dev wlan0 scan dump"},
+		// Dump takes no scan parameters.
+		{Request{IfName: "wlan0", Mode: ModeDump, Freqs: []int{2412}, SSIDs: []string{"x"}}, "dev wlan0 scan dump"},
+		{Request{IfName: "wlan0", Freqs: []int{2412, 5180}}, "dev wlan0 scan freq 2412 5180"},
+		{Request{IfName: "wlan0", Mode: ModeTrigger, Freqs: []int{2437}, SSIDs: []string{"home", "cafe wifi"}}, "dev wlan0 scan trigger freq 2437 ssid home|cafe wifi"},
+		// SSIDs are ignored when passive.
+		{Request{IfName: "wlan0", SSIDs: []string{"home"}, Passive: true}, "dev wlan0 scan passive"},
+		{Request{IfName: "mon.wl0@x:1_a-b"}, "dev mon.wl0@x:1_a-b scan"},
+		{Request{IfName: "wlan0", SSIDs: []string{strings.Repeat("s", 32)}}, "dev wlan0 scan ssid " + strings.Repeat("s", 32)},
+		// Keywords are matched exactly.
+		{Request{IfName: "wlan0", SSIDs: []string{"Passive", "freq2"}}, "dev wlan0 scan ssid Passive|freq2"},
+	} {
+		args, err := Args(tt.req)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.req, err)
+			continue
+		}
+		// SSIDs may contain spaces, so they are joined with | here.
+		got := strings.Join(args, " ")
+		if i := indexOf(args, "ssid"); i >= 0 {
+			got = strings.Join(args[:i+1], " ") + " " + strings.Join(args[i+1:], "|")
+		}
+		if got != tt.want {
+			t.Errorf("%+v: %q, want %q", tt.req, got, tt.want)
+		}
+	}
+}
+
+func indexOf(args []string, s string) int {
+	for i, a := range args {
+		if a == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestArgsInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		req  Request
+		want string
+	}{
+		{Request{}, "invalid interface name"},
+		{Request{IfName: "-wlan0"}, "invalid interface name"},
+		{Request{IfName: "--help"}, "invalid interface name"},
+		{Request{IfName: "wlan0 scan"}, "invalid interface name"},
+		{Request{IfName: "wlan0;reboot"}, "invalid interface name"},
+		{Request{IfName: "../wlan0"}, "invalid interface name"},
+		{Request{IfName: "averyveryverylongname"}, "invalid interface name"},
+		{Request{IfName: "wlan0", Mode: "abort"}, "invalid mode"},
+		{Request{IfName: "wlan0", Mode: "-trigger"}, "invalid mode"},
+		{Request{IfName: "wlan0", Freqs: []int{0}}, "invalid frequency 0"},
+		{Request{IfName: "wlan0", Freqs: []int{2412, -1}}, "invalid frequency -1"},
+		{Request{IfName: "wlan0", Freqs: []int{100001}}, "invalid frequency 100001"},
+		{Request{IfName: "wlan0", SSIDs: []string{""}}, "invalid SSID"},
+		{Request{IfName: "wlan0", SSIDs: []string{strings.Repeat("s", 33)}}, "invalid SSID"},
+		{Request{IfName: "wlan0", SSIDs: []string{"a\x00b"}}, "invalid SSID"},
+		{Request{IfName: "wlan0", Mode: ModeTrigger, SSIDs: []string{"ok", ""}}, "invalid SSID"},
+		{Request{IfName: "wlan0", SSIDs: []string{"passive"}}, "iw reads it as a keyword"},
+		{Request{IfName: "wlan0", SSIDs: []string{"home", "freq"}}, "iw reads it as a keyword"},
+		{Request{IfName: "wlan0", Mode: ModeTrigger, SSIDs: []string{"ap-force"}}, "iw reads it as a keyword"},
+	} {
+		if args, err := Args(tt.req); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: %q, %v; want an error containing %q", tt.req, args, err, tt.want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	var in bytes.Buffer
+	enc := json.NewEncoder(&in)
+	enc.Encode(Request{IfName: "wlan0", Mode: ModeDump})
+	enc.Encode(Request{IfName: "-x"})
+	enc.Encode(Request{IfName: "wlan1"})
+
+	var ran []string
+	var out bytes.Buffer
+	err := Serve(&in, &out, func(args []string) ([]byte, error) {
+		ran = append(ran, strings.Join(args, " "))
+		if args[1] == "wlan1" {
+			return []byte("command failed: Device or resource busy (-16)"), exec.Command("false").Run()
+		}
+		return []byte("BSS aa:bb"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ran, ",") != "dev wlan0 scan dump,dev wlan1 scan" {
+		t.Errorf("ran %q; the invalid request must not run iw", ran)
+	}
+
+	dec := json.NewDecoder(&out)
+	var resps [3]Response
+	for i := range resps {
+		if err := dec.Decode(&resps[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(resps[0].Output) != "BSS aa:bb" || resps[0].ExitCode != 0 || resps[0].Error != "" {
+		t.Errorf("dump response = %+v", resps[0])
+	}
+	if !strings.Contains(resps[1].Error, "invalid interface name") {
+		t.Errorf("invalid request response = %+v", resps[1])
+	}
+	if resps[2].ExitCode != 1 || !strings.Contains(string(resps[2].Output), "(-16)") {
+		t.Errorf("failed scan response = %+v", resps[2])
+	}
+}
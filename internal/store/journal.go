@@ -0,0 +1,86 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+
+	"wifi-radar/internal/model"
+)
+
+const defaultReplay = 512
+
+// journal keeps the most recent events in a ring so stream clients can
+// read at their own pace and resume after reconnecting. Readers hold a
+// cursor (the last ID they saw) instead of a queue that could overflow.
+type journal struct {
+	mu     sync.Mutex
+	ring   []model.Event
+	size   int
+	nextID uint64
+	wake   chan struct{}
+}
+
+func newJournal(size int) *journal {
+	return &journal{
+		ring:   make([]model.Event, 0, size),
+		size:   size,
+		nextID: 1,
+		wake:   make(chan struct{}),
+	}
+}
+
+// append assigns ev the next ID and encodes its data, once for all readers.
+func (j *journal) append(ev model.Event) model.Event {
+	if ev.Payload == nil {
+		ev.Payload, _ = json.Marshal(ev.Data)
+	}
+	j.mu.Lock()
+	ev.ID = j.nextID
+	j.nextID++
+	if len(j.ring) < j.size {
+		j.ring = append(j.ring, ev)
+	} else {
+		j.ring[int((ev.ID-1)%uint64(j.size))] = ev
+	}
+	close(j.wake)
+	j.wake = make(chan struct{})
+	j.mu.Unlock()
+	return ev
+}
+
+// since returns the events after cursor, oldest first. missed reports
+// that some events after cursor have already left the ring. wait is
+// closed when the next event arrives.
+func (j *journal) since(cursor uint64) (events []model.Event, missed bool, wait <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	oldest := j.nextID - uint64(len(j.ring))
+	if cursor+1 < oldest {
+		missed = true
+		cursor = oldest - 1
+	}
+	for id := cursor + 1; id < j.nextID; id++ {
+		events = append(events, j.ring[int((id-1)%uint64(j.size))])
+	}
+	return events, missed, j.wake
+}
+
+func (j *journal) lastID() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.nextID - 1
+}
+
+// latest returns the newest event of type typ still in the ring.
+func (j *journal) latest(typ string) (model.Event, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id := j.nextID - 1; id >= j.nextID-uint64(len(j.ring)) && id > 0; id-- {
+		ev := j.ring[int((id-1)%uint64(j.size))]
+		if ev.Type == typ {
+			return ev, true
+		}
+	}
+	return model.Event{}, false
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"wifi-radar/internal/model"
+)
+
+// ids lists event IDs with their types' first letter, e.g. "3s 4a".
+func ids(events []model.Event) string {
+	var out []string
+	for _, ev := range events {
+		out = append(out, fmt.Sprintf("%d%c", ev.ID, ev.Type[0]))
+	}
+	return strings.Join(out, " ")
+}
+
+func filled(size, n int) *journal {
+	j := newJournal(size)
+	for i := 0; i < n; i++ {
+		j.append(model.Event{Type: model.EventStatus, Data: i + 1})
+	}
+	return j
+}
+
+func TestJournalSince(t *testing.T) {
+	for _, tt := range []struct {
+		appended int
+		cursor   uint64
+		want     string
+		missed   bool
+	}{
+		{0, 0, "", false},
+		{3, 0, "1s 2s 3s", false},
+		{3, 2, "3s", false},
+		{3, 3, "", false},
+		// A ring of 4 after 6 events holds 3 to 6.
+		{6, 0, "3s 4s 5s 6s", true},
+		{6, 1, "3s 4s 5s 6s", true},
+		{6, 2, "3s 4s 5s 6s", false},
+		{6, 4, "5s 6s", false},
+		{6, 6, "", false},
+		// Wrapped more than once.
+		{9, 4, "6s 7s 8s 9s", true},
+		{9, 5, "6s 7s 8s 9s", false},
+		{9, 8, "9s", false},
+	} {
+		events, missed, _ := filled(4, tt.appended).since(tt.cursor)
+		if got := ids(events); got != tt.want || missed != tt.missed {
+			t.Errorf("%d appended, since(%d) = %q, missed %v; want %q, %v", tt.appended, tt.cursor, got, missed, tt.want, tt.missed)
+		}
+	}
+}
+
+func TestJournalPayloadAndWake(t *testing.T) {
+	j := newJournal(4)
+	_, _, wait := j.since(0)
+	ev := j.append(model.Event{Type: model.EventAlert, Data: map[string]int{"n": 1}})
+	if ev.ID != 1 || string(ev.Payload) != `{"n":1}` {
+		t.Errorf("appended %+v", ev)
+	}
+	select {
+	case <-wait:
+	default:
+		t.Error("wait channel not closed by append")
+	}
+	if _, _, next := j.since(1); next == wait {
+		t.Error("since returned the closed wait channel")
+	}
+	if j.lastID() != 1 {
+		t.Errorf("lastID = %d", j.lastID())
+	}
+}
+
+func TestJournalLatest(t *testing.T) {
+	types := map[byte]string{
+		's': model.EventStatus, 'a': model.EventAlert, 't': model.EventTarget, 'c': model.EventCollector,
+	}
+	for _, tt := range []struct {
+		events string
+		typ    string
+		want   uint64 // 0 for none
+	}{
+		{"", model.EventStatus, 0},
+		{"sas", model.EventStatus, 3},
+		{"sas", model.EventAlert, 2},
+		{"sas", model.EventTarget, 0},
+		// With a ring of 4, "satsaa" keeps events 3 to 6.
+		{"satsaa", model.EventStatus, 4},
+		{"sataaa", model.EventStatus, 0},
+		{"sataaa", model.EventTarget, 3},
+		{"sataaa", model.EventAlert, 6},
+		{"ssssssst", model.EventStatus, 7},
+		{"tsssssss", model.EventTarget, 0},
+		{"ccccccccc", model.EventCollector, 9},
+	} {
+		j := newJournal(4)
+		for i := 0; i < len(tt.events); i++ {
+			j.append(model.Event{Type: types[tt.events[i]]})
+		}
+		ev, ok := j.latest(tt.typ)
+		if ok != (tt.want != 0) || ev.ID != tt.want || ok && ev.Type != tt.typ {
+			t.Errorf("%q: latest(%s) = %d %v, want %d", tt.events, tt.typ, ev.ID, ok, tt.want)
+		}
+	}
+}
+
+func TestReaderNext(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// ring is the journal size, 8 if zero.
+		ring      int
+		events    []string
+		cursor    uint64
+		want      string
+		missed    bool
+		coalesced uint64
+	}{
+		{"nothing new", 0, nil, 0, "", false, 0},
+		{"one status", 0, []string{model.EventStatus}, 0, "1s", false, 0},
+		{"only the newest status", 0, []string{model.EventStatus, model.EventStatus, model.EventStatus}, 0, "3s", false, 2},
+		{
+			"alerts and targets kept in order", 0,
+			[]string{model.EventStatus, model.EventAlert, model.EventStatus, model.EventTarget, model.EventStatus, model.EventScan},
+			0, "2a 4t 5s 6s", false, 2,
+		},
+		{
+			"newest status before other events", 0,
+			[]string{model.EventStatus, model.EventAlert, model.EventStatus, model.EventAlert, model.EventAlert},
+			0, "2a 3s 4a 5a", false, 1,
+		},
+		{"from a cursor", 0, []string{model.EventStatus, model.EventAlert, model.EventStatus}, 2, "3s", false, 0},
+		{
+			// Events 1 and 2 have left the ring.
+			"evicted cursor", 4,
+			[]string{model.EventAlert, model.EventAlert, model.EventStatus, model.EventAlert, model.EventStatus, model.EventTarget},
+			0, "4a 5s 6t", true, 1,
+		},
+		{
+			"evicted cursor after wrapping twice", 4,
+			[]string{
+				model.EventStatus, model.EventAlert, model.EventStatus, model.EventAlert, model.EventStatus,
+				model.EventTarget, model.EventStatus, model.EventAlert, model.EventStatus, model.EventStatus,
+			},
+			3, "8a 10s", true, 2,
+		},
+	} {
+		s := New(1)
+		size := tt.ring
+		if size == 0 {
+			size = 8
+		}
+		s.journal = newJournal(size)
+		for _, typ := range tt.events {
+			s.Publish(model.Event{Type: typ})
+		}
+		r := s.Follow(tt.cursor)
+		events, missed, _ := r.Next()
+		if got := ids(events); got != tt.want || missed != tt.missed {
+			t.Errorf("%s: Next() = %q, missed %v; want %q, %v", tt.name, got, missed, tt.want, tt.missed)
+		}
+		stats := s.Stats()
+		if stats.Coalesced != tt.coalesced || stats.Missed != map[bool]uint64{true: 1}[tt.missed] || stats.Readers != 1 {
+			t.Errorf("%s: stats = %+v", tt.name, stats)
+		}
+
+		// The cursor moved past everything returned.
+		if events, missed, _ := r.Next(); len(events) != 0 || missed {
+			t.Errorf("%s: second Next() = %q, missed %v", tt.name, ids(events), missed)
+		}
+		s.Publish(model.Event{Type: model.EventAlert})
+		if events, _, _ := r.Next(); ids(events) != fmt.Sprintf("%da", len(tt.events)+1) {
+			t.Errorf("%s: after one more event: %q", tt.name, ids(events))
+		}
+		r.Close()
+		if s.Stats().Readers != 0 {
+			t.Errorf("%s: reader not released", tt.name)
+		}
+	}
+}
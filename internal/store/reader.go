@@ -0,0 +1,50 @@
+package store
+
+import "wifi-radar/internal/model"
+
+// Reader follows the journal for one stream client. A reader that falls
+// behind never holds up the store; it skips to the newest status instead.
+type Reader struct {
+	s      *Store
+	cursor uint64
+}
+
+// Follow returns a reader positioned after the event with ID cursor.
+func (s *Store) Follow(cursor uint64) *Reader {
+	s.readers.Add(1)
+	return &Reader{s: s, cursor: cursor}
+}
+
+// Next returns the events since the previous call, with every status
+// update but the newest dropped, whether some events had already left the
+// journal, and a channel closed when the next event arrives.
+func (r *Reader) Next() ([]model.Event, bool, <-chan struct{}) {
+	events, missed, wait := r.s.journal.since(r.cursor)
+	if missed {
+		r.s.missed.Add(1)
+	}
+	if len(events) == 0 {
+		return nil, missed, wait
+	}
+	r.cursor = events[len(events)-1].ID
+
+	last := -1
+	for i, ev := range events {
+		if ev.Type == model.EventStatus {
+			last = i
+		}
+	}
+	out := events[:0]
+	for i, ev := range events {
+		if ev.Type == model.EventStatus && i != last {
+			r.s.coalesced.Add(1)
+			continue
+		}
+		out = append(out, ev)
+	}
+	return out, missed, wait
+}
+
+func (r *Reader) Close() {
+	r.s.readers.Add(-1)
+}
@@ -1,18 +1,45 @@
 package store
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"wifi-radar/internal/model"
 )
 
 type Store struct {
-	mu          sync.RWMutex
-	histories   map[string]*history
-	subscribers map[chan model.Status]struct{}
-	maxSamples  int
+	mu           sync.RWMutex
+	histories    map[string]*history
+	recent       map[string][]model.Sample
+	observers    []func(model.Sample)
+	networks     map[string][]model.Sample
+	netObservers []func([]model.Sample)
+	locate       func() *model.Location
+	decorate     func(model.Status) model.Status
+	maxSamples   int
+	journal      *journal
+
+	readers    atomic.Int64
+	broadcasts atomic.Uint64
+	missed     atomic.Uint64
+	coalesced  atomic.Uint64
+}
+
+type Stats struct {
+	Readers    int
+	Broadcasts uint64
+	// Missed counts reads that found events already evicted from the
+	// journal; Coalesced counts status updates skipped because a newer one
+	// was already waiting for the same reader.
+	Missed    uint64
+	Coalesced uint64
 }
 
+// historyLen is how many raw samples per interface History keeps, about
+// ten minutes at the default interval.
+const historyLen = 1200
+
 type history struct {
 	samples []model.Sample
 	max     int
@@ -23,30 +50,118 @@ func New(maxSamples int) *Store {
 		maxSamples = 1
 	}
 	return &Store{
-		histories:   make(map[string]*history),
-		subscribers: make(map[chan model.Status]struct{}),
-		maxSamples:  maxSamples,
+		histories:  make(map[string]*history),
+		recent:     make(map[string][]model.Sample),
+		networks:   make(map[string][]model.Sample),
+		maxSamples: maxSamples,
+		journal:    newJournal(defaultReplay),
 	}
 }
 
 func (s *Store) Update(sample model.Sample) {
 	s.mu.Lock()
+	if sample.Location == nil && s.locate != nil {
+		sample.Location = s.locate()
+	}
 	h := s.histories[sample.IfName]
 	if h == nil {
 		h = &history{max: s.maxSamples}
 		s.histories[sample.IfName] = h
 	}
 	h.add(sample)
+	recent := append(s.recent[sample.IfName], sample)
+	if len(recent) > historyLen {
+		recent = recent[len(recent)-historyLen:]
+	}
+	s.recent[sample.IfName] = recent
+	observers := s.observers
+	s.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(sample)
+	}
+
+	s.mu.RLock()
 	status := s.latestStatusLocked()
-	for ch := range s.subscribers {
-		select {
-		case ch <- status:
-		default:
+	decorate := s.decorate
+	s.mu.RUnlock()
+
+	// Decorate outside the lock: decorators read smoothed samples back.
+	if decorate != nil {
+		status = decorate(status)
+	}
+	s.journal.append(model.Event{Type: model.EventStatus, Data: status})
+	s.broadcasts.Add(1)
+}
+
+// SetLocator makes the store stamp samples and scan results that carry no
+// location with the result of fn, which may return nil.
+func (s *Store) SetLocator(fn func() *model.Location) {
+	s.mu.Lock()
+	s.locate = fn
+	s.mu.Unlock()
+}
+
+// SetDecorator makes the store pass every status through fn before it is
+// journaled, so derived data is computed and encoded once per update
+// rather than once per stream client.
+func (s *Store) SetDecorator(fn func(model.Status) model.Status) {
+	s.mu.Lock()
+	s.decorate = fn
+	s.mu.Unlock()
+}
+
+// Observe registers fn to be called with every sample passed to Update,
+// before the new status is journaled.
+func (s *Store) Observe(fn func(model.Sample)) {
+	s.mu.Lock()
+	s.observers = append(s.observers, fn)
+	s.mu.Unlock()
+}
+
+// UpdateNetworks replaces the scan results for ifname. Stream clients see
+// them with the next status update.
+func (s *Store) UpdateNetworks(ifname string, networks []model.Sample) {
+	s.mu.Lock()
+	if s.locate != nil {
+		loc := s.locate()
+		stamped := make([]model.Sample, len(networks))
+		for i, n := range networks {
+			if n.Location == nil {
+				n.Location = loc
+			}
+			stamped[i] = n
 		}
+		networks = stamped
+	}
+	s.networks[ifname] = networks
+	observers := s.netObservers
+	s.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(networks)
 	}
+	s.Publish(model.Event{Type: model.EventScan, Data: model.ScanComplete{
+		IfName:   ifname,
+		Networks: len(networks),
+		TSUnixMS: model.NowUnixMS(),
+	}})
+}
+
+// ObserveNetworks registers fn to be called with every scan result passed
+// to UpdateNetworks.
+func (s *Store) ObserveNetworks(fn func([]model.Sample)) {
+	s.mu.Lock()
+	s.netObservers = append(s.netObservers, fn)
 	s.mu.Unlock()
 }
 
+func (s *Store) Networks() []model.Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.networksLocked()
+}
+
 func (s *Store) LatestStatus() model.Status {
 	s.mu.RLock()
 	status := s.latestStatusLocked()
@@ -68,19 +183,87 @@ func (s *Store) SmoothedSamples() []model.Sample {
 	return out
 }
 
-func (s *Store) Subscribe() chan model.Status {
-	ch := make(chan model.Status, 4)
-	s.mu.Lock()
-	s.subscribers[ch] = struct{}{}
-	s.mu.Unlock()
-	return ch
+// History returns the raw samples for ifname (all interfaces if empty)
+// newer than sinceMS, oldest first, at most limit of the newest when
+// limit > 0.
+func (s *Store) History(ifname string, sinceMS int64, limit int) []model.Sample {
+	s.mu.RLock()
+	out := []model.Sample{}
+	for name, samples := range s.recent {
+		if ifname != "" && name != ifname {
+			continue
+		}
+		for _, sample := range samples {
+			if sample.TimestampUnixM > sinceMS {
+				out = append(out, sample)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].TimestampUnixM < out[j].TimestampUnixM
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+func (s *Store) SmoothedSample(ifname string) (model.Sample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h := s.histories[ifname]
+	if h == nil || len(h.samples) == 0 {
+		return model.Sample{}, false
+	}
+	return h.average(), true
+}
+
+// Publish journals ev for stream clients.
+func (s *Store) Publish(ev model.Event) {
+	s.journal.append(ev)
+}
+
+func (s *Store) LastEventID() uint64 {
+	return s.journal.lastID()
+}
+
+// LatestEvent returns the newest journaled event of type typ.
+func (s *Store) LatestEvent(typ string) (model.Event, bool) {
+	return s.journal.latest(typ)
 }
 
-func (s *Store) Unsubscribe(ch chan model.Status) {
+// SetSmoothing changes how many samples per interface are averaged.
+func (s *Store) SetSmoothing(maxSamples int) {
+	if maxSamples < 1 {
+		maxSamples = 1
+	}
 	s.mu.Lock()
-	delete(s.subscribers, ch)
-	close(ch)
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+	s.maxSamples = maxSamples
+	for _, h := range s.histories {
+		h.max = maxSamples
+		if len(h.samples) > maxSamples {
+			h.samples = h.samples[len(h.samples)-maxSamples:]
+		}
+	}
+}
+
+func (s *Store) Smoothing() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxSamples
+}
+
+func (s *Store) Stats() Stats {
+	return Stats{
+		Readers:    int(s.readers.Load()),
+		Broadcasts: s.broadcasts.Load(),
+		Missed:     s.missed.Load(),
+		Coalesced:  s.coalesced.Load(),
+	}
 }
 
 func (s *Store) latestStatusLocked() model.Status {
@@ -91,9 +274,24 @@ func (s *Store) latestStatusLocked() model.Status {
 		}
 		status.Interfaces = append(status.Interfaces, h.samples[len(h.samples)-1])
 	}
+	status.Networks = s.networksLocked()
 	return status
 }
 
+func (s *Store) networksLocked() []model.Sample {
+	var out []model.Sample
+	for _, networks := range s.networks {
+		out = append(out, networks...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SignalDBM == out[j].SignalDBM {
+			return out[i].BSSID < out[j].BSSID
+		}
+		return out[i].SignalDBM > out[j].SignalDBM
+	})
+	return out
+}
+
 func (h *history) add(sample model.Sample) {
 	h.samples = append(h.samples, sample)
 	if len(h.samples) > h.max {
@@ -0,0 +1,295 @@
+package survey
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+const (
+	MethodIDW     = "idw"
+	MethodKriging = "kriging"
+
+	MetricRSSI = "rssi"
+	MetricSNR  = "snr"
+
+	defaultCells = 80
+	minCell      = 4
+	idwPower     = 2
+	heatAlpha    = 170
+)
+
+var ErrNoSamples = errors.New("no survey points observed this BSS")
+
+type HeatmapOptions struct {
+	BSSID  string
+	Method string
+	Metric string
+	// Cell is the grid cell size in floor plan pixels; 0 picks one.
+	Cell int
+	// NoiseDBM is the noise floor assumed for SNR.
+	NoiseDBM float64
+}
+
+type Grid struct {
+	BSSID     string       `json:"bssid"`
+	Method    string       `json:"method"`
+	Metric    string       `json:"metric"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Cell      int          `json:"cell"`
+	Cols      int          `json:"cols"`
+	Rows      int          `json:"rows"`
+	Min       float64      `json:"min"`
+	Max       float64      `json:"max"`
+	ScaleMin  float64      `json:"scale_min"`
+	ScaleMax  float64      `json:"scale_max"`
+	Values    [][]float64  `json:"values"`
+	Samples   []GridSample `json:"samples"`
+	Variogram *Variogram   `json:"variogram,omitempty"`
+}
+
+type GridSample struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Value float64 `json:"value"`
+}
+
+// Heatmap interpolates the chosen metric for one BSS over the floor plan.
+// Points where the BSS was not heard are left out rather than treated as
+// a floor value.
+func Heatmap(p Project, opts HeatmapOptions) (Grid, error) {
+	if opts.Method == "" {
+		opts.Method = MethodIDW
+	}
+	if opts.Metric == "" {
+		opts.Metric = MetricRSSI
+	}
+	if opts.Method != MethodIDW && opts.Method != MethodKriging {
+		return Grid{}, fmt.Errorf("invalid method %q (use idw or kriging)", opts.Method)
+	}
+	if opts.Metric != MetricRSSI && opts.Metric != MetricSNR {
+		return Grid{}, fmt.Errorf("invalid metric %q (use rssi or snr)", opts.Metric)
+	}
+	if p.Width <= 0 || p.Height <= 0 {
+		return Grid{}, errors.New("survey has no floor plan size")
+	}
+
+	samples := samplesFor(p, opts)
+	if len(samples) == 0 {
+		return Grid{}, ErrNoSamples
+	}
+
+	cell := opts.Cell
+	if cell <= 0 {
+		cell = max(p.Width, p.Height) / defaultCells
+	}
+	cell = max(cell, minCell)
+
+	g := Grid{
+		BSSID:   opts.BSSID,
+		Method:  opts.Method,
+		Metric:  opts.Metric,
+		Width:   p.Width,
+		Height:  p.Height,
+		Cell:    cell,
+		Cols:    (p.Width + cell - 1) / cell,
+		Rows:    (p.Height + cell - 1) / cell,
+		Samples: samples,
+	}
+	g.ScaleMin, g.ScaleMax = -90, -30
+	if opts.Metric == MetricSNR {
+		g.ScaleMin, g.ScaleMax = 0, 60
+	}
+
+	estimate := idw(samples)
+	if opts.Method == MethodKriging {
+		k, err := newKriging(samples)
+		if err != nil {
+			return Grid{}, err
+		}
+		estimate = k.estimate
+		g.Variogram = &k.variogram
+	}
+
+	g.Min, g.Max = math.Inf(1), math.Inf(-1)
+	g.Values = make([][]float64, g.Rows)
+	for r := 0; r < g.Rows; r++ {
+		row := make([]float64, g.Cols)
+		y := (float64(r) + 0.5) * float64(cell)
+		for c := 0; c < g.Cols; c++ {
+			x := (float64(c) + 0.5) * float64(cell)
+			v := math.Round(estimate(x, y)*10) / 10
+			row[c] = v
+			g.Min = math.Min(g.Min, v)
+			g.Max = math.Max(g.Max, v)
+		}
+		g.Values[r] = row
+	}
+	return g, nil
+}
+
+// RenderPNG draws the grid at floor plan size, bilinearly smoothed and
+// colored red (weak) through yellow to green (strong). With a floor plan
+// image the heatmap is blended over it; otherwise the background is
+// transparent so it can be layered in the browser.
+func RenderPNG(w io.Writer, g Grid, floorplan image.Image) error {
+	bounds := image.Rect(0, 0, g.Width, g.Height)
+	out := image.NewRGBA(bounds)
+	if floorplan != nil {
+		draw.Draw(out, bounds, floorplan, floorplan.Bounds().Min, draw.Src)
+	}
+
+	heat := image.NewRGBA(bounds)
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			v := g.at(float64(x), float64(y))
+			t := (v - g.ScaleMin) / (g.ScaleMax - g.ScaleMin)
+			heat.SetRGBA(x, y, colorFor(t))
+		}
+	}
+	for _, s := range g.Samples {
+		dot(heat, int(s.X), int(s.Y))
+	}
+	draw.Draw(out, bounds, heat, image.Point{}, draw.Over)
+	return png.Encode(w, out)
+}
+
+func (g Grid) at(x float64, y float64) float64 {
+	fx := x/float64(g.Cell) - 0.5
+	fy := y/float64(g.Cell) - 0.5
+	c0 := clampInt(int(math.Floor(fx)), 0, g.Cols-1)
+	r0 := clampInt(int(math.Floor(fy)), 0, g.Rows-1)
+	c1 := clampInt(c0+1, 0, g.Cols-1)
+	r1 := clampInt(r0+1, 0, g.Rows-1)
+	tx := math.Max(0, math.Min(1, fx-float64(c0)))
+	ty := math.Max(0, math.Min(1, fy-float64(r0)))
+
+	top := g.Values[r0][c0]*(1-tx) + g.Values[r0][c1]*tx
+	bottom := g.Values[r1][c0]*(1-tx) + g.Values[r1][c1]*tx
+	return top*(1-ty) + bottom*ty
+}
+
+func samplesFor(p Project, opts HeatmapOptions) []GridSample {
+	var out []GridSample
+	for _, pt := range p.Points {
+		var sum, n float64
+		for _, o := range pt.Observations {
+			if o.BSSID != opts.BSSID {
+				continue
+			}
+			sum += float64(o.SignalDBM)
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		v := sum / n
+		if opts.Metric == MetricSNR {
+			v -= opts.NoiseDBM
+		}
+		out = append(out, GridSample{X: pt.X, Y: pt.Y, Value: math.Round(v*10) / 10})
+	}
+	return mergeDuplicates(out)
+}
+
+// mergeDuplicates averages samples taken at the same spot, which would
+// otherwise make the kriging system singular.
+func mergeDuplicates(samples []GridSample) []GridSample {
+	type key struct{ x, y float64 }
+	index := make(map[key]int)
+	counts := make([]float64, 0, len(samples))
+	out := make([]GridSample, 0, len(samples))
+	for _, s := range samples {
+		k := key{math.Round(s.X), math.Round(s.Y)}
+		if i, ok := index[k]; ok {
+			out[i].Value = (out[i].Value*counts[i] + s.Value) / (counts[i] + 1)
+			counts[i]++
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, s)
+		counts = append(counts, 1)
+	}
+	return out
+}
+
+func idw(samples []GridSample) func(x, y float64) float64 {
+	return func(x, y float64) float64 {
+		var num, den float64
+		for _, s := range samples {
+			d2 := (s.X-x)*(s.X-x) + (s.Y-y)*(s.Y-y)
+			if d2 < 1e-9 {
+				return s.Value
+			}
+			w := 1 / math.Pow(d2, idwPower/2.0)
+			num += w * s.Value
+			den += w
+		}
+		return num / den
+	}
+}
+
+// colorFor maps t in [0, 1] onto a red-yellow-green ramp.
+func colorFor(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t))
+	stops := []struct {
+		t       float64
+		r, g, b float64
+	}{
+		{0, 215, 48, 39},
+		{0.5, 254, 224, 139},
+		{1, 26, 152, 80},
+	}
+	for i := 1; i < len(stops); i++ {
+		if t > stops[i].t {
+			continue
+		}
+		a, b := stops[i-1], stops[i]
+		f := (t - a.t) / (b.t - a.t)
+		return premultiply(
+			a.r+(b.r-a.r)*f,
+			a.g+(b.g-a.g)*f,
+			a.b+(b.b-a.b)*f,
+			heatAlpha,
+		)
+	}
+	last := stops[len(stops)-1]
+	return premultiply(last.r, last.g, last.b, heatAlpha)
+}
+
+func premultiply(r, g, b float64, a uint8) color.RGBA {
+	f := float64(a) / 255
+	return color.RGBA{R: uint8(r * f), G: uint8(g * f), B: uint8(b * f), A: a}
+}
+
+func dot(img *image.RGBA, cx int, cy int) {
+	const radius = 4
+	bounds := img.Bounds()
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) > radius*radius {
+				continue
+			}
+			if !(image.Point{X: x, Y: y}).In(bounds) {
+				continue
+			}
+			img.SetRGBA(x, y, color.RGBA{R: 15, G: 27, B: 36, A: 255})
+		}
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
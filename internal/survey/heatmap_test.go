@@ -0,0 +1,200 @@
+package survey
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func project(points ...Point) Project {
+	return Project{Width: 100, Height: 60, Points: points}
+}
+
+func pointAt(x, y float64, obs ...Observation) Point {
+	return Point{X: x, Y: y, Observations: obs}
+}
+
+func obs(bssid string, dbm int) Observation {
+	return Observation{BSSID: bssid, SignalDBM: dbm}
+}
+
+func TestIDW(t *testing.T) {
+	samples := []GridSample{{X: 0, Y: 0, Value: -40}, {X: 10, Y: 0, Value: -60}}
+	f := idw(samples)
+	near(t, "at a sample", f(0, 0), -40, 0)
+	near(t, "midway", f(5, 0), -50, 1e-9)
+	// Weights fall off with the squared distance: 1/4 vs 1/64.
+	near(t, "a quarter of the way", f(2, 0), (-40.0/4-60.0/64)/(1.0/4+1.0/64), 1e-9)
+	near(t, "far away", f(5, 1e6), -50, 1e-3)
+	// Every estimate stays within the sampled range.
+	for x := -20.0; x <= 30; x += 2.5 {
+		if v := f(x, 3); v > -40 || v < -60 {
+			t.Errorf("idw(%v, 3) = %v out of range", x, v)
+		}
+	}
+}
+
+func TestSamplesFor(t *testing.T) {
+	p := project(
+		pointAt(10, 10, obs("aa", -50), obs("aa", -54), obs("bb", -70)),
+		pointAt(20, 10, obs("bb", -60)),
+		// The same spot again is averaged in.
+		pointAt(10.2, 9.8, obs("aa", -40)),
+	)
+	got := samplesFor(p, HeatmapOptions{BSSID: "aa", Metric: MetricRSSI})
+	if len(got) != 1 || got[0].X != 10 || got[0].Value != (-52-40)/2.0 {
+		t.Errorf("aa samples = %+v", got)
+	}
+	got = samplesFor(p, HeatmapOptions{BSSID: "bb", Metric: MetricSNR, NoiseDBM: -95})
+	if len(got) != 2 || got[0].Value != 25 || got[1].Value != 35 {
+		t.Errorf("bb SNR samples = %+v", got)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	p := project(
+		pointAt(0, 0, obs("aa", -40)),
+		pointAt(100, 0, obs("aa", -80)),
+		pointAt(0, 60, obs("aa", -40)),
+		pointAt(100, 60, obs("aa", -80)),
+		pointAt(50, 30, obs("bb", -30)),
+	)
+	g, err := Heatmap(p, HeatmapOptions{BSSID: "aa", Cell: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Method != MethodIDW || g.Metric != MetricRSSI || g.Cols != 10 || g.Rows != 6 || len(g.Values) != 6 || len(g.Values[0]) != 10 {
+		t.Fatalf("grid %s/%s %dx%d", g.Method, g.Metric, g.Cols, g.Rows)
+	}
+	if len(g.Samples) != 4 || g.ScaleMin != -90 || g.ScaleMax != -30 {
+		t.Errorf("samples %d, scale %v..%v", len(g.Samples), g.ScaleMin, g.ScaleMax)
+	}
+	// Stronger on the left, symmetric top to bottom.
+	if g.Values[3][0] <= g.Values[3][9] || g.Values[0][4] != g.Values[5][4] {
+		t.Errorf("values = %v", g.Values)
+	}
+	if g.Min < -80 || g.Max > -40 || g.Min >= g.Max {
+		t.Errorf("min %v, max %v", g.Min, g.Max)
+	}
+
+	// Kriging needs a denser survey: a falling signal from left to right.
+	var dense Project
+	dense.Width, dense.Height = 100, 60
+	for x := 0.0; x <= 100; x += 20 {
+		for y := 0.0; y <= 60; y += 20 {
+			dense.Points = append(dense.Points, pointAt(x, y, obs("aa", -40-int(x/5))))
+		}
+	}
+	k, err := Heatmap(dense, HeatmapOptions{BSSID: "aa", Method: MethodKriging, Metric: MetricSNR, NoiseDBM: -90, Cell: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Variogram == nil || k.ScaleMin != 0 || k.ScaleMax != 60 || k.Values[3][0] <= k.Values[3][9] {
+		t.Errorf("kriging SNR grid: variogram %v, scale %v..%v", k.Variogram, k.ScaleMin, k.ScaleMax)
+	}
+
+	// The cell size defaults from the floor plan size, with a minimum.
+	if g, _ := Heatmap(project(pointAt(1, 1, obs("aa", -50))), HeatmapOptions{BSSID: "aa"}); g.Cell != minCell {
+		t.Errorf("default cell = %d, want %d", g.Cell, minCell)
+	}
+
+	for name, opts := range map[string]HeatmapOptions{
+		"method": {BSSID: "aa", Method: "nearest"},
+		"metric": {BSSID: "aa", Metric: "noise"},
+	} {
+		if _, err := Heatmap(p, opts); err == nil {
+			t.Errorf("invalid %s accepted", name)
+		}
+	}
+	if _, err := Heatmap(p, HeatmapOptions{BSSID: "cc"}); err != ErrNoSamples {
+		t.Errorf("unheard BSS: %v", err)
+	}
+	if _, err := Heatmap(Project{Points: p.Points}, HeatmapOptions{BSSID: "aa"}); err == nil {
+		t.Error("project without a floor plan size accepted")
+	}
+}
+
+func TestGridAt(t *testing.T) {
+	g := Grid{Cell: 10, Cols: 2, Rows: 2, Values: [][]float64{{0, 10}, {20, 30}}}
+	for _, tt := range []struct{ x, y, want float64 }{
+		{5, 5, 0},   // a cell center
+		{15, 5, 10}, // the next one
+		{10, 5, 5},  // halfway between
+		{10, 10, 15},
+		{0, 0, 0}, // clamped at the edges
+		{100, 100, 30},
+	} {
+		if got := g.at(tt.x, tt.y); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("at(%v, %v) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestColorFor(t *testing.T) {
+	for _, tt := range []struct {
+		t    float64
+		want color.RGBA
+	}{
+		{-1, premultiply(215, 48, 39, heatAlpha)},
+		{0, premultiply(215, 48, 39, heatAlpha)},
+		{0.5, premultiply(254, 224, 139, heatAlpha)},
+		{1, premultiply(26, 152, 80, heatAlpha)},
+		{2, premultiply(26, 152, 80, heatAlpha)},
+	} {
+		if got := colorFor(tt.t); got != tt.want {
+			t.Errorf("colorFor(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+	if c := colorFor(0.25); c.R <= colorFor(0.75).R || c.G >= colorFor(0.5).G {
+		t.Errorf("colorFor(0.25) = %v not between red and yellow", c)
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	p := project(pointAt(20, 20, obs("aa", -30)), pointAt(80, 40, obs("aa", -90)))
+	g, err := Heatmap(p, HeatmapOptions{BSSID: "aa", Cell: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decode := func(floorplan image.Image) image.Image {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := RenderPNG(&buf, g, floorplan); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 100, 60) {
+			t.Fatalf("bounds = %v", img.Bounds())
+		}
+		return img
+	}
+
+	img := decode(nil)
+	// The survey points are drawn as opaque dots.
+	if r, g, b, a := img.At(20, 20).RGBA(); a != 0xffff || r>>8 != 15 || g>>8 != 27 || b>>8 != 36 {
+		t.Errorf("dot color = %v", img.At(20, 20))
+	}
+	// Without a floor plan the heat layer stays translucent, green near
+	// the strong point and red near the weak one.
+	_, _, _, a := img.At(0, 59).RGBA()
+	if a>>8 != heatAlpha {
+		t.Errorf("background alpha = %d, want %d", a>>8, heatAlpha)
+	}
+	strong, weak := color.NRGBAModel.Convert(img.At(30, 10)).(color.NRGBA), color.NRGBAModel.Convert(img.At(95, 55)).(color.NRGBA)
+	if strong.G <= strong.R || weak.R <= weak.G {
+		t.Errorf("strong %v, weak %v", strong, weak)
+	}
+
+	// Over a floor plan the result is opaque.
+	plan := image.NewUniform(color.White)
+	if _, _, _, a := decode(plan).At(0, 59).RGBA(); a != 0xffff {
+		t.Errorf("alpha over a floor plan = %d", a)
+	}
+}
@@ -0,0 +1,205 @@
+package survey
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	variogramBins = 12
+	minKriging    = 3
+)
+
+// Variogram is an exponential semivariogram model:
+// γ(h) = nugget + (sill - nugget)·(1 - exp(-3h/range)).
+type Variogram struct {
+	Nugget float64 `json:"nugget"`
+	Sill   float64 `json:"sill"`
+	Range  float64 `json:"range"`
+}
+
+func (v Variogram) at(h float64) float64 {
+	if h <= 0 {
+		return 0
+	}
+	return v.Nugget + (v.Sill-v.Nugget)*(1-math.Exp(-3*h/v.Range))
+}
+
+// kriging is an ordinary kriging interpolator with the system matrix
+// factored once so each grid cell costs a single back substitution.
+type kriging struct {
+	samples   []GridSample
+	variogram Variogram
+	lu        [][]float64
+	perm      []int
+	mean      float64
+	constant  bool
+}
+
+func newKriging(samples []GridSample) (*kriging, error) {
+	if len(samples) < minKriging {
+		return nil, errors.New("kriging needs at least 3 survey points for this BSS")
+	}
+
+	k := &kriging{samples: samples}
+	var sum, sumSq float64
+	for _, s := range samples {
+		sum += s.Value
+		sumSq += s.Value * s.Value
+	}
+	n := float64(len(samples))
+	k.mean = sum / n
+	variance := sumSq/n - k.mean*k.mean
+	if variance < 1e-6 {
+		k.constant = true
+		return k, nil
+	}
+	k.variogram = fitVariogram(samples, variance)
+
+	size := len(samples) + 1
+	m := make([][]float64, size)
+	for i := range m {
+		m[i] = make([]float64, size)
+	}
+	for i, a := range samples {
+		for j, b := range samples {
+			m[i][j] = k.variogram.at(math.Hypot(a.X-b.X, a.Y-b.Y))
+		}
+		m[i][size-1] = 1
+		m[size-1][i] = 1
+	}
+
+	perm, ok := luDecompose(m)
+	if !ok {
+		return nil, errors.New("kriging system is singular; add more distinct survey points")
+	}
+	k.lu = m
+	k.perm = perm
+	return k, nil
+}
+
+func (k *kriging) estimate(x float64, y float64) float64 {
+	if k.constant {
+		return k.mean
+	}
+
+	size := len(k.samples) + 1
+	rhs := make([]float64, size)
+	for i, s := range k.samples {
+		rhs[i] = k.variogram.at(math.Hypot(s.X-x, s.Y-y))
+	}
+	rhs[size-1] = 1
+
+	weights := luSolve(k.lu, k.perm, rhs)
+	var v float64
+	for i, s := range k.samples {
+		v += weights[i] * s.Value
+	}
+	return v
+}
+
+// fitVariogram bins the empirical semivariogram and grid-searches nugget
+// and range for the exponential model, with the sill fixed at the sample
+// variance. Bins are weighted by their pair counts.
+func fitVariogram(samples []GridSample, variance float64) Variogram {
+	var maxDist float64
+	for i := range samples {
+		for j := i + 1; j < len(samples); j++ {
+			maxDist = math.Max(maxDist, math.Hypot(samples[i].X-samples[j].X, samples[i].Y-samples[j].Y))
+		}
+	}
+	if maxDist == 0 {
+		return Variogram{Sill: variance, Range: 1}
+	}
+
+	lag := maxDist / 2 / variogramBins
+	var (
+		gamma [variogramBins]float64
+		count [variogramBins]float64
+		dist  [variogramBins]float64
+	)
+	for i := range samples {
+		for j := i + 1; j < len(samples); j++ {
+			h := math.Hypot(samples[i].X-samples[j].X, samples[i].Y-samples[j].Y)
+			b := int(h / lag)
+			if b >= variogramBins {
+				continue
+			}
+			d := samples[i].Value - samples[j].Value
+			gamma[b] += d * d / 2
+			dist[b] += h
+			count[b]++
+		}
+	}
+
+	best := Variogram{Sill: variance, Range: maxDist / 3}
+	bestErr := math.Inf(1)
+	for _, nuggetFrac := range []float64{0, 0.1, 0.2, 0.3, 0.5} {
+		for step := 1; step <= 20; step++ {
+			v := Variogram{
+				Nugget: nuggetFrac * variance,
+				Sill:   variance,
+				Range:  maxDist * float64(step) / 20,
+			}
+			var sse float64
+			for b := 0; b < variogramBins; b++ {
+				if count[b] == 0 {
+					continue
+				}
+				diff := gamma[b]/count[b] - v.at(dist[b]/count[b])
+				sse += count[b] * diff * diff
+			}
+			if sse < bestErr {
+				best, bestErr = v, sse
+			}
+		}
+	}
+	return best
+}
+
+// luDecompose factors m in place with partial pivoting.
+func luDecompose(m [][]float64) ([]int, bool) {
+	n := len(m)
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		perm[col], perm[pivot] = perm[pivot], perm[col]
+		for r := col + 1; r < n; r++ {
+			m[r][col] /= m[col][col]
+			for c := col + 1; c < n; c++ {
+				m[r][c] -= m[r][col] * m[col][c]
+			}
+		}
+	}
+	return perm, true
+}
+
+func luSolve(lu [][]float64, perm []int, b []float64) []float64 {
+	n := len(lu)
+	x := make([]float64, n)
+	for i := 0; i < n; i++ {
+		x[i] = b[perm[i]]
+		for j := 0; j < i; j++ {
+			x[i] -= lu[i][j] * x[j]
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := i + 1; j < n; j++ {
+			x[i] -= lu[i][j] * x[j]
+		}
+		x[i] /= lu[i][i]
+	}
+	return x
+}
@@ -0,0 +1,153 @@
+package survey
+
+import (
+	"math"
+	"testing"
+)
+
+func near(t *testing.T, name string, got, want, tol float64) {
+	t.Helper()
+	if math.Abs(got-want) > tol {
+		t.Errorf("%s = %.4f, want %.4f ± %g", name, got, want, tol)
+	}
+}
+
+func gridSamples(n int, spacing float64, f func(x, y float64) float64) []GridSample {
+	var samples []GridSample
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			x, y := float64(i)*spacing, float64(j)*spacing
+			samples = append(samples, GridSample{X: x, Y: y, Value: f(x, y)})
+		}
+	}
+	return samples
+}
+
+func TestVariogramAt(t *testing.T) {
+	v := Variogram{Nugget: 2, Sill: 10, Range: 30}
+	near(t, "γ(0)", v.at(0), 0, 0)
+	near(t, "γ(0+)", v.at(1e-9), 2, 1e-6)
+	// The exponential model reaches 95% of the sill at the range.
+	near(t, "γ(range)", v.at(30), 2+8*0.95, 0.01)
+	near(t, "γ(∞)", v.at(1e6), 10, 1e-9)
+	if v.at(10) >= v.at(20) {
+		t.Errorf("γ is not increasing: γ(10)=%v γ(20)=%v", v.at(10), v.at(20))
+	}
+}
+
+func TestFitVariogram(t *testing.T) {
+	// A smooth field is spatially correlated: no nugget, and the fitted
+	// curve rises over the sampled distances.
+	smooth := gridSamples(6, 10, func(x, y float64) float64 { return -40 - 0.3*x - 0.2*y })
+	var sum, sumSq float64
+	for _, s := range smooth {
+		sum += s.Value
+		sumSq += s.Value * s.Value
+	}
+	n := float64(len(smooth))
+	variance := sumSq/n - (sum/n)*(sum/n)
+
+	v := fitVariogram(smooth, variance)
+	near(t, "sill", v.Sill, variance, 1e-9)
+	if v.Nugget != 0 {
+		t.Errorf("nugget = %v, want 0 for a smooth field", v.Nugget)
+	}
+	maxDist := math.Hypot(50, 50)
+	if v.Range <= 0 || v.Range > maxDist {
+		t.Errorf("range = %v, want in (0, %v]", v.Range, maxDist)
+	}
+
+	// Coincident points leave nothing to fit.
+	same := []GridSample{{X: 1, Y: 1, Value: -50}, {X: 1, Y: 1, Value: -60}}
+	if v := fitVariogram(same, 25); v.Sill != 25 || v.Range != 1 {
+		t.Errorf("coincident points: got %+v", v)
+	}
+}
+
+func TestKrigingEstimate(t *testing.T) {
+	field := func(x, y float64) float64 { return -45 - 0.4*x + 0.1*y }
+	samples := gridSamples(5, 10, field)
+	k, err := newKriging(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kriging is an exact interpolator: γ(0) = 0 even with a nugget.
+	for _, s := range samples {
+		near(t, "estimate at sample", k.estimate(s.X, s.Y), s.Value, 1e-6)
+	}
+	// Between samples of a linear field, the estimate stays close.
+	near(t, "estimate at (15, 25)", k.estimate(15, 25), field(15, 25), 0.5)
+
+	// Far away it falls back towards the mean.
+	mean := 0.0
+	for _, s := range samples {
+		mean += s.Value
+	}
+	mean /= float64(len(samples))
+	near(t, "estimate far away", k.estimate(1e5, 1e5), mean, 0.5)
+}
+
+func TestKrigingSymmetry(t *testing.T) {
+	samples := []GridSample{
+		{X: 0, Y: 0, Value: -40},
+		{X: 10, Y: 0, Value: -60},
+		{X: 10, Y: 10, Value: -40},
+		{X: 0, Y: 10, Value: -60},
+	}
+	k, err := newKriging(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, "center", k.estimate(5, 5), -50, 1e-6)
+}
+
+func TestKrigingEdgeCases(t *testing.T) {
+	if _, err := newKriging([]GridSample{{X: 0, Y: 0, Value: -50}, {X: 1, Y: 0, Value: -60}}); err == nil {
+		t.Error("two samples: want an error")
+	}
+
+	constant := gridSamples(3, 5, func(x, y float64) float64 { return -55 })
+	k, err := newKriging(constant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	near(t, "constant field", k.estimate(123, 4), -55, 0)
+
+	duplicate := []GridSample{
+		{X: 0, Y: 0, Value: -50},
+		{X: 0, Y: 0, Value: -60},
+		{X: 10, Y: 0, Value: -70},
+	}
+	if _, err := newKriging(duplicate); err == nil {
+		t.Error("duplicate points: want a singular system error")
+	}
+}
+
+func TestLUSolve(t *testing.T) {
+	// The zero in the top-left corner needs pivoting.
+	m := [][]float64{
+		{0, 2, 1},
+		{1, 1, 1},
+		{2, 1, 3},
+	}
+	want := []float64{1, -2, 3}
+	b := make([]float64, 3)
+	for i := range m {
+		for j := range m[i] {
+			b[i] += m[i][j] * want[j]
+		}
+	}
+	perm, ok := luDecompose(m)
+	if !ok {
+		t.Fatal("luDecompose: singular")
+	}
+	x := luSolve(m, perm, b)
+	for i := range want {
+		near(t, "x", x[i], want[i], 1e-9)
+	}
+
+	if _, ok := luDecompose([][]float64{{1, 2}, {2, 4}}); ok {
+		t.Error("singular matrix: want ok = false")
+	}
+}
@@ -0,0 +1,533 @@
+package survey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	projectFile   = "project.json"
+	defaultWindow = 3 * time.Second
+	saveDelay     = time.Second
+	maxFloorplan  = 32 << 20
+)
+
+var (
+	ErrNotFound     = errors.New("survey not found")
+	ErrBadFloorplan = errors.New("floor plan must be a PNG, JPEG or GIF image")
+
+	validID = regexp.MustCompile(`^[a-z0-9-]+$`)
+)
+
+type Project struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	CreatedMS int64   `json:"created_unix_ms"`
+	Floorplan string  `json:"floorplan"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Points    []Point `json:"points"`
+}
+
+// Summary is a project as listed, without its points.
+type Summary struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedMS int64  `json:"created_unix_ms"`
+	Floorplan string `json:"floorplan"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+}
+
+type Point struct {
+	ID             int           `json:"id"`
+	X              float64       `json:"x"`
+	Y              float64       `json:"y"`
+	TimestampUnixM int64         `json:"ts_unix_ms"`
+	Observations   []Observation `json:"observations"`
+}
+
+type Observation struct {
+	BSSID          string `json:"bssid"`
+	SSID           string `json:"ssid"`
+	FreqMHz        int    `json:"freq_mhz"`
+	SignalDBM      int    `json:"signal_dbm"`
+	TimestampUnixM int64  `json:"ts_unix_ms"`
+}
+
+type BSSSummary struct {
+	BSSID     string  `json:"bssid"`
+	SSID      string  `json:"ssid"`
+	FreqMHz   int     `json:"freq_mhz"`
+	Points    int     `json:"points"`
+	BestDBM   int     `json:"best_dbm"`
+	MeanDBM   float64 `json:"mean_dbm"`
+	signalSum int
+	samples   int
+}
+
+// Manager keeps survey projects on disk under Dir, one directory per
+// project, and attaches scan results to the positions clicked on the
+// floor plan.
+type Manager struct {
+	Dir string
+	// Window is how far before and after a click scan results are still
+	// attributed to that position.
+	Window time.Duration
+
+	mu       sync.Mutex
+	projects map[string]*Project
+	recent   []scan
+
+	// Scans only mark projects dirty; saveLoop writes them out at most
+	// once per saveDelay, so the collector never waits on the disk.
+	// saveMu orders writes and is taken after mu.
+	saveMu    sync.Mutex
+	dirty     map[string]bool
+	saveDelay time.Duration
+	wake      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+type scan struct {
+	tsMS     int64
+	networks []model.Sample
+}
+
+func Open(dir string, window time.Duration) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create survey dir: %w", err)
+	}
+	if window <= 0 {
+		window = defaultWindow
+	}
+
+	m := &Manager{
+		Dir:       dir,
+		Window:    window,
+		projects:  make(map[string]*Project),
+		dirty:     make(map[string]bool),
+		saveDelay: saveDelay,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read survey dir: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !validID.MatchString(e.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), projectFile))
+		if err != nil {
+			continue
+		}
+		var p Project
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("load survey %s: %w", e.Name(), err)
+		}
+		p.ID = e.Name()
+		m.projects[p.ID] = &p
+	}
+	go m.saveLoop()
+	return m, nil
+}
+
+// Close writes out pending changes and stops the background saver.
+func (m *Manager) Close() error {
+	close(m.stop)
+	<-m.stopped
+	return nil
+}
+
+func (m *Manager) List() []Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Summary, 0, len(m.projects))
+	for _, p := range m.projects {
+		out = append(out, Summary{
+			ID:        p.ID,
+			Name:      p.Name,
+			CreatedMS: p.CreatedMS,
+			Floorplan: p.Floorplan,
+			Width:     p.Width,
+			Height:    p.Height,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedMS > out[j].CreatedMS })
+	return out
+}
+
+func (m *Manager) Get(id string) (Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok {
+		return Project{}, ErrNotFound
+	}
+	return clone(p), nil
+}
+
+// Create stores a new project with the floor plan image read from r.
+func (m *Manager) Create(name string, r io.Reader) (Project, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFloorplan+1))
+	if err != nil {
+		return Project{}, fmt.Errorf("read floor plan: %w", err)
+	}
+	if len(data) > maxFloorplan {
+		return Project{}, fmt.Errorf("floor plan larger than %d MB", maxFloorplan>>20)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Project{}, ErrBadFloorplan
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Project{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Survey " + time.Now().Format("2006-01-02 15:04")
+	}
+	p := &Project{
+		ID:        id,
+		Name:      name,
+		CreatedMS: model.NowUnixMS(),
+		Floorplan: "floorplan." + format,
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+		Points:    []Point{},
+	}
+
+	dir := filepath.Join(m.Dir, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Project{}, fmt.Errorf("create survey: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, p.Floorplan), data, 0o644); err != nil {
+		return Project{}, fmt.Errorf("write floor plan: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.saveLocked(p); err != nil {
+		return Project{}, err
+	}
+	m.projects[id] = p
+	return clone(p), nil
+}
+
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projects[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.projects, id)
+	delete(m.dirty, id)
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	return os.RemoveAll(filepath.Join(m.Dir, id))
+}
+
+func (m *Manager) FloorplanPath(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return filepath.Join(m.Dir, id, p.Floorplan), nil
+}
+
+// AddPoint records the surveyor's position in floor plan pixels and
+// attaches the scan results from the preceding window. Scans arriving
+// within the window afterwards are attached by ObserveNetworks.
+func (m *Manager) AddPoint(id string, x float64, y float64) (Point, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok {
+		return Point{}, ErrNotFound
+	}
+	if x < 0 || y < 0 || x > float64(p.Width) || y > float64(p.Height) {
+		return Point{}, fmt.Errorf("position %.0f,%.0f outside the %dx%d floor plan", x, y, p.Width, p.Height)
+	}
+
+	point := Point{
+		ID:             nextPointID(p),
+		X:              x,
+		Y:              y,
+		TimestampUnixM: model.NowUnixMS(),
+		Observations:   []Observation{},
+	}
+	for _, s := range m.recent {
+		if point.TimestampUnixM-s.tsMS <= m.Window.Milliseconds() {
+			point.Observations = append(point.Observations, observations(s)...)
+		}
+	}
+	p.Points = append(p.Points, point)
+	if err := m.saveLocked(p); err != nil {
+		return Point{}, err
+	}
+	return point, nil
+}
+
+func (m *Manager) DeletePoint(id string, pointID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, pt := range p.Points {
+		if pt.ID == pointID {
+			p.Points = append(p.Points[:i], p.Points[i+1:]...)
+			return m.saveLocked(p)
+		}
+	}
+	return ErrNotFound
+}
+
+// ObserveNetworks is fed every scan result. It keeps recent scans for
+// upcoming clicks and attaches the scan to points clicked within the
+// window before it.
+func (m *Manager) ObserveNetworks(networks []model.Sample) {
+	if len(networks) == 0 {
+		return
+	}
+	s := scan{tsMS: networks[0].TimestampUnixM, networks: networks}
+	if s.tsMS == 0 {
+		s.tsMS = model.NowUnixMS()
+	}
+	window := m.Window.Milliseconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keep := m.recent[:0]
+	for _, r := range m.recent {
+		if s.tsMS-r.tsMS <= window {
+			keep = append(keep, r)
+		}
+	}
+	m.recent = append(keep, s)
+
+	for _, p := range m.projects {
+		changed := false
+		for i := range p.Points {
+			pt := &p.Points[i]
+			if s.tsMS <= pt.TimestampUnixM || s.tsMS-pt.TimestampUnixM > window {
+				continue
+			}
+			pt.Observations = append(pt.Observations, observations(s)...)
+			changed = true
+		}
+		if changed {
+			m.dirty[p.ID] = true
+		}
+	}
+	if len(m.dirty) > 0 {
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) BSSes(id string) ([]BSSSummary, error) {
+	p, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	byBSSID := make(map[string]*BSSSummary)
+	for _, pt := range p.Points {
+		seen := make(map[string]bool)
+		for _, o := range pt.Observations {
+			b := byBSSID[o.BSSID]
+			if b == nil {
+				b = &BSSSummary{BSSID: o.BSSID, BestDBM: o.SignalDBM}
+				byBSSID[o.BSSID] = b
+			}
+			if o.SSID != "" {
+				b.SSID = o.SSID
+			}
+			if o.FreqMHz != 0 {
+				b.FreqMHz = o.FreqMHz
+			}
+			if o.SignalDBM > b.BestDBM {
+				b.BestDBM = o.SignalDBM
+			}
+			b.signalSum += o.SignalDBM
+			b.samples++
+			if !seen[o.BSSID] {
+				seen[o.BSSID] = true
+				b.Points++
+			}
+		}
+	}
+
+	out := make([]BSSSummary, 0, len(byBSSID))
+	for _, b := range byBSSID {
+		b.MeanDBM = float64(b.signalSum) / float64(b.samples)
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Points == out[j].Points {
+			return out[i].BestDBM > out[j].BestDBM
+		}
+		return out[i].Points > out[j].Points
+	})
+	return out, nil
+}
+
+func (m *Manager) saveLocked(p *Project) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode survey: %w", err)
+	}
+	delete(m.dirty, p.ID)
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	return m.write(p.ID, data)
+}
+
+func (m *Manager) saveLoop() {
+	defer close(m.stopped)
+	for {
+		select {
+		case <-m.wake:
+		case <-m.stop:
+			m.flush()
+			return
+		}
+		select {
+		case <-time.After(m.saveDelay):
+		case <-m.stop:
+			m.flush()
+			return
+		}
+		m.flush()
+	}
+}
+
+// flush writes the dirty projects. They are encoded under mu but written
+// after it is released.
+func (m *Manager) flush() {
+	type pending struct {
+		id   string
+		data []byte
+	}
+	var out []pending
+	m.mu.Lock()
+	for id := range m.dirty {
+		if p, ok := m.projects[id]; ok {
+			data, err := json.MarshalIndent(p, "", "  ")
+			if err != nil {
+				log.Printf("survey %s: encode: %v", id, err)
+				continue
+			}
+			out = append(out, pending{id, data})
+		}
+		delete(m.dirty, id)
+	}
+	m.saveMu.Lock()
+	m.mu.Unlock()
+	defer m.saveMu.Unlock()
+
+	for _, p := range out {
+		if err := m.write(p.id, p.data); err != nil {
+			log.Printf("survey %s: %v", p.id, err)
+		}
+	}
+}
+
+func (m *Manager) write(id string, data []byte) error {
+	path := filepath.Join(m.Dir, id, projectFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write survey: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write survey: %w", err)
+	}
+	return nil
+}
+
+func observations(s scan) []Observation {
+	out := make([]Observation, 0, len(s.networks))
+	for _, n := range s.networks {
+		if n.Lost || n.BSSID == "" {
+			continue
+		}
+		out = append(out, Observation{
+			BSSID:          n.BSSID,
+			SSID:           n.SSID,
+			FreqMHz:        n.FreqMHz,
+			SignalDBM:      n.SignalDBM,
+			TimestampUnixM: s.tsMS,
+		})
+	}
+	return out
+}
+
+func nextPointID(p *Project) int {
+	id := 1
+	for _, pt := range p.Points {
+		if pt.ID >= id {
+			id = pt.ID + 1
+		}
+	}
+	return id
+}
+
+func clone(p *Project) Project {
+	out := *p
+	out.Points = make([]Point, len(p.Points))
+	for i, pt := range p.Points {
+		pt.Observations = append([]Observation(nil), pt.Observations...)
+		out.Points[i] = pt
+	}
+	return out
+}
+
+func newID() (string, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate survey id: %w", err)
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b[:]), nil
+}
+
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
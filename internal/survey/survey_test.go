@@ -0,0 +1,245 @@
+package survey
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+func floorplanPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func openManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	m, err := Open(dir, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.saveDelay = 10 * time.Millisecond
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func scanAt(tsMS int64, networks ...model.Sample) []model.Sample {
+	for i := range networks {
+		networks[i].TimestampUnixM = tsMS
+	}
+	return networks
+}
+
+func ap(bssid string, dbm int) model.Sample {
+	return model.Sample{IfName: "wlan0", BSSID: bssid, SSID: "net-" + bssid, FreqMHz: 2412, SignalDBM: dbm}
+}
+
+// saved reads the project as written to disk.
+func saved(t *testing.T, dir, id string) Project {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, id, projectFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Project
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	m := openManager(t, dir)
+
+	p, err := m.Create("  Office  ", bytes.NewReader(floorplanPNG(t, 200, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidID(p.ID) || p.Name != "Office" || p.Width != 200 || p.Height != 100 || p.Floorplan != "floorplan.png" {
+		t.Errorf("project = %+v", p)
+	}
+	if path, _ := m.FloorplanPath(p.ID); path != filepath.Join(dir, p.ID, "floorplan.png") {
+		t.Errorf("floor plan path = %s", path)
+	}
+	if got := saved(t, dir, p.ID); got.Name != "Office" {
+		t.Errorf("saved = %+v", got)
+	}
+
+	if _, err := m.Create("text", strings.NewReader("not an image")); !errors.Is(err, ErrBadFloorplan) {
+		t.Errorf("bad floor plan: %v", err)
+	}
+	unnamed, _ := m.Create("", bytes.NewReader(floorplanPNG(t, 10, 10)))
+	if !strings.HasPrefix(unnamed.Name, "Survey ") {
+		t.Errorf("default name = %q", unnamed.Name)
+	}
+	if list := m.List(); len(list) != 2 || list[0].ID != unnamed.ID {
+		t.Errorf("list = %+v", list)
+	}
+
+	if err := m.Delete(p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, p.ID)); !os.IsNotExist(err) {
+		t.Errorf("deleted project dir: %v", err)
+	}
+	if _, err := m.Get(p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: %v", err)
+	}
+	if err := m.Delete(p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+}
+
+func TestPointsAndObservations(t *testing.T) {
+	dir := t.TempDir()
+	m := openManager(t, dir)
+	p, err := m.Create("home", bytes.NewReader(floorplanPNG(t, 100, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := model.NowUnixMS()
+	// Too old for the next click, then one within the window before it.
+	m.ObserveNetworks(scanAt(now-5000, ap("old", -40)))
+	m.ObserveNetworks(scanAt(now-500, ap("aa", -50), ap("bb", -70),
+		model.Sample{BSSID: "lost", SignalDBM: -100, Lost: true}, model.Sample{SSID: "no bssid"}))
+
+	pt, err := m.AddPoint(p.ID, 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.ID != 1 || len(pt.Observations) != 2 || pt.Observations[0].TimestampUnixM != now-500 {
+		t.Fatalf("point = %+v, want aa and bb from the recent scan", pt)
+	}
+
+	// A scan after the click and within the window is attached; so is
+	// only that one.
+	m.ObserveNetworks(scanAt(pt.TimestampUnixM+300, ap("aa", -48)))
+	m.ObserveNetworks(scanAt(pt.TimestampUnixM+5000, ap("aa", -20)))
+	got, _ := m.Get(p.ID)
+	if obs := got.Points[0].Observations; len(obs) != 3 || obs[2].SignalDBM != -48 {
+		t.Errorf("observations = %+v", obs)
+	}
+
+	if _, err := m.AddPoint(p.ID, 101, 5); err == nil {
+		t.Error("point outside the floor plan accepted")
+	}
+	if _, err := m.AddPoint("nope", 1, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown survey: %v", err)
+	}
+
+	pt2, _ := m.AddPoint(p.ID, 50, 50)
+	pt3, _ := m.AddPoint(p.ID, 90, 90)
+	if err := m.DeletePoint(p.ID, pt2.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeletePoint(p.ID, pt2.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: %v", err)
+	}
+	if pt4, _ := m.AddPoint(p.ID, 1, 1); pt4.ID != pt3.ID+1 {
+		t.Errorf("point id after a delete = %d, want %d", pt4.ID, pt3.ID+1)
+	}
+
+	// Get returns a copy.
+	got, _ = m.Get(p.ID)
+	got.Points[0].Observations[0].SignalDBM = 0
+	if again, _ := m.Get(p.ID); again.Points[0].Observations[0].SignalDBM == 0 {
+		t.Error("Get shares observations with the manager")
+	}
+}
+
+func TestObserveSavesInBackground(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Open(dir, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.saveDelay = time.Hour
+	p, _ := m.Create("home", bytes.NewReader(floorplanPNG(t, 100, 100)))
+	pt, _ := m.AddPoint(p.ID, 10, 10)
+
+	m.ObserveNetworks(scanAt(pt.TimestampUnixM+100, ap("aa", -50)))
+	// Nothing is written from the collector's call.
+	if obs := saved(t, dir, p.ID).Points[0].Observations; len(obs) != 0 {
+		t.Errorf("observations written synchronously: %+v", obs)
+	}
+	// Close writes what is pending.
+	m.Close()
+	if obs := saved(t, dir, p.ID).Points[0].Observations; len(obs) != 1 {
+		t.Errorf("after Close: %+v", obs)
+	}
+
+	reopened := openManager(t, dir)
+	got, err := reopened.Get(p.ID)
+	if err != nil || len(got.Points) != 1 || len(got.Points[0].Observations) != 1 {
+		t.Errorf("reopened = %+v, %v", got, err)
+	}
+
+	// With a short delay the saver writes on its own.
+	pt2, _ := reopened.AddPoint(p.ID, 20, 20)
+	reopened.ObserveNetworks(scanAt(pt2.TimestampUnixM+100, ap("bb", -60)))
+	deadline := time.Now().Add(2 * time.Second)
+	for len(saved(t, dir, p.ID).Points[1].Observations) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scan never saved")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBSSes(t *testing.T) {
+	m := openManager(t, t.TempDir())
+	p, _ := m.Create("home", bytes.NewReader(floorplanPNG(t, 100, 100)))
+	m.mu.Lock()
+	m.projects[p.ID].Points = []Point{
+		{ID: 1, Observations: []Observation{
+			{BSSID: "aa", SSID: "home", FreqMHz: 2412, SignalDBM: -50},
+			{BSSID: "aa", SignalDBM: -54},
+			{BSSID: "bb", SignalDBM: -80},
+		}},
+		{ID: 2, Observations: []Observation{{BSSID: "aa", SignalDBM: -40}}},
+		{ID: 3, Observations: []Observation{{BSSID: "cc", SignalDBM: -30}}},
+	}
+	m.mu.Unlock()
+
+	bsses, err := m.BSSes(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range bsses {
+		got = append(got, b.BSSID)
+	}
+	// Most points first, then the best signal.
+	if strings.Join(got, ",") != "aa,cc,bb" {
+		t.Fatalf("order = %v", got)
+	}
+	// Two observations at one point count as one point.
+	a := bsses[0]
+	if a.Points != 2 || a.BestDBM != -40 || a.MeanDBM != (-50-54-40)/3.0 || a.SSID != "home" || a.FreqMHz != 2412 {
+		t.Errorf("aa = %+v", a)
+	}
+	if _, err := m.BSSes("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown survey: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package tsdb pushes samples to time-series databases: InfluxDB line
+// protocol over HTTP or UDP, and Graphite plaintext over TCP or UDP.
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	defaultFlushInterval = 10 * time.Second
+	defaultBatchSize     = 500
+	defaultSpoolMax      = 64 << 20
+	replayChunk          = 1 << 20
+)
+
+type Options struct {
+	FlushInterval time.Duration
+	BatchSize     int
+	// SpoolDir holds batches that could not be delivered, one file per
+	// sink, replayed before the next batch. Empty disables spooling.
+	SpoolDir      string
+	SpoolMaxBytes int64
+}
+
+// Exporter batches points for one sink and flushes them from Run.
+type Exporter struct {
+	spec  string
+	sink  sink
+	opts  Options
+	spool string
+
+	mu    sync.Mutex
+	buf   []Point
+	flush chan struct{}
+	down  bool
+}
+
+func New(spec string, opts Options) (*Exporter, error) {
+	s, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.SpoolMaxBytes <= 0 {
+		opts.SpoolMaxBytes = defaultSpoolMax
+	}
+	e := &Exporter{spec: redact(spec), sink: s, opts: opts, flush: make(chan struct{}, 1)}
+	if opts.SpoolDir != "" && s.reliable() {
+		if err := os.MkdirAll(opts.SpoolDir, 0o755); err != nil {
+			return nil, err
+		}
+		e.spool = filepath.Join(opts.SpoolDir, spoolName(spec))
+	}
+	return e, nil
+}
+
+func (e *Exporter) Observe(sample model.Sample) {
+	e.add(samplePoint(sample))
+}
+
+func (e *Exporter) ObserveNetworks(networks []model.Sample) {
+	points := make([]Point, 0, len(networks))
+	for _, n := range networks {
+		if !n.Lost && n.BSSID != "" {
+			points = append(points, networkPoint(n))
+		}
+	}
+	e.add(points...)
+}
+
+func (e *Exporter) add(points ...Point) {
+	e.mu.Lock()
+	e.buf = append(e.buf, points...)
+	full := len(e.buf) >= e.opts.BatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run flushes on every interval or full batch until ctx is done, then
+// flushes once more.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			e.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-e.flush:
+		}
+		e.Flush(ctx)
+	}
+}
+
+// Flush sends spooled data and the buffered points. Points the server
+// rejects are dropped; only those that failed to get through are spooled.
+func (e *Exporter) Flush(ctx context.Context) {
+	e.mu.Lock()
+	points := e.buf
+	e.buf = nil
+	e.mu.Unlock()
+
+	var payload []byte
+	if len(points) > 0 {
+		payload = e.sink.encode(points)
+	}
+
+	err := e.replay(ctx)
+	if err == nil && len(payload) > 0 {
+		var retry bool
+		retry, err = e.sink.send(ctx, payload)
+		if err != nil && !retry {
+			e.report(nil)
+			log.Printf("tsdb %s: dropping %d points: %v", e.spec, len(points), err)
+			return
+		}
+	}
+	e.report(err)
+	if err != nil && len(payload) > 0 && e.spool != "" {
+		if serr := e.appendSpool(payload); serr != nil {
+			log.Printf("tsdb %s: spool: %v", e.spec, serr)
+		}
+	}
+}
+
+func (e *Exporter) report(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil && !e.down:
+		e.down = true
+		log.Printf("tsdb %s: %v", e.spec, err)
+	case err == nil && e.down:
+		e.down = false
+		log.Printf("tsdb %s: delivering again", e.spec)
+	}
+}
+
+func (e *Exporter) replay(ctx context.Context) error {
+	if e.spool == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.spool)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(data) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	chunks := splitLines(data, replayChunk)
+	for i, chunk := range chunks {
+		retry, err := e.sink.send(ctx, chunk)
+		if err != nil && !retry {
+			log.Printf("tsdb %s: dropping %d spooled bytes: %v", e.spec, len(chunk), err)
+			continue
+		}
+		if err != nil {
+			rest := bytes.Join(chunks[i:], nil)
+			if werr := os.WriteFile(e.spool, rest, 0o644); werr != nil {
+				log.Printf("tsdb %s: spool: %v", e.spec, werr)
+			}
+			return err
+		}
+	}
+	return os.Remove(e.spool)
+}
+
+func (e *Exporter) appendSpool(payload []byte) error {
+	if info, err := os.Stat(e.spool); err == nil && info.Size()+int64(len(payload)) > e.opts.SpoolMaxBytes {
+		return fmt.Errorf("spool full (%d bytes), dropping %d bytes", info.Size(), len(payload))
+	}
+	f, err := os.OpenFile(e.spool, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(payload); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func spoolName(spec string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, redact(spec))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name + ".spool"
+}
+
+// redact drops credentials from a spec for logs and file names.
+func redact(spec string) string {
+	if i := strings.Index(spec, "://"); i >= 0 {
+		rest := spec[i+3:]
+		if at := strings.Index(rest, "@"); at >= 0 && !strings.Contains(rest[:at], "/") {
+			return spec[:i+3] + rest[at+1:]
+		}
+	}
+	return spec
+}
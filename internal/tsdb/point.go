@@ -0,0 +1,166 @@
+package tsdb
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]any
+	TimeMS      int64
+}
+
+func samplePoint(s model.Sample) Point {
+	ts := s.TimestampUnixM
+	if ts == 0 {
+		ts = model.NowUnixMS()
+	}
+	return Point{
+		Measurement: "wifi_radar",
+		Tags: map[string]string{
+			"ifname": s.IfName,
+			"ssid":   s.SSID,
+			"bssid":  s.BSSID,
+		},
+		Fields: map[string]any{
+			"signal_dbm": s.SignalDBM,
+			"rx_mbps":    s.RxBitrateMbps,
+			"tx_mbps":    s.TxBitrateMbps,
+			"freq_mhz":   s.FreqMHz,
+			"lost":       s.Lost,
+		},
+		TimeMS: ts,
+	}
+}
+
+func networkPoint(n model.Sample) Point {
+	ts := n.TimestampUnixM
+	if ts == 0 {
+		ts = model.NowUnixMS()
+	}
+	return Point{
+		Measurement: "wifi_radar_bss",
+		Tags: map[string]string{
+			"ifname":   n.IfName,
+			"ssid":     n.SSID,
+			"bssid":    n.BSSID,
+			"channel":  strconv.Itoa(model.Channel(n.FreqMHz)),
+			"security": n.Security,
+		},
+		Fields: map[string]any{
+			"signal_dbm": n.SignalDBM,
+			"freq_mhz":   n.FreqMHz,
+		},
+		TimeMS: ts,
+	}
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// Line formats p in Influx line protocol with the timestamp in precision
+// ("ns", "us", "ms" or "s").
+func (p Point) Line(precision time.Duration) string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, k := range sortedKeys(p.Tags) {
+		// Influx rejects empty tag values.
+		if p.Tags[k] == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(tagEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(tagEscaper.Replace(p.Tags[k]))
+	}
+	for i, k := range sortedKeys(p.Fields) {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(tagEscaper.Replace(k))
+		b.WriteByte('=')
+		switch v := p.Fields[k].(type) {
+		case int:
+			b.WriteString(strconv.Itoa(v))
+			b.WriteByte('i')
+		case float64:
+			b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			b.WriteString(strconv.FormatBool(v))
+		case string:
+			b.WriteByte('"')
+			b.WriteString(stringEscaper.Replace(v))
+			b.WriteByte('"')
+		}
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.TimeMS*int64(time.Millisecond)/int64(precision), 10))
+	return b.String()
+}
+
+// Graphite formats p as Graphite plaintext lines, one per field:
+// <prefix>.<measurement>.<ifname>[.<bssid>].<field> <value> <unix seconds>.
+func (p Point) Graphite(prefix string) []string {
+	path := []string{p.Measurement, graphiteSafe(p.Tags["ifname"])}
+	if p.Measurement == "wifi_radar_bss" {
+		path = append(path, graphiteSafe(p.Tags["bssid"]))
+	}
+	if prefix != "" {
+		path = append([]string{prefix}, path...)
+	}
+	base := strings.Join(path, ".")
+	ts := strconv.FormatInt(p.TimeMS/1000, 10)
+
+	var out []string
+	for _, k := range sortedKeys(p.Fields) {
+		var value string
+		switch v := p.Fields[k].(type) {
+		case int:
+			value = strconv.Itoa(v)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = "0"
+			if v {
+				value = "1"
+			}
+		default:
+			continue
+		}
+		out = append(out, base+"."+k+" "+value+" "+ts)
+	}
+	return out
+}
+
+func graphiteSafe(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', ':', '/':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,269 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"wifi-radar/internal/model"
+)
+
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	inverse = "\x1b[7m"
+	red     = "\x1b[31m"
+	green   = "\x1b[32m"
+	yellow  = "\x1b[33m"
+	cyan    = "\x1b[36m"
+
+	minDBM = -95
+	maxDBM = -30
+
+	chartHeight = 4
+)
+
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// draw renders the whole screen: header, one gauge per interface, the
+// network table, channel occupancy and the footer.
+func (a *app) draw(w io.Writer) {
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	add("%swifi-radar%s  %s  sort: %s  %d networks", bold, reset, time.Now().Format("15:04:05"), a.sortBy, len(a.rows))
+	for _, s := range a.status.Interfaces {
+		lines = append(lines, a.gauge(s))
+	}
+	if len(a.status.Interfaces) == 0 {
+		add("%swaiting for samples…%s", dim, reset)
+	}
+	add("")
+
+	add("%s%s%s", bold, a.row(" ", "SSID", "BSSID", "CH", "DBM", "HISTORY", "SECURITY"), reset)
+	n := a.tableRows()
+	sel := a.selectedIndex()
+	if sel >= 0 {
+		if sel < a.offset {
+			a.offset = sel
+		}
+		if sel >= a.offset+n {
+			a.offset = sel - n + 1
+		}
+	}
+	a.offset = max(0, min(a.offset, len(a.rows)-n))
+	for i := a.offset; i < a.offset+n; i++ {
+		if i >= len(a.rows) {
+			add("")
+			continue
+		}
+		net := a.rows[i]
+		marker := " "
+		if a.isTarget(net) {
+			marker = "*"
+		}
+		line := a.row(marker, displaySSID(net.SSID), net.BSSID, fmt.Sprint(model.Channel(net.FreqMHz)),
+			fmt.Sprint(net.SignalDBM), sparkline(a.history[networkKey(net)]), net.Security)
+		if i == sel {
+			line = inverse + line + reset
+		} else {
+			line = signalColor(net.SignalDBM) + line + reset
+		}
+		lines = append(lines, line)
+	}
+
+	add("")
+	lines = append(lines, a.channels()...)
+
+	add("%s%s%s", dim, truncate(a.message, a.width), reset)
+	keys := "↑/↓ select  enter target  s sort  r rescan  q quit"
+	if a.opts.SetTarget == nil {
+		keys = "↑/↓ select  s sort  r rescan  q quit"
+	}
+	add("%s%s%s", dim, truncate(keys, a.width), reset)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i >= a.height {
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+		if i < len(lines)-1 && i < a.height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(w, b.String())
+}
+
+// tableRows is how many network rows fit between the fixed parts.
+func (a *app) tableRows() int {
+	fixed := 1 + max(1, len(a.status.Interfaces)) + 1 + 1 + 1 + (chartHeight + 2) + 2
+	return max(3, a.height-fixed)
+}
+
+func (a *app) gauge(s model.Sample) string {
+	label := fmt.Sprintf("%-8s %s", s.IfName, describe(s.SSID, s.BSSID))
+	value := fmt.Sprintf("%4d dBm", s.SignalDBM)
+	if s.Lost {
+		value = "    lost"
+	}
+	if s.Distance != nil {
+		value += fmt.Sprintf("  ~%.1f m", s.Distance.Meters)
+	}
+	barWidth := max(10, a.width-utf8.RuneCountInString(label)-utf8.RuneCountInString(value)-6)
+	filled := 0
+	if !s.Lost {
+		filled = int(float64(barWidth) * level(s.SignalDBM))
+	}
+	bar := signalColor(s.SignalDBM) + strings.Repeat("█", filled) + reset + dim + strings.Repeat("░", barWidth-filled) + reset
+	return truncate(label, a.width) + " [" + bar + "] " + value
+}
+
+// row lays out one table line in fixed columns; the history column keeps
+// its width and the security column takes what is left.
+func (a *app) row(marker, ssid, bssid, ch, dbm, hist, sec string) string {
+	line := fmt.Sprintf("%s %s %s %3s %4s %s %s",
+		marker, pad(ssid, 22), pad(bssid, 17), ch, dbm, pad(hist, sparkLen), sec)
+	return pad(line, a.width)
+}
+
+func (a *app) isTarget(n model.Sample) bool {
+	for _, s := range a.status.Interfaces {
+		if s.BSSID != "" && strings.EqualFold(s.BSSID, n.BSSID) {
+			return true
+		}
+	}
+	return false
+}
+
+// channels draws a bar chart of BSS counts per channel: all 2.4 GHz
+// channels, then the 5 and 6 GHz channels in use.
+func (a *app) channels() []string {
+	counts := make(map[int]int)
+	for _, n := range a.rows {
+		if ch := model.Channel(n.FreqMHz); ch > 0 {
+			counts[ch+bandOffset(n.FreqMHz)]++
+		}
+	}
+	cols := []int{}
+	for ch := 1; ch <= 13; ch++ {
+		cols = append(cols, ch)
+	}
+	var high []int
+	for key := range counts {
+		if key > 1000 {
+			high = append(high, key)
+		}
+	}
+	sort.Ints(high)
+	cols = append(cols, high...)
+
+	fit := max(1, a.width/4)
+	if len(cols) > fit {
+		cols = cols[:fit]
+	}
+	peak := 1
+	for _, c := range counts {
+		peak = max(peak, c)
+	}
+
+	lines := []string{bold + "channel occupancy" + reset}
+	for row := chartHeight; row >= 1; row-- {
+		var b strings.Builder
+		for _, col := range cols {
+			// Each row covers 1/chartHeight of the peak, in eighths.
+			eighths := counts[col]*chartHeight*8/peak - (row-1)*8
+			switch {
+			case eighths >= 8:
+				b.WriteString(" ██ ")
+			case eighths > 0:
+				c := string(sparkChars[eighths-1])
+				b.WriteString(" " + c + c + " ")
+			default:
+				b.WriteString("    ")
+			}
+		}
+		lines = append(lines, cyan+b.String()+reset)
+	}
+	var labels strings.Builder
+	for _, col := range cols {
+		fmt.Fprintf(&labels, "%3d ", col%1000)
+	}
+	lines = append(lines, dim+labels.String()+reset)
+	return lines
+}
+
+// bandOffset keeps 5 and 6 GHz channels apart from each other and from
+// 2.4 GHz ones with the same number.
+func bandOffset(freqMHz int) int {
+	switch {
+	case freqMHz >= 5925:
+		return 2000
+	case freqMHz >= 5000:
+		return 1000
+	default:
+		return 0
+	}
+}
+
+func sparkline(signals []int) string {
+	var b strings.Builder
+	for _, s := range signals {
+		i := int(level(s) * float64(len(sparkChars)-1))
+		b.WriteRune(sparkChars[i])
+	}
+	return b.String()
+}
+
+// level maps a signal to 0..1 between minDBM and maxDBM.
+func level(dbm int) float64 {
+	v := float64(dbm-minDBM) / float64(maxDBM-minDBM)
+	return max(0, min(1, v))
+}
+
+func signalColor(dbm int) string {
+	switch {
+	case dbm >= -60:
+		return green
+	case dbm >= -75:
+		return yellow
+	default:
+		return red
+	}
+}
+
+func displaySSID(ssid string) string {
+	if ssid == "" {
+		return "<hidden>"
+	}
+	return ssid
+}
+
+// pad truncates or space-pads s to exactly width runes.
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
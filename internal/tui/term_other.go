@@ -0,0 +1,29 @@
+//go:build !linux
+
+package tui
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// Raw mode is only implemented for Linux; elsewhere keys take effect
+// after Enter.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+// size falls back to $COLUMNS and $LINES.
+func size(f *os.File) (int, int, bool) {
+	cols, err1 := strconv.Atoi(os.Getenv("COLUMNS"))
+	rows, err2 := strconv.Atoi(os.Getenv("LINES"))
+	if err1 != nil || err2 != nil || cols <= 0 || rows <= 0 {
+		return 0, 0, false
+	}
+	return cols, rows, true
+}
+
+func resized() (<-chan os.Signal, func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,320 @@
+// Package tui is a full-screen terminal dashboard drawn with plain ANSI
+// escape sequences.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+const (
+	sparkLen = 24
+	// escTimeout is how long to wait for the rest of an escape sequence
+	// before taking ESC as a key of its own.
+	escTimeout = 50 * time.Millisecond
+)
+
+type Options struct {
+	// In and Out default to os.Stdin and os.Stdout.
+	In  *os.File
+	Out io.Writer
+	// SetTarget switches the tracked network; nil disables Enter.
+	SetTarget func(ssid, bssid string)
+	// Rescan asks for a sample right away; nil disables r.
+	Rescan func()
+}
+
+type sortKey int
+
+const (
+	bySignal sortKey = iota
+	bySSID
+	byChannel
+)
+
+func (k sortKey) String() string {
+	return [...]string{"signal", "ssid", "channel"}[k]
+}
+
+type app struct {
+	opts     Options
+	status   model.Status
+	history  map[string][]int
+	rows     []model.Sample
+	sortBy   sortKey
+	selected string
+	offset   int
+	message  string
+	width    int
+	height   int
+}
+
+// Run draws st until ctx is done or the user quits.
+func Run(ctx context.Context, st *store.Store, opts Options) error {
+	if opts.In == nil {
+		opts.In = os.Stdin
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	a := &app{opts: opts, history: make(map[string][]int)}
+
+	restore, err := makeRaw(opts.In)
+	if err != nil {
+		a.message = "line mode: press a key then Enter"
+		restore = func() {}
+	}
+	defer restore()
+	out := bufio.NewWriter(opts.Out)
+	out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		out.WriteString("\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	keys := make(chan string)
+	go readKeys(opts.In, keys)
+	resize, stopResize := resized()
+	defer stopResize()
+
+	reader := st.Follow(st.LastEventID())
+	defer reader.Close()
+	if ev, ok := st.LatestEvent(model.EventStatus); ok {
+		a.apply(ev)
+	}
+
+	for {
+		a.width, a.height = 80, 24
+		if w, h, ok := size(opts.In); ok {
+			a.width, a.height = w, h
+		}
+		a.draw(out)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		events, _, wait := reader.Next()
+		for _, ev := range events {
+			a.apply(ev)
+		}
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wait:
+		case <-resize:
+		case key, ok := <-keys:
+			if !ok || !a.key(key) {
+				return nil
+			}
+		}
+	}
+}
+
+func (a *app) apply(ev model.Event) {
+	switch data := ev.Data.(type) {
+	case model.Status:
+		a.status = data
+		seen := make(map[string]bool, len(data.Networks))
+		for _, n := range data.Networks {
+			key := networkKey(n)
+			seen[key] = true
+			h := append(a.history[key], n.SignalDBM)
+			if len(h) > sparkLen {
+				h = h[len(h)-sparkLen:]
+			}
+			a.history[key] = h
+		}
+		for key := range a.history {
+			if !seen[key] {
+				delete(a.history, key)
+			}
+		}
+		a.sortRows()
+	case model.CollectorStatus:
+		if data.OK {
+			a.message = data.IfName + ": ok"
+		} else {
+			a.message = data.IfName + ": " + data.Error
+		}
+	case model.Target:
+		a.message = "tracking " + describe(data.SSID, data.BSSID)
+	}
+}
+
+func (a *app) sortRows() {
+	a.rows = append(a.rows[:0], a.status.Networks...)
+	sort.SliceStable(a.rows, func(i, j int) bool {
+		x, y := a.rows[i], a.rows[j]
+		switch a.sortBy {
+		case bySSID:
+			if !strings.EqualFold(x.SSID, y.SSID) {
+				return strings.ToLower(x.SSID) < strings.ToLower(y.SSID)
+			}
+		case byChannel:
+			if x.FreqMHz != y.FreqMHz {
+				return x.FreqMHz < y.FreqMHz
+			}
+		}
+		return x.SignalDBM > y.SignalDBM
+	})
+	if a.selectedIndex() < 0 && len(a.rows) > 0 {
+		a.selected = networkKey(a.rows[0])
+	}
+}
+
+func (a *app) selectedIndex() int {
+	for i, n := range a.rows {
+		if networkKey(n) == a.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+// key handles one key press and reports whether to keep running.
+func (a *app) key(k string) bool {
+	move := func(delta int) {
+		if len(a.rows) == 0 {
+			return
+		}
+		i := a.selectedIndex() + delta
+		i = max(0, min(i, len(a.rows)-1))
+		a.selected = networkKey(a.rows[i])
+	}
+	switch k {
+	case "q", "Q", "\x03", "\x1b":
+		return false
+	case "j", "\x1b[B":
+		move(1)
+	case "k", "\x1b[A":
+		move(-1)
+	case "\x1b[6~", " ":
+		move(a.tableRows())
+	case "\x1b[5~":
+		move(-a.tableRows())
+	case "g", "\x1b[H":
+		move(-len(a.rows))
+	case "G", "\x1b[F":
+		move(len(a.rows))
+	case "s":
+		a.sortBy = (a.sortBy + 1) % 3
+		a.sortRows()
+	case "r":
+		if a.opts.Rescan != nil {
+			a.opts.Rescan()
+			a.message = "rescanning"
+		}
+	case "\r", "t":
+		i := a.selectedIndex()
+		if a.opts.SetTarget == nil || i < 0 {
+			a.message = "changing the target needs scan mode"
+			break
+		}
+		n := a.rows[i]
+		a.opts.SetTarget(n.SSID, n.BSSID)
+	}
+	return true
+}
+
+// readKeys sends key presses, keeping escape sequences such as arrow
+// keys together even when they arrive split across reads.
+func readKeys(in io.Reader, keys chan<- string) {
+	defer close(keys)
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				chunks <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	for {
+		var timeout <-chan time.Time
+		if len(pending) > 0 {
+			timeout = time.After(escTimeout)
+		}
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if len(pending) > 0 {
+					keys <- string(pending)
+				}
+				return
+			}
+			var split []string
+			split, pending = splitKeys(append(pending, chunk...))
+			for _, k := range split {
+				keys <- k
+			}
+		case <-timeout:
+			// Nothing followed, so it was not the start of a sequence.
+			keys <- string(pending)
+			pending = nil
+		}
+	}
+}
+
+// splitKeys splits b into keys. rest is an escape sequence cut off at
+// the end of b, to be completed by the next read.
+func splitKeys(b []byte) (keys []string, rest []byte) {
+	for len(b) > 0 {
+		if b[0] != 0x1b {
+			keys = append(keys, string(b[:1]))
+			b = b[1:]
+			continue
+		}
+		if len(b) == 1 {
+			return keys, b
+		}
+		if b[1] != '[' {
+			keys = append(keys, string(b[:1]))
+			b = b[1:]
+			continue
+		}
+		end := 2
+		for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+			end++
+		}
+		if end == len(b) {
+			return keys, b
+		}
+		keys = append(keys, string(b[:end+1]))
+		b = b[end+1:]
+	}
+	return keys, nil
+}
+
+func networkKey(n model.Sample) string {
+	return n.IfName + "/" + n.BSSID
+}
+
+func describe(ssid, bssid string) string {
+	switch {
+	case ssid != "" && bssid != "":
+		return ssid + " (" + bssid + ")"
+	case ssid != "":
+		return ssid
+	default:
+		return bssid
+	}
+}
@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+func TestSplitKeys(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		keys string // keys joined by "|"
+		rest string
+	}{
+		{"", "", ""},
+		{"jk", "j|k", ""},
+		{"\x1b[A", "\x1b[A", ""},
+		{"\x1b[Aj\x1b[B", "\x1b[A|j|\x1b[B", ""},
+		{"\x1b[5~\x1b[6~", "\x1b[5~|\x1b[6~", ""},
+		// Cut off at the end of the read: kept for the next one.
+		{"\x1b", "", "\x1b"},
+		{"j\x1b", "j", "\x1b"},
+		{"\x1b[", "", "\x1b["},
+		{"k\x1b[5", "k", "\x1b[5"},
+		// ESC followed by anything but [ is a key of its own.
+		{"\x1bq", "\x1b|q", ""},
+		{"\x1b\x1b[A", "\x1b|\x1b[A", ""},
+	} {
+		keys, rest := splitKeys([]byte(tt.in))
+		if got := strings.Join(keys, "|"); got != tt.keys || string(rest) != tt.rest {
+			t.Errorf("splitKeys(%q) = %q, rest %q; want %q, rest %q", tt.in, got, rest, tt.keys, tt.rest)
+		}
+	}
+}
+
+// readAll feeds writes to readKeys with the given pause between them and
+// returns the keys it sends.
+func readAll(writes []string, pause time.Duration) []string {
+	r, w := io.Pipe()
+	keys := make(chan string)
+	go readKeys(r, keys)
+	go func() {
+		for _, s := range writes {
+			w.Write([]byte(s))
+			time.Sleep(pause)
+		}
+		w.Close()
+	}()
+	var out []string
+	for k := range keys {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestReadKeys(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		writes []string
+		pause  time.Duration
+		want   string
+	}{
+		{"one read", []string{"j\x1b[Bk"}, 0, "j|\x1b[B|k"},
+		{"arrow split after ESC", []string{"\x1b", "[A"}, escTimeout / 5, "\x1b[A"},
+		{"arrow split after [", []string{"\x1b[", "B", "q"}, escTimeout / 5, "\x1b[B|q"},
+		{"lone ESC", []string{"\x1b", "j"}, 3 * escTimeout, "\x1b|j"},
+		{"ESC at the end of input", []string{"k\x1b"}, 0, "k|\x1b"},
+	} {
+		if got := strings.Join(readAll(tt.writes, tt.pause), "|"); got != tt.want {
+			t.Errorf("%s: keys %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testApp() *app {
+	a := &app{history: make(map[string][]int), height: 24}
+	a.apply(model.Event{Data: model.Status{Networks: []model.Sample{
+		{IfName: "wlan0", BSSID: "aa", SSID: "cafe", FreqMHz: 5180, SignalDBM: -70},
+		{IfName: "wlan0", BSSID: "bb", SSID: "Home", FreqMHz: 2437, SignalDBM: -50},
+		{IfName: "wlan0", BSSID: "cc", SSID: "attic", FreqMHz: 2412, SignalDBM: -80},
+		{IfName: "wlan0", BSSID: "dd", SSID: "home", FreqMHz: 2437, SignalDBM: -60},
+	}}})
+	return a
+}
+
+func order(a *app) string {
+	var out []string
+	for _, n := range a.rows {
+		out = append(out, n.BSSID)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestSortRows(t *testing.T) {
+	a := testApp()
+	for _, tt := range []struct {
+		by   sortKey
+		want string
+	}{
+		{bySignal, "bb dd aa cc"},
+		// Case-insensitive, then by signal.
+		{bySSID, "cc aa bb dd"},
+		{byChannel, "cc bb dd aa"},
+	} {
+		a.sortBy = tt.by
+		a.sortRows()
+		if got := order(a); got != tt.want {
+			t.Errorf("by %s: %s, want %s", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	var targets []string
+	rescans := 0
+	a := testApp()
+	a.opts = Options{
+		SetTarget: func(ssid, bssid string) { targets = append(targets, ssid+"/"+bssid) },
+		Rescan:    func() { rescans++ },
+	}
+	if a.selected != "wlan0/bb" {
+		t.Fatalf("initial selection %q, want the strongest", a.selected)
+	}
+
+	for _, tt := range []struct {
+		key      string
+		selected string
+	}{
+		{"j", "wlan0/dd"},
+		{"\x1b[B", "wlan0/aa"},
+		{"j", "wlan0/cc"},
+		{"j", "wlan0/cc"}, // stays on the last row
+		{"k", "wlan0/aa"},
+		{"g", "wlan0/bb"},
+		{"\x1b[A", "wlan0/bb"},
+		{"G", "wlan0/cc"},
+		{"\x1b[5~", "wlan0/bb"},
+		{" ", "wlan0/cc"},
+	} {
+		if !a.key(tt.key) || a.selected != tt.selected {
+			t.Errorf("key %q: selected %q, want %q", tt.key, a.selected, tt.selected)
+		}
+	}
+
+	// Sorting keeps the selection on the same network.
+	a.key("s")
+	if a.sortBy != bySSID || a.selected != "wlan0/cc" || a.rows[0].BSSID != "cc" {
+		t.Errorf("after s: sort %s, selected %q", a.sortBy, a.selected)
+	}
+	a.key("s")
+	a.key("s")
+	if a.sortBy != bySignal {
+		t.Errorf("s cycles back to %s", a.sortBy)
+	}
+
+	a.key("\r")
+	a.key("r")
+	if strings.Join(targets, ",") != "attic/cc" || rescans != 1 || a.message != "rescanning" {
+		t.Errorf("targets %v, rescans %d, message %q", targets, rescans, a.message)
+	}
+
+	for _, k := range []string{"q", "Q", "\x03", "\x1b"} {
+		if a.key(k) {
+			t.Errorf("key %q did not quit", k)
+		}
+	}
+	if !a.key("\x1b[") {
+		t.Error("an unfinished sequence quit")
+	}
+
+	// Link mode has no target to change.
+	a.opts.SetTarget = nil
+	a.key("t")
+	if a.message != "changing the target needs scan mode" {
+		t.Errorf("message = %q", a.message)
+	}
+}
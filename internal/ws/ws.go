@@ -0,0 +1,279 @@
+// Package ws is a minimal RFC 6455 WebSocket server: handshake, text and
+// binary messages with fragmentation, ping/pong and close.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseTooBig        = 1009
+
+	acceptGUID   = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	ErrTooBig = errors.New("ws: message too big")
+	// ErrClosed is returned by ReadMessage after the peer sent a close
+	// frame.
+	ErrClosed = errors.New("ws: connection closed")
+)
+
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// MaxMessage limits the size of a received message.
+	MaxMessage int
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// Upgrade performs the server handshake. Requests with an Origin from a
+// different host are refused, so other sites cannot drive the API from a
+// visitor's browser.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("ws: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("ws: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("ws: missing key")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			http.Error(w, "cross-origin websocket refused", http.StatusForbidden)
+			return nil, fmt.Errorf("ws: origin %q not allowed", origin)
+		}
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("ws: response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, br: rw.Reader, MaxMessage: 1 << 20}, nil
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped; a close frame is echoed and ends the connection with
+// ErrClosed.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		op  int
+		msg []byte
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case OpPing:
+			if err := c.writeFrame(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			code := CloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			_ = c.WriteClose(code, "")
+			return 0, nil, ErrClosed
+		case OpText, OpBinary:
+			if msg != nil {
+				c.fail(CloseProtocolError)
+				return 0, nil, errors.New("ws: new message inside a fragmented one")
+			}
+			op = opcode
+			msg = append([]byte{}, payload...)
+		case OpContinuation:
+			if msg == nil {
+				c.fail(CloseProtocolError)
+				return 0, nil, errors.New("ws: unexpected continuation frame")
+			}
+			msg = append(msg, payload...)
+		default:
+			c.fail(CloseProtocolError)
+			return 0, nil, fmt.Errorf("ws: unknown opcode %d", opcode)
+		}
+		if len(msg) > c.MaxMessage {
+			c.fail(CloseTooBig)
+			return 0, nil, ErrTooBig
+		}
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(OpText, data)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(OpPing, nil)
+}
+
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	err := c.writeFrame(OpClose, payload)
+	c.wmu.Lock()
+	c.closed = true
+	c.wmu.Unlock()
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) fail(code int) {
+	_ = c.WriteClose(code, "")
+	c.conn.Close()
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		c.fail(CloseProtocolError)
+		return false, 0, nil, errors.New("ws: reserved bits set")
+	}
+	masked := head[1]&0x80 != 0
+	if !masked {
+		c.fail(CloseProtocolError)
+		return false, 0, nil, errors.New("ws: client frame not masked")
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= OpClose && (length > 125 || !fin) {
+		c.fail(CloseProtocolError)
+		return false, 0, nil, errors.New("ws: invalid control frame")
+	}
+	if length > uint64(c.MaxMessage) {
+		c.fail(CloseTooBig)
+		return false, 0, nil, ErrTooBig
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|byte(opcode))
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,349 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type frame struct {
+	fin     bool
+	op      int
+	payload []byte
+	// header is the raw frame header, to check the length encoding.
+	header []byte
+}
+
+// clientFrame builds a frame as a browser sends it: masked, with the
+// shortest length encoding.
+func clientFrame(fin bool, op int, payload []byte) []byte {
+	return rawFrame(fin, op, payload, true)
+}
+
+func rawFrame(fin bool, op int, payload []byte, masked bool) []byte {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	out := []byte{b0}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		out = append(out, maskBit|byte(n))
+	case n <= 0xffff:
+		out = append(out, maskBit|126)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, maskBit|127)
+		out = binary.BigEndian.AppendUint64(out, uint64(n))
+	}
+	if !masked {
+		return append(out, payload...)
+	}
+	key := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+	out = append(out, key[:]...)
+	for i, c := range payload {
+		out = append(out, c^key[i%4])
+	}
+	return out
+}
+
+// peer is the client end of a net.Pipe. Everything the server writes is
+// decoded into frames.
+type peer struct {
+	conn   net.Conn
+	frames chan frame
+}
+
+func newPair(t *testing.T, maxMessage int) (*Conn, *peer) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	p := &peer{conn: client, frames: make(chan frame, 16)}
+	go p.read()
+	return &Conn{conn: server, br: bufio.NewReader(server), MaxMessage: maxMessage}, p
+}
+
+func (p *peer) read() {
+	defer close(p.frames)
+	r := bufio.NewReader(p.conn)
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return
+		}
+		f := frame{fin: head[0]&0x80 != 0, op: int(head[0] & 0x0f), header: head[:]}
+		length := uint64(head[1] & 0x7f)
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			io.ReadFull(r, ext)
+			f.header = append(f.header, ext...)
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			io.ReadFull(r, ext)
+			f.header = append(f.header, ext...)
+			length = binary.BigEndian.Uint64(ext)
+		}
+		f.payload = make([]byte, length)
+		if _, err := io.ReadFull(r, f.payload); err != nil {
+			return
+		}
+		p.frames <- f
+	}
+}
+
+// send writes frames from a goroutine; net.Pipe blocks until the server
+// reads them.
+func (p *peer) send(frames ...[]byte) {
+	data := bytes.Join(frames, nil)
+	go p.conn.Write(data)
+}
+
+func (p *peer) next(t *testing.T) frame {
+	t.Helper()
+	select {
+	case f, ok := <-p.frames:
+		if !ok {
+			t.Fatal("connection closed, want a frame")
+		}
+		return f
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a frame")
+	}
+	return frame{}
+}
+
+// expectClose checks that the server sent a close frame with code.
+func (p *peer) expectClose(t *testing.T, code int) {
+	t.Helper()
+	f := p.next(t)
+	if f.op != OpClose || len(f.payload) < 2 {
+		t.Fatalf("frame = op %d % x, want close", f.op, f.payload)
+	}
+	if got := int(binary.BigEndian.Uint16(f.payload)); got != code {
+		t.Errorf("close code = %d, want %d", got, code)
+	}
+}
+
+func TestReadMasked(t *testing.T) {
+	c, p := newPair(t, 1<<20)
+	p.send(clientFrame(true, OpText, []byte("hello, radar")))
+	op, msg, err := c.ReadMessage()
+	if err != nil || op != OpText || string(msg) != "hello, radar" {
+		t.Errorf("ReadMessage = %d %q %v", op, msg, err)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	c, p := newPair(t, 1<<20)
+	// A ping between fragments is answered without breaking the message.
+	p.send(
+		clientFrame(false, OpBinary, []byte{1, 2}),
+		clientFrame(true, OpPing, []byte("are you there")),
+		clientFrame(false, OpContinuation, []byte{3}),
+		clientFrame(true, OpPong, nil),
+		clientFrame(true, OpContinuation, []byte{4, 5}),
+	)
+	op, msg, err := c.ReadMessage()
+	if err != nil || op != OpBinary || !bytes.Equal(msg, []byte{1, 2, 3, 4, 5}) {
+		t.Errorf("ReadMessage = %d % x %v", op, msg, err)
+	}
+	if f := p.next(t); f.op != OpPong || string(f.payload) != "are you there" {
+		t.Errorf("reply to ping = op %d %q", f.op, f.payload)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"unmasked", [][]byte{rawFrame(true, OpText, []byte("hi"), false)}},
+		{"reserved bits", [][]byte{func() []byte {
+			f := clientFrame(true, OpText, []byte("hi"))
+			f[0] |= 0x40
+			return f
+		}()}},
+		{"unexpected continuation", [][]byte{clientFrame(true, OpContinuation, []byte("hi"))}},
+		{"message inside a fragmented one", [][]byte{
+			clientFrame(false, OpText, []byte("a")),
+			clientFrame(true, OpText, []byte("b")),
+		}},
+		{"fragmented control frame", [][]byte{clientFrame(false, OpPing, nil)}},
+		{"long control frame", [][]byte{clientFrame(true, OpPing, make([]byte, 126))}},
+		{"unknown opcode", [][]byte{clientFrame(true, 0x3, nil)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, p := newPair(t, 1<<20)
+			p.send(tt.frames...)
+			if _, _, err := c.ReadMessage(); err == nil {
+				t.Error("ReadMessage: want an error")
+			}
+			p.expectClose(t, CloseProtocolError)
+		})
+	}
+}
+
+func TestTooBig(t *testing.T) {
+	c, p := newPair(t, 10)
+	p.send(clientFrame(true, OpText, make([]byte, 11)))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrTooBig) {
+		t.Errorf("err = %v, want ErrTooBig", err)
+	}
+	p.expectClose(t, CloseTooBig)
+
+	// Fragments that each fit but add up to too much.
+	c, p = newPair(t, 10)
+	p.send(clientFrame(false, OpText, make([]byte, 6)), clientFrame(true, OpContinuation, make([]byte, 6)))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrTooBig) {
+		t.Errorf("fragmented: err = %v, want ErrTooBig", err)
+	}
+	p.expectClose(t, CloseTooBig)
+}
+
+func TestClose(t *testing.T) {
+	c, p := newPair(t, 1<<20)
+	p.send(clientFrame(true, OpClose, binary.BigEndian.AppendUint16(nil, CloseGoingAway)))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrClosed) {
+		t.Errorf("err = %v, want ErrClosed", err)
+	}
+	p.expectClose(t, CloseGoingAway) // echoed
+	if err := c.WriteText([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteText after close: err = %v", err)
+	}
+
+	// A close without a status code is answered with 1000.
+	c, p = newPair(t, 1<<20)
+	p.send(clientFrame(true, OpClose, nil))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrClosed) {
+		t.Errorf("err = %v, want ErrClosed", err)
+	}
+	p.expectClose(t, CloseNormal)
+
+	c, p = newPair(t, 1<<20)
+	go c.WriteClose(CloseNormal, "bye")
+	f := p.next(t)
+	if f.op != OpClose || !bytes.Equal(f.payload, append([]byte{0x03, 0xe8}, "bye"...)) {
+		t.Errorf("close frame = op %d % x", f.op, f.payload)
+	}
+}
+
+func TestLengths(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xffff, 0x10000, 70000} {
+		c, p := newPair(t, 1<<20)
+		payload := bytes.Repeat([]byte{'x'}, n)
+
+		// Server to client: unmasked, with the shortest length form.
+		go c.WriteText(payload)
+		f := p.next(t)
+		wantHeader := rawFrame(true, OpText, nil, false)[:1]
+		switch {
+		case n < 126:
+			wantHeader = append(wantHeader, byte(n))
+		case n <= 0xffff:
+			wantHeader = binary.BigEndian.AppendUint16(append(wantHeader, 126), uint16(n))
+		default:
+			wantHeader = binary.BigEndian.AppendUint64(append(wantHeader, 127), uint64(n))
+		}
+		if !bytes.Equal(f.header, wantHeader) || len(f.payload) != n || !f.fin {
+			t.Errorf("%d bytes: header % x, want % x; payload %d bytes", n, f.header, wantHeader, len(f.payload))
+		}
+
+		// Client to server.
+		p.send(clientFrame(true, OpText, payload))
+		_, msg, err := c.ReadMessage()
+		if err != nil || !bytes.Equal(msg, payload) {
+			t.Errorf("%d bytes: read %d bytes, %v", n, len(msg), err)
+		}
+	}
+
+	// A 64-bit length beyond the limit is refused before reading it.
+	c, p := newPair(t, 1<<20)
+	head := []byte{0x80 | OpBinary, 0x80 | 127}
+	head = binary.BigEndian.AppendUint64(head, 1<<40)
+	p.send(append(head, 0, 0, 0, 0))
+	if _, _, err := c.ReadMessage(); !errors.Is(err, ErrTooBig) {
+		t.Errorf("huge frame: err = %v, want ErrTooBig", err)
+	}
+	p.expectClose(t, CloseTooBig)
+}
+
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if op, msg, err := c.ReadMessage(); err == nil {
+			c.writeFrame(op, bytes.ToUpper(msg))
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	handshake := func(header string) (net.Conn, *bufio.Reader, *http.Response) {
+		t.Helper()
+		conn, err := net.Dial("tcp", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		req := "GET /ws HTTP/1.1\r\nHost: " + host + "\r\n" + header + "\r\n"
+		if _, err := conn.Write([]byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, br, resp
+	}
+	const upgrade = "Connection: keep-alive, Upgrade\r\nUpgrade: websocket\r\n"
+
+	// The key and accept value from RFC 6455 section 1.3.
+	conn, br, resp := handshake(upgrade + "Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nOrigin: http://" + host + "\r\n")
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s, accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	conn.Write(clientFrame(true, OpText, []byte("ping")))
+	var head [2]byte
+	io.ReadFull(br, head[:])
+	echo := make([]byte, head[1])
+	io.ReadFull(br, echo)
+	if head[0] != 0x80|OpText || string(echo) != "PING" {
+		t.Errorf("echo = % x %q", head, echo)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"plain GET", "", http.StatusUpgradeRequired},
+		{"old version", upgrade + "Sec-WebSocket-Version: 8\r\nSec-WebSocket-Key: x\r\n", http.StatusBadRequest},
+		{"no key", upgrade + "Sec-WebSocket-Version: 13\r\n", http.StatusBadRequest},
+		{"cross origin", upgrade + "Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: x\r\nOrigin: http://evil.example\r\n", http.StatusForbidden},
+	} {
+		if _, _, resp := handshake(tt.header); resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
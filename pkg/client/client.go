@@ -0,0 +1,133 @@
+// Package client is a Go client for the WiFi Radar HTTP API described by
+// /api/openapi.json.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-radar/internal/model"
+)
+
+// The API's JSON types, re-exported so callers need not import anything
+// else.
+type (
+	Sample      = model.Sample
+	Status      = model.Status
+	StatusDelta = model.StatusDelta
+	Best        = model.Best
+	Hunt        = model.Hunt
+	Alert       = model.Alert
+	Location    = model.Location
+	Distance    = model.Distance
+)
+
+// ErrNotFound is returned when the server has nothing to report yet, or
+// the feature is not enabled.
+var ErrNotFound = errors.New("not found")
+
+type Client struct {
+	// BaseURL is the server address, e.g. http://127.0.0.1:8888.
+	BaseURL string
+	// Token is sent as a bearer token when set.
+	Token string
+	// HTTPClient defaults to http.DefaultClient. Its Timeout also applies
+	// to streams, so leave it zero when using Stream.
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.getJSON(ctx, "/api/status", nil, &status)
+	return status, err
+}
+
+// Best returns the interface with the best smoothed signal, or
+// ErrNotFound before the first sample.
+func (c *Client) Best(ctx context.Context) (Best, error) {
+	var best Best
+	err := c.getJSON(ctx, "/api/best", nil, &best)
+	return best, err
+}
+
+type HistoryQuery struct {
+	// IfName limits the result to one interface.
+	IfName string
+	// Since limits the result to newer samples.
+	Since time.Time
+	// Limit keeps only the newest samples when > 0.
+	Limit int
+}
+
+// History returns recent raw samples, oldest first.
+func (c *Client) History(ctx context.Context, q HistoryQuery) ([]Sample, error) {
+	params := url.Values{}
+	if q.IfName != "" {
+		params.Set("ifname", q.IfName)
+	}
+	if !q.Since.IsZero() {
+		params.Set("since_ms", strconv.FormatInt(q.Since.UnixMilli(), 10))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var samples []Sample
+	err := c.getJSON(ctx, "/api/history", params, &samples)
+	return samples, err
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, params url.Values, v any) error {
+	resp, err := c.get(ctx, path, params, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values, header http.Header) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("%s %s: %s %s", req.Method, path, resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wifi-radar/internal/api"
+	"wifi-radar/internal/model"
+	"wifi-radar/internal/store"
+)
+
+// newServer serves the real API handlers for st and records the
+// headers of each request.
+func newServer(t *testing.T, st *store.Store) (*httptest.Server, func() []http.Header) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		headers []http.Header
+	)
+	mux := http.NewServeMux()
+	for _, rt := range (api.API{Store: st}).Routes() {
+		mux.HandleFunc(rt.Pattern(), rt.Handler)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]http.Header(nil), headers...)
+	}
+}
+
+func TestStatusBestHistory(t *testing.T) {
+	ctx := context.Background()
+	st := store.New(10)
+	srv, headers := newServer(t, st)
+	c := New(srv.URL + "/")
+	c.Token = "secret"
+
+	if _, err := c.Best(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Best before any sample: %v, want ErrNotFound", err)
+	}
+
+	t0 := time.UnixMilli(1700000000000)
+	for _, s := range []model.Sample{
+		{IfName: "wlan0", SignalDBM: -70, TimestampUnixM: t0.UnixMilli()},
+		{IfName: "wlan1", SignalDBM: -40, TimestampUnixM: t0.Add(time.Second).UnixMilli()},
+		{IfName: "wlan0", SignalDBM: -68, TimestampUnixM: t0.Add(2 * time.Second).UnixMilli()},
+	} {
+		st.Update(s)
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Interfaces) != 2 {
+		t.Errorf("status interfaces = %+v", status.Interfaces)
+	}
+
+	best, err := c.Best(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Sample.IfName != "wlan1" {
+		t.Errorf("best = %s, want wlan1", best.Sample.IfName)
+	}
+
+	for _, tt := range []struct {
+		q    HistoryQuery
+		want []int
+	}{
+		{HistoryQuery{}, []int{-70, -40, -68}},
+		{HistoryQuery{IfName: "wlan0"}, []int{-70, -68}},
+		{HistoryQuery{Since: t0}, []int{-40, -68}},
+		{HistoryQuery{Limit: 1}, []int{-68}},
+	} {
+		samples, err := c.History(ctx, tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, s := range samples {
+			got = append(got, s.SignalDBM)
+		}
+		if !equal(got, tt.want) {
+			t.Errorf("History(%+v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	for _, h := range headers() {
+		if h.Get("Authorization") != "Bearer secret" || h.Get("Accept") != "application/json" {
+			t.Errorf("headers = %v", h)
+		}
+	}
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/best":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	if _, err := c.Best(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("404: %v, want ErrNotFound", err)
+	}
+	_, err := c.History(context.Background(), HistoryQuery{Limit: 1})
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request invalid limit") {
+		t.Errorf("400: %v", err)
+	}
+}
+
+func alert(id string) model.Event {
+	return model.Event{Type: model.EventAlert, Data: model.Alert{ID: id, Kind: "test", State: "firing"}}
+}
+
+// next reads one event from s, failing the test if there is none.
+func next(t *testing.T, s *Stream) Event {
+	t.Helper()
+	if !s.Next() {
+		t.Fatalf("stream ended: %v", s.Err())
+	}
+	return s.Event()
+}
+
+func TestStreamReconnect(t *testing.T) {
+	st := store.New(10)
+	srv, headers := newServer(t, st)
+	c := New(srv.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A new client gets the current status first.
+	st.Update(model.Sample{IfName: "wlan0", SignalDBM: -60, TimestampUnixM: model.NowUnixMS()})
+	s, err := c.Stream(ctx, StreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := next(t, s)
+	var status Status
+	if ev.Type != "status" || ev.ID != 1 || ev.Decode(&status) != nil || len(status.Interfaces) != 1 {
+		t.Fatalf("first event = %d %s %s", ev.ID, ev.Type, ev.Data)
+	}
+	st.Publish(alert("a1"))
+	var a Alert
+	if ev := next(t, s); ev.Type != "alert" || ev.ID != 2 || ev.Decode(&a) != nil || a.ID != "a1" {
+		t.Fatalf("second event = %d %s %s", ev.ID, ev.Type, ev.Data)
+	}
+	if s.LastEventID() != 2 {
+		t.Errorf("LastEventID = %d, want 2", s.LastEventID())
+	}
+
+	// Events published while the client is away are delivered after it
+	// reconnects with the last ID it saw.
+	s.Close()
+	if s.Next() || s.Err() == nil {
+		t.Error("closed stream kept going without an error")
+	}
+	st.Publish(alert("a2"))
+	st.Publish(alert("a3"))
+
+	s, err = c.Stream(ctx, StreamOptions{Types: []string{"alert"}, LastEventID: s.LastEventID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var got []string
+	for i := 0; i < 2; i++ {
+		ev := next(t, s)
+		if ev.Decode(&a) != nil {
+			t.Fatalf("alert data %s", ev.Data)
+		}
+		got = append(got, a.ID)
+	}
+	if strings.Join(got, ",") != "a2,a3" || s.LastEventID() != 4 {
+		t.Errorf("after reconnect: %v up to %d, want a2,a3 up to 4", got, s.LastEventID())
+	}
+
+	h := headers()
+	if last := h[len(h)-1]; last.Get("Last-Event-ID") != "2" || last.Get("Accept") != "text/event-stream" {
+		t.Errorf("reconnect headers = %v", last)
+	}
+	if h[0].Get("Last-Event-ID") != "" {
+		t.Errorf("first connection sent Last-Event-ID %q", h[0].Get("Last-Event-ID"))
+	}
+}
+
+func TestStreamParse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Encode(); got != "bssid=aa%3Abb&ifname=wlan0%2Cwlan1&max_hz=0.5&types=status%2Chunt" {
+			http.Error(w, "query "+got, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": comment\nretry: 1000\n\n" +
+			"id: 7\nevent: status\ndata: {\"a\":\ndata: 1}\n\n" +
+			"data:{}\n\n" +
+			"id: x\nevent: status\ndata: {}\n\n"))
+	}))
+	defer srv.Close()
+
+	s, err := New(srv.URL).Stream(context.Background(), StreamOptions{
+		Types: []string{"status", "hunt"}, IfNames: []string{"wlan0", "wlan1"}, BSSIDs: []string{"aa:bb"}, MaxHz: 0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if ev := next(t, s); ev.ID != 7 || ev.Type != "status" || string(ev.Data) != "{\"a\":\n1}" {
+		t.Errorf("multi-line event = %d %s %q", ev.ID, ev.Type, ev.Data)
+	}
+	if ev := next(t, s); ev.ID != 0 || ev.Type != "message" || string(ev.Data) != "{}" || s.LastEventID() != 7 {
+		t.Errorf("untyped event = %d %s %q, last id %d", ev.ID, ev.Type, ev.Data, s.LastEventID())
+	}
+	if s.Next() || s.Err() == nil || !strings.Contains(s.Err().Error(), `invalid event id "x"`) {
+		t.Errorf("bad id: %v", s.Err())
+	}
+}
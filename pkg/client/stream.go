@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type StreamOptions struct {
+	// Types limits the event types, e.g. "status", "alert".
+	Types []string
+	// IfNames and BSSIDs filter status events.
+	IfNames []string
+	BSSIDs  []string
+	// MaxHz limits the status rate; the server sends the newest status
+	// when the interval is up.
+	MaxHz float64
+	// LastEventID resumes after an event from an earlier stream.
+	LastEventID uint64
+}
+
+type Event struct {
+	// ID is zero for events sent without one. A hunt carries the ID of
+	// the status it was computed from.
+	ID   uint64
+	Type string
+	Data json.RawMessage
+}
+
+// Decode unmarshals the event data into v, e.g. a *Status for "status"
+// events or an *Alert for "alert" events.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Stream iterates over the server's Server-Sent Events:
+//
+//	s, err := c.Stream(ctx, client.StreamOptions{Types: []string{"status"}})
+//	...
+//	defer s.Close()
+//	for s.Next() {
+//		ev := s.Event()
+//		...
+//	}
+//	if err := s.Err(); err != nil { ... }
+//
+// To carry on after an error, open a new stream with LastEventID set to
+// s.LastEventID().
+type Stream struct {
+	body   io.ReadCloser
+	sc     *bufio.Scanner
+	ev     Event
+	lastID uint64
+	err    error
+}
+
+func (c *Client) Stream(ctx context.Context, opts StreamOptions) (*Stream, error) {
+	params := url.Values{}
+	if len(opts.Types) > 0 {
+		params.Set("types", strings.Join(opts.Types, ","))
+	}
+	if len(opts.IfNames) > 0 {
+		params.Set("ifname", strings.Join(opts.IfNames, ","))
+	}
+	if len(opts.BSSIDs) > 0 {
+		params.Set("bssid", strings.Join(opts.BSSIDs, ","))
+	}
+	if opts.MaxHz > 0 {
+		params.Set("max_hz", strconv.FormatFloat(opts.MaxHz, 'f', -1, 64))
+	}
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if opts.LastEventID > 0 {
+		header.Set("Last-Event-ID", strconv.FormatUint(opts.LastEventID, 10))
+	}
+
+	resp, err := c.get(ctx, "/api/stream", params, header)
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	return &Stream{body: resp.Body, sc: sc, lastID: opts.LastEventID}, nil
+}
+
+// Next reads the next event, returning false at the end of the stream or
+// on error.
+func (s *Stream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	var (
+		ev   Event
+		data []string
+	)
+	for s.sc.Scan() {
+		line := s.sc.Text()
+		if line == "" {
+			if data == nil {
+				// A retry: or comment-only block.
+				ev = Event{}
+				continue
+			}
+			if ev.Type == "" {
+				ev.Type = "message"
+			}
+			ev.Data = json.RawMessage(strings.Join(data, "\n"))
+			if ev.ID > 0 {
+				s.lastID = ev.ID
+			}
+			s.ev = ev
+			return true
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				s.err = fmt.Errorf("invalid event id %q", value)
+				return false
+			}
+			ev.ID = id
+		case "event":
+			ev.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	s.err = s.sc.Err()
+	if s.err == nil {
+		s.err = io.ErrUnexpectedEOF
+	}
+	return false
+}
+
+func (s *Stream) Event() Event {
+	return s.ev
+}
+
+// LastEventID is the ID of the last event read that had one.
+func (s *Stream) LastEventID() uint64 {
+	return s.lastID
+}
+
+// Err returns the error that ended the stream. A stream closed by Close
+// or a cancelled context also reports an error.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
@@ -9,10 +9,14 @@ const elements = {
   ssid: document.getElementById("ssid"),
   bssid: document.getElementById("bssid"),
   freq: document.getElementById("freq"),
+  location: document.getElementById("location"),
   signal: document.getElementById("signal-db"),
   quality: document.getElementById("quality"),
   rx: document.getElementById("rx"),
   tx: document.getElementById("tx"),
+  distance: document.getElementById("distance"),
+  distanceRange: document.getElementById("distance-range"),
+  calibrate: document.getElementById("calibrate"),
   gaugeFill: document.getElementById("gauge-fill"),
   needle: document.getElementById("needle"),
   pulse: document.getElementById("pulse"),
@@ -40,15 +44,28 @@ function pickBestSample(samples) {
   }, null);
 }
 
+function formatBSSID(sample) {
+  if (!sample.bssid) {
+    return "—";
+  }
+  if (sample.randomized) {
+    return `${sample.bssid} (randomized)`;
+  }
+  return sample.vendor ? `${sample.bssid} (${sample.vendor})` : sample.bssid;
+}
+
 function updateReadout(sample) {
   elements.ifname.textContent = sample.ifname || "—";
   elements.ssid.textContent = sample.ssid || "—";
-  elements.bssid.textContent = sample.bssid || "—";
+  elements.bssid.textContent = formatBSSID(sample);
   elements.freq.textContent = sample.freq_mhz ? `${sample.freq_mhz} MHz` : "—";
   elements.signal.textContent = sample.signal_dbm ?? "—";
+  elements.location.textContent = formatLocation(sample.location);
   elements.rx.textContent = sample.rx_mbps ? `${sample.rx_mbps.toFixed(1)} Mbps` : "—";
   elements.tx.textContent = sample.tx_mbps ? `${sample.tx_mbps.toFixed(1)} Mbps` : "—";
 
+  updateDistance(sample.distance);
+
   const quality = normalizeSignal(sample.signal_dbm);
   state.targetQuality = quality;
 
@@ -67,6 +84,274 @@ function updateReadout(sample) {
   elements.pulse.style.opacity = `${0.2 + quality / 140}`;
 }
 
+function formatLocation(location) {
+  if (!location) return "—";
+  const accuracy = location.accuracy_m ? ` ±${Math.round(location.accuracy_m)} m` : "";
+  return `${location.lat.toFixed(5)}, ${location.lon.toFixed(5)}${accuracy}`;
+}
+
+function formatMeters(meters) {
+  if (meters >= 100) return `${Math.round(meters)} m`;
+  return `${meters.toFixed(1)} m`;
+}
+
+function updateDistance(distance) {
+  if (!distance) {
+    elements.distance.textContent = "—";
+    elements.distanceRange.textContent = "";
+    return;
+  }
+  elements.distance.textContent = `~${formatMeters(distance.meters)}`;
+  const source = distance.calibrated ? "calibrated" : "estimated";
+  elements.distanceRange.textContent =
+    `${formatMeters(distance.min_meters)}–${formatMeters(distance.max_meters)} · ${source}`;
+}
+
+function calibrateDistance() {
+  elements.calibrate.disabled = true;
+  fetch("/api/distance/calibrate", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ meters: 1 }),
+  })
+    .then((res) => (res.ok ? res.json() : null))
+    .then((distance) => {
+      if (distance) updateDistance(distance);
+    })
+    .catch((err) => console.warn("Calibration failed", err))
+    .finally(() => {
+      elements.calibrate.disabled = false;
+    });
+}
+
+function postHeading(heading) {
+  fetch("/api/heading", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ heading_deg: heading }),
+  }).catch((err) => console.warn("Heading update failed", err));
+}
+
+function headingFromOrientation(event) {
+  if (typeof event.webkitCompassHeading === "number") return event.webkitCompassHeading;
+  if (event.alpha === null || event.alpha === undefined) return null;
+  if (!event.absolute && event.type !== "deviceorientationabsolute") return null;
+  return (360 - event.alpha) % 360;
+}
+
+function handleOrientation(event) {
+  const heading = headingFromOrientation(event);
+  if (heading === null) return;
+  const now = Date.now();
+  if (now - compass.lastPost < HEADING_POST_MS) return;
+  compass.lastPost = now;
+  postHeading(heading);
+}
+
+function startCompass() {
+  if (compass.active) return;
+  const eventName =
+    "ondeviceorientationabsolute" in window ? "deviceorientationabsolute" : "deviceorientation";
+  const listen = () => {
+    window.addEventListener(eventName, handleOrientation);
+    compass.active = true;
+    elements.dfCompass.textContent = "Compass on";
+    elements.dfCompass.disabled = true;
+  };
+
+  if (typeof DeviceOrientationEvent === "undefined") {
+    elements.dfGuidance.textContent = "No compass on this device; enter headings by hand";
+    return;
+  }
+  if (typeof DeviceOrientationEvent.requestPermission === "function") {
+    DeviceOrientationEvent.requestPermission()
+      .then((result) => {
+        if (result === "granted") listen();
+      })
+      .catch((err) => console.warn("Compass permission denied", err));
+    return;
+  }
+  listen();
+}
+
+function setManualHeading() {
+  const value = parseFloat(elements.dfManual.value);
+  if (Number.isNaN(value)) return;
+  postHeading(value);
+}
+
+function resetDirectionFinder() {
+  fetch("/api/df", { method: "DELETE" }).catch((err) => console.warn("Reset failed", err));
+}
+
+function drawPolar(df) {
+  const canvas = elements.dfPlot;
+  const ctx = canvas.getContext("2d");
+  const size = canvas.width;
+  const center = size / 2;
+  const radius = center - 12;
+  const toXY = (bearing, r) => {
+    const rad = ((bearing - 90) * Math.PI) / 180;
+    return [center + Math.cos(rad) * r, center + Math.sin(rad) * r];
+  };
+
+  ctx.clearRect(0, 0, size, size);
+  ctx.strokeStyle = "rgba(255, 255, 255, 0.12)";
+  ctx.setLineDash([3, 4]);
+  [0.33, 0.66, 1].forEach((f) => {
+    ctx.beginPath();
+    ctx.arc(center, center, radius * f, 0, Math.PI * 2);
+    ctx.stroke();
+  });
+  ctx.setLineDash([]);
+
+  if (!df) return;
+
+  const bins = df.bins || [];
+  if (bins.length > 0) {
+    ctx.fillStyle = "rgba(76, 217, 100, 0.35)";
+    ctx.strokeStyle = "#4cd964";
+    ctx.beginPath();
+    bins.forEach((bin, i) => {
+      const r = (normalizeSignal(bin.signal_dbm) / 100) * radius;
+      const [x, y] = toXY(bin.bearing_deg, r);
+      if (i === 0) ctx.moveTo(x, y);
+      else ctx.lineTo(x, y);
+    });
+    ctx.closePath();
+    ctx.fill();
+    ctx.stroke();
+  }
+
+  const line = (bearing, color, width) => {
+    const [x, y] = toXY(bearing, radius);
+    ctx.strokeStyle = color;
+    ctx.lineWidth = width;
+    ctx.beginPath();
+    ctx.moveTo(center, center);
+    ctx.lineTo(x, y);
+    ctx.stroke();
+    ctx.lineWidth = 1;
+  };
+  line(df.heading_deg, "rgba(245, 242, 232, 0.6)", 2);
+  if (df.fitted) line(df.bearing_deg, "#ffb347", 3);
+}
+
+function updateDirectionFinder(df) {
+  drawPolar(df);
+  if (!df) return;
+
+  elements.dfHeading.textContent = `${Math.round(df.heading_deg)}°`;
+  if (df.fitted) {
+    elements.dfBearing.textContent = `${Math.round(df.bearing_deg)}°`;
+    elements.dfConfidence.textContent = `${Math.round(df.confidence * 100)}% confidence`;
+  } else {
+    elements.dfBearing.textContent = "—";
+    elements.dfConfidence.textContent = `${Math.round(df.coverage * 100)}% swept`;
+  }
+
+  if (df.guidance === "turn left" || df.guidance === "turn right") {
+    elements.dfGuidance.textContent = `${df.guidance} ${Math.abs(Math.round(df.turn_deg))}°`;
+  } else {
+    elements.dfGuidance.textContent = df.guidance;
+  }
+}
+
+function formatAgo(ms) {
+  const seconds = Math.max(0, Math.round(ms / 1000));
+  if (seconds < 60) return `${seconds} s ago`;
+  return `${Math.floor(seconds / 60)} min ago`;
+}
+
+function updateHunt(hunt) {
+  elements.hunt.className = `hunt ${hunt.trend}`;
+  const slope = hunt.slope_db_per_s;
+  const rate = hunt.trend === "steady" ? "" : ` ${slope > 0 ? "+" : ""}${slope.toFixed(1)} dB/s`;
+  elements.huntTrend.textContent = `${hunt.trend}${rate}`;
+  elements.huntPeak.textContent = `Peak ${hunt.peak_dbm} dBm · ${formatAgo(hunt.since_peak_ms)}`;
+}
+
+function makeClickBuffer(ctx) {
+  const length = Math.round(ctx.sampleRate * 0.002);
+  const buffer = ctx.createBuffer(1, length, ctx.sampleRate);
+  const data = buffer.getChannelData(0);
+  for (let i = 0; i < length; i += 1) {
+    data[i] = (Math.random() * 2 - 1) * (1 - i / length);
+  }
+  return buffer;
+}
+
+function applySoundParams(params) {
+  sound.params = params;
+  const now = sound.ctx.currentTime;
+  const toneGain = params.mode === "tone" && !params.muted ? params.gain : 0;
+  if (params.tone_hz) sound.osc.frequency.setTargetAtTime(params.tone_hz, now, 0.05);
+  sound.gain.gain.setTargetAtTime(toneGain, now, 0.05);
+}
+
+function playClicks() {
+  const params = sound.params;
+  if (!params || params.mode !== "click" || params.muted) return;
+  if (Math.random() >= (params.click_rate * CLICK_TICK_MS) / 1000) return;
+  const source = sound.ctx.createBufferSource();
+  const gain = sound.ctx.createGain();
+  source.buffer = sound.noise;
+  gain.gain.value = params.gain;
+  source.connect(gain).connect(sound.ctx.destination);
+  source.start();
+}
+
+function startSound() {
+  const AudioCtx = window.AudioContext || window.webkitAudioContext;
+  if (!AudioCtx) {
+    elements.soundToggle.textContent = "No WebAudio";
+    return;
+  }
+  sound.ctx = new AudioCtx();
+  sound.gain = sound.ctx.createGain();
+  sound.gain.gain.value = 0;
+  sound.gain.connect(sound.ctx.destination);
+  sound.osc = sound.ctx.createOscillator();
+  sound.osc.type = "sine";
+  sound.osc.connect(sound.gain);
+  sound.osc.start();
+  sound.noise = makeClickBuffer(sound.ctx);
+
+  sound.source = new EventSource(`/api/audio/params?mode=${elements.soundMode.value}`);
+  sound.source.onmessage = (event) => {
+    try {
+      applySoundParams(JSON.parse(event.data));
+    } catch (err) {
+      console.warn("Bad audio payload", err);
+    }
+  };
+  sound.clickTimer = setInterval(playClicks, CLICK_TICK_MS);
+  elements.soundToggle.textContent = "Sound on";
+}
+
+function stopSound() {
+  if (sound.source) sound.source.close();
+  if (sound.clickTimer) clearInterval(sound.clickTimer);
+  if (sound.ctx) sound.ctx.close();
+  sound.ctx = null;
+  sound.source = null;
+  sound.params = null;
+  sound.clickTimer = null;
+  elements.soundToggle.textContent = "Sound off";
+}
+
+function toggleSound() {
+  if (sound.ctx) stopSound();
+  else startSound();
+}
+
+function changeSoundMode() {
+  elements.soundWav.href = `/api/audio?mode=${elements.soundMode.value}`;
+  if (!sound.ctx) return;
+  stopSound();
+  startSound();
+}
+
 function renderGauge() {
   state.displayQuality += (state.targetQuality - state.displayQuality) * 0.08;
   const quality = state.displayQuality;
@@ -80,6 +365,7 @@ function renderGauge() {
 }
 
 function handleStatus(status) {
+  updateDirectionFinder(status.df);
   const sample = pickBestSample(status.interfaces);
   if (!sample) return;
   updateReadout(sample);
@@ -96,19 +382,85 @@ function startStream() {
     });
 
   const source = new EventSource("/api/stream");
-  source.onmessage = (event) => {
+  source.addEventListener("status", (event) => {
     try {
       const data = JSON.parse(event.data);
       handleStatus(data);
     } catch (err) {
       console.warn("Bad stream payload", err);
     }
-  };
+  });
+
+  source.addEventListener("hunt", (event) => {
+    try {
+      updateHunt(JSON.parse(event.data));
+    } catch (err) {
+      console.warn("Bad hunt payload", err);
+    }
+  });
+
+  source.addEventListener("alert", (event) => {
+    try {
+      addAlert(JSON.parse(event.data));
+    } catch (err) {
+      console.warn("Bad alert payload", err);
+    }
+  });
 
   source.onerror = () => {
     elements.quality.textContent = "Stream paused";
   };
 }
 
+const alerts = new Map();
+
+function addAlert(alert) {
+  if (alert.state === "resolved") {
+    alerts.delete(alert.id);
+  } else {
+    alerts.set(alert.id, alert);
+  }
+  renderAlerts();
+}
+
+function renderAlerts() {
+  const card = document.getElementById("alerts");
+  const list = document.getElementById("alerts-list");
+  const sorted = [...alerts.values()].sort((a, b) => b.last_seen_unix_ms - a.last_seen_unix_ms);
+  card.hidden = sorted.length === 0;
+  document.getElementById("alerts-summary").textContent =
+    sorted.length === 1 ? "1 alert" : `${sorted.length} alerts`;
+  list.replaceChildren(
+    ...sorted.map((alert) => {
+      const item = document.createElement("li");
+      item.className = alert.severity;
+      const when = new Date(alert.last_seen_unix_ms).toLocaleTimeString();
+      const detail = document.createElement("small");
+      const kind = alert.rule || alert.kind.replace(/_/g, " ");
+      const signal = alert.signal_dbm ? ` · ${alert.signal_dbm} dBm` : "";
+      detail.textContent = `${kind}${signal} · ${when}`;
+      item.append(alert.message, detail);
+      return item;
+    })
+  );
+}
+
+function loadAlerts() {
+  fetch("/api/alerts/rogue")
+    .then((res) => (res.ok ? res.json() : []))
+    .then((list) => list.forEach((alert) => alerts.set(alert.id, alert)))
+    .then(renderAlerts)
+    .catch(() => {});
+}
+
+elements.calibrate.addEventListener("click", calibrateDistance);
+elements.dfCompass.addEventListener("click", startCompass);
+elements.dfSet.addEventListener("click", setManualHeading);
+elements.dfReset.addEventListener("click", resetDirectionFinder);
+elements.soundToggle.addEventListener("click", toggleSound);
+elements.soundMode.addEventListener("change", changeSoundMode);
+
+drawPolar(null);
 renderGauge();
+loadAlerts();
 startStream();
@@ -0,0 +1,198 @@
+const survey = {
+  project: null,
+  heatURL: null,
+};
+
+const elements = {
+  select: document.getElementById("survey-select"),
+  create: document.getElementById("survey-create"),
+  bss: document.getElementById("survey-bss"),
+  method: document.getElementById("survey-method"),
+  metric: document.getElementById("survey-metric"),
+  render: document.getElementById("survey-render"),
+  clear: document.getElementById("survey-clear"),
+  grid: document.getElementById("survey-grid"),
+  count: document.getElementById("survey-count"),
+  undo: document.getElementById("survey-undo"),
+  remove: document.getElementById("survey-delete"),
+  message: document.getElementById("survey-message"),
+  plan: document.getElementById("survey-plan"),
+  floorplan: document.getElementById("survey-floorplan"),
+  heat: document.getElementById("survey-heat"),
+  markers: document.getElementById("survey-markers"),
+};
+
+// Scans up to this long after a click are still attached to it server-side.
+const ATTACH_DELAY_MS = 3500;
+
+function showMessage(text) {
+  elements.message.textContent = text;
+}
+
+function requestJSON(url, options) {
+  return fetch(url, options).then((res) => {
+    if (!res.ok) return res.text().then((text) => Promise.reject(new Error(text.trim() || res.statusText)));
+    if (res.status === 204) return null;
+    return res.json();
+  });
+}
+
+function loadProjects(selectID) {
+  return requestJSON("/api/survey").then((projects) => {
+    elements.select.innerHTML = "";
+    if (projects.length === 0) {
+      elements.select.add(new Option("No surveys yet", ""));
+      openProject(null);
+      return;
+    }
+    projects.forEach((p) => elements.select.add(new Option(p.name, p.id)));
+    elements.select.value = selectID || projects[0].id;
+    openProject(elements.select.value);
+  });
+}
+
+function openProject(id) {
+  hideHeatmap();
+  if (!id) {
+    survey.project = null;
+    elements.floorplan.removeAttribute("src");
+    drawPoints();
+    return;
+  }
+  requestJSON(`/api/survey/${id}`)
+    .then((project) => {
+      survey.project = project;
+      elements.floorplan.src = `/api/survey/${id}/floorplan`;
+      drawPoints();
+      loadBSSes();
+    })
+    .catch((err) => showMessage(err.message));
+}
+
+function loadBSSes() {
+  if (!survey.project) return;
+  const current = elements.bss.value;
+  requestJSON(`/api/survey/${survey.project.id}/bss`)
+    .then((bsses) => {
+      elements.bss.innerHTML = "";
+      bsses.forEach((b) => {
+        const label = `${b.ssid || "<hidden>"} · ${b.bssid} · ${b.points} pts`;
+        elements.bss.add(new Option(label, b.bssid));
+      });
+      if (current) elements.bss.value = current;
+    })
+    .catch((err) => showMessage(err.message));
+}
+
+function drawPoints() {
+  elements.markers.innerHTML = "";
+  const project = survey.project;
+  if (!project) {
+    elements.count.textContent = "No survey open";
+    return;
+  }
+  project.points.forEach((p) => {
+    const marker = document.createElement("span");
+    marker.className = "survey-marker";
+    marker.style.left = `${(p.x / project.width) * 100}%`;
+    marker.style.top = `${(p.y / project.height) * 100}%`;
+    marker.title = `#${p.id} · ${p.observations.length} observations`;
+    elements.markers.appendChild(marker);
+  });
+  elements.count.textContent = `${project.points.length} points`;
+}
+
+function addPoint(event) {
+  const project = survey.project;
+  if (!project || !elements.floorplan.naturalWidth) return;
+  const rect = elements.floorplan.getBoundingClientRect();
+  const x = ((event.clientX - rect.left) / rect.width) * project.width;
+  const y = ((event.clientY - rect.top) / rect.height) * project.height;
+
+  requestJSON(`/api/survey/${project.id}/points`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ x, y }),
+  })
+    .then((point) => {
+      project.points.push(point);
+      drawPoints();
+      showMessage(`Point #${point.id}: ${point.observations.length} observations so far`);
+      setTimeout(() => {
+        openProject(project.id);
+      }, ATTACH_DELAY_MS);
+    })
+    .catch((err) => showMessage(err.message));
+}
+
+function createProject(event) {
+  event.preventDefault();
+  requestJSON("/api/survey", { method: "POST", body: new FormData(elements.create) })
+    .then((project) => {
+      elements.create.reset();
+      return loadProjects(project.id);
+    })
+    .catch((err) => showMessage(err.message));
+}
+
+function heatmapURL(format) {
+  const params = new URLSearchParams({
+    bssid: elements.bss.value,
+    method: elements.method.value,
+    metric: elements.metric.value,
+  });
+  if (format) params.set("format", format);
+  return `/api/survey/${survey.project.id}/heatmap?${params}`;
+}
+
+function renderHeatmap() {
+  if (!survey.project || !elements.bss.value) return;
+  elements.grid.href = heatmapURL("json");
+  fetch(heatmapURL())
+    .then((res) => {
+      if (!res.ok) return res.text().then((text) => Promise.reject(new Error(text.trim())));
+      return res.blob();
+    })
+    .then((blob) => {
+      hideHeatmap();
+      survey.heatURL = URL.createObjectURL(blob);
+      elements.heat.src = survey.heatURL;
+      elements.heat.hidden = false;
+      showMessage("");
+    })
+    .catch((err) => showMessage(err.message));
+}
+
+function hideHeatmap() {
+  elements.heat.hidden = true;
+  if (survey.heatURL) URL.revokeObjectURL(survey.heatURL);
+  survey.heatURL = null;
+}
+
+function undoPoint() {
+  const project = survey.project;
+  if (!project || project.points.length === 0) return;
+  const last = project.points[project.points.length - 1];
+  requestJSON(`/api/survey/${project.id}/points/${last.id}`, { method: "DELETE" })
+    .then(() => openProject(project.id))
+    .catch((err) => showMessage(err.message));
+}
+
+function deleteProject() {
+  const project = survey.project;
+  if (!project || !window.confirm(`Delete survey "${project.name}"?`)) return;
+  requestJSON(`/api/survey/${project.id}`, { method: "DELETE" })
+    .then(() => loadProjects())
+    .catch((err) => showMessage(err.message));
+}
+
+elements.select.addEventListener("change", () => openProject(elements.select.value));
+elements.create.addEventListener("submit", createProject);
+elements.floorplan.addEventListener("click", addPoint);
+elements.render.addEventListener("click", renderHeatmap);
+elements.clear.addEventListener("click", hideHeatmap);
+elements.undo.addEventListener("click", undoPoint);
+elements.remove.addEventListener("click", deleteProject);
+
+hideHeatmap();
+loadProjects().catch((err) => showMessage(err.message));